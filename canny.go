@@ -0,0 +1,214 @@
+package stablediffusion
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// CannyOptions configures Canny. Zero thresholds and Strong use the
+// stable-diffusion.cpp CLI defaults.
+type CannyOptions struct {
+	// HighThreshold is a fraction of the strongest gradient (default 0.08)
+	HighThreshold float32
+	// LowThreshold is a fraction of the high threshold (default 0.08)
+	LowThreshold float32
+	// Weak and Strong are the output levels of weak and strong edges (default 0.8 and 1)
+	Weak   float32
+	Strong float32
+	// Inverse draws dark edges on white
+	Inverse bool
+}
+
+func (o CannyOptions) withDefaults() CannyOptions {
+	if o.HighThreshold == 0 {
+		o.HighThreshold = 0.08
+	}
+	if o.LowThreshold == 0 {
+		o.LowThreshold = 0.08
+	}
+	if o.Weak == 0 {
+		o.Weak = 0.8
+	}
+	if o.Strong == 0 {
+		o.Strong = 1
+	}
+	return o
+}
+
+// Canny returns the Canny edges of img as a new image. The input is copied
+// into a separate buffer before native preprocess_canny runs on it; when the
+// library does not export that symbol the Go implementation is used.
+func (sd *StableDiffusion) Canny(img image.Image, opts CannyOptions) (image.Image, error) {
+	if img == nil || img.Bounds().Empty() {
+		return nil, fmt.Errorf("canny: empty image")
+	}
+	opts = opts.withDefaults()
+	if sd == nil || sd.preprocessCanny == nil {
+		return CannyGo(img, opts), nil
+	}
+
+	buf := ImageToSDImage(img)
+	if !sd.preprocessCanny(&buf, opts.HighThreshold, opts.LowThreshold, opts.Weak, opts.Strong, opts.Inverse) {
+		return nil, fmt.Errorf("preprocess_canny failed")
+	}
+	return SDImageToImage(&buf)
+}
+
+// Canny runs Canny with the default instance, or in Go when none is set
+func Canny(img image.Image, opts CannyOptions) (image.Image, error) {
+	return defaultSD.Canny(img, opts)
+}
+
+// CannyGo is the pure Go Canny detector. It follows the stable-diffusion.cpp
+// pipeline: grayscale, 5x5 gaussian (sigma 1.4), Sobel, non-maximum
+// suppression and a single hysteresis pass with thresholds relative to the
+// strongest gradient.
+func CannyGo(img image.Image, opts CannyOptions) *image.RGBA {
+	opts = opts.withDefaults()
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	gray := make([]float32, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			gray[y*w+x] = (0.2989*float32(r) + 0.5870*float32(g) + 0.1140*float32(bl)) / 0xffff
+		}
+	}
+
+	blurred := convolve(gray, w, h, gaussianKernel5(1.4))
+	gx := convolve(blurred, w, h, [][]float32{{-1, 0, 1}, {-2, 0, 2}, {-1, 0, 1}})
+	gy := convolve(blurred, w, h, [][]float32{{1, 2, 1}, {0, 0, 0}, {-1, -2, -1}})
+
+	mag := make([]float32, w*h)
+	var peak float32
+	for i := range mag {
+		mag[i] = float32(math.Hypot(float64(gx[i]), float64(gy[i])))
+		peak = max(peak, mag[i])
+	}
+	if peak > 0 {
+		for i := range mag {
+			mag[i] /= peak
+		}
+	}
+
+	thin := suppressNonMax(mag, gx, gy, w, h)
+	edges := hysteresis(thin, w, h, opts)
+
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i, v := range edges {
+		if opts.Inverse {
+			v = 1 - v
+		}
+		c := uint8(math.Round(float64(min(max(v, 0), 1)) * 255))
+		out.Pix[i*4], out.Pix[i*4+1], out.Pix[i*4+2], out.Pix[i*4+3] = c, c, c, 255
+	}
+	return out
+}
+
+func gaussianKernel5(sigma float64) [][]float32 {
+	k := make([][]float32, 5)
+	norm := 1 / (2 * math.Pi * sigma * sigma)
+	for y := range k {
+		k[y] = make([]float32, 5)
+		for x := range k[y] {
+			d := float64((y-2)*(y-2) + (x-2)*(x-2))
+			k[y][x] = float32(math.Exp(-d/(2*sigma*sigma)) * norm)
+		}
+	}
+	return k
+}
+
+// convolve applies kernel with zero padding
+func convolve(src []float32, w, h int, kernel [][]float32) []float32 {
+	out := make([]float32, w*h)
+	r := len(kernel) / 2
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var acc float32
+			for ky, row := range kernel {
+				sy := y + ky - r
+				if sy < 0 || sy >= h {
+					continue
+				}
+				for kx, k := range row {
+					sx := x + kx - r
+					if sx < 0 || sx >= w {
+						continue
+					}
+					acc += k * src[sy*w+sx]
+				}
+			}
+			out[y*w+x] = acc
+		}
+	}
+	return out
+}
+
+// suppressNonMax keeps pixels that are maximal along the gradient direction
+func suppressNonMax(mag, gx, gy []float32, w, h int) []float32 {
+	out := make([]float32, w*h)
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			angle := math.Atan2(float64(gy[i]), float64(gx[i])) * 180 / math.Pi
+			if angle < 0 {
+				angle += 180
+			}
+
+			var q, r float32
+			switch {
+			case angle < 22.5 || angle >= 157.5:
+				q, r = mag[i+1], mag[i-1]
+			case angle < 67.5:
+				q, r = mag[i-w+1], mag[i+w-1]
+			case angle < 112.5:
+				q, r = mag[i-w], mag[i+w]
+			default:
+				q, r = mag[i-w-1], mag[i+w+1]
+			}
+			if mag[i] >= q && mag[i] >= r {
+				out[i] = mag[i]
+			}
+		}
+	}
+	return out
+}
+
+// hysteresis classifies pixels as strong or weak, then keeps weak pixels only
+// when they touch a strong one
+func hysteresis(mag []float32, w, h int, opts CannyOptions) []float32 {
+	var peak float32
+	for _, v := range mag {
+		peak = max(peak, v)
+	}
+	high := peak * opts.HighThreshold
+	low := high * opts.LowThreshold
+
+	out := make([]float32, w*h)
+	for i, v := range mag {
+		switch {
+		case v >= high && v > 0:
+			out[i] = opts.Strong
+		case v >= low && v > 0:
+			out[i] = opts.Weak
+		}
+	}
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			if out[i] != opts.Weak || opts.Weak == opts.Strong {
+				continue
+			}
+			out[i] = 0
+			for _, n := range []int{i - w - 1, i - w, i - w + 1, i - 1, i + 1, i + w - 1, i + w, i + w + 1} {
+				if out[n] == opts.Strong {
+					out[i] = opts.Strong
+					break
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,186 @@
+package stablediffusion
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden images in testdata")
+
+const cannyGolden = "testdata/canny_golden.png"
+
+// cannyInput draws a filled disc and a rectangle over a horizontal gradient
+func cannyInput() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 96, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 96; x++ {
+			c := color.RGBA{uint8(x), uint8(x), uint8(x), 255}
+			if dx, dy := float64(x-30), float64(y-32); math.Hypot(dx, dy) < 18 {
+				c = color.RGBA{230, 200, 40, 255}
+			}
+			if x >= 58 && x < 86 && y >= 12 && y < 52 {
+				c = color.RGBA{240, 240, 255, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestCannyGoGolden(t *testing.T) {
+	got := CannyGo(cannyInput(), CannyOptions{})
+
+	if *updateGolden {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, got); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(cannyGolden), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(cannyGolden, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := decodeImageFile(cannyGolden)
+	if err != nil {
+		t.Fatalf("failed to read golden image (run with -update to create it): %v", err)
+	}
+	if diff := edgeDiff(got, want); diff != 0 {
+		t.Errorf("%d pixels differ from %s", diff, cannyGolden)
+	}
+}
+
+func TestCannyGoEdges(t *testing.T) {
+	out := CannyGo(cannyInput(), CannyOptions{})
+	// The disc boundary is an edge; the disc centre and the smooth gradient are not
+	if out.RGBAAt(12, 32).R != 255 && out.RGBAAt(13, 32).R != 255 {
+		t.Error("expected an edge on the disc boundary")
+	}
+	if out.RGBAAt(30, 32).R != 0 || out.RGBAAt(50, 5).R != 0 {
+		t.Error("flat regions should have no edges")
+	}
+
+	inv := CannyGo(cannyInput(), CannyOptions{Inverse: true})
+	for i := 0; i < len(out.Pix); i += 4 {
+		if out.Pix[i]+inv.Pix[i] != 255 {
+			t.Fatal("Inverse should invert the edge map")
+		}
+	}
+}
+
+func TestCannyDoesNotMutateInput(t *testing.T) {
+	in := cannyInput()
+	before := append([]uint8(nil), in.Pix...)
+
+	sd, _ := newFakeSD()
+	var native *SDImage
+	sd.preprocessCanny = func(img *SDImage, high, low, weak, strong float32, inverse bool) bool {
+		native = img
+		// Overwrite the buffer in place like the native implementation
+		data := unsafe.Slice(img.Data, img.Width*img.Height*img.Channel)
+		for i := range data {
+			data[i] = 255
+		}
+		if high != 0.08 || strong != 1 {
+			t.Errorf("defaults not applied: high %v strong %v", high, strong)
+		}
+		return true
+	}
+
+	out, err := sd.Canny(in, CannyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if native == nil || native.Channel != 3 {
+		t.Fatal("native preprocess_canny was not called with an RGB buffer")
+	}
+	if !bytes.Equal(in.Pix, before) {
+		t.Error("input image was modified")
+	}
+	if r, _, _, _ := out.At(5, 5).RGBA(); r != 0xffff {
+		t.Error("result should come from the native buffer")
+	}
+
+	sd.preprocessCanny = func(*SDImage, float32, float32, float32, float32, bool) bool { return false }
+	if _, err := sd.Canny(in, CannyOptions{}); err == nil {
+		t.Error("expected error when preprocess_canny fails")
+	}
+}
+
+func TestCannyFallback(t *testing.T) {
+	sd, _ := newFakeSD()
+	out, err := sd.Canny(cannyInput(), CannyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edgeDiff(out, CannyGo(cannyInput(), CannyOptions{})) != 0 {
+		t.Error("missing symbol should fall back to the Go implementation")
+	}
+	if _, err := sd.Canny(image.NewRGBA(image.Rect(0, 0, 0, 0)), CannyOptions{}); err == nil {
+		t.Error("expected error for an empty image")
+	}
+}
+
+// TestCannyNativeGolden compares the native detector with the golden image
+// when the library is available
+func TestCannyNativeGolden(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping native Canny comparison in short mode")
+	}
+	libPath := filepath.Join("native", LibraryName())
+	if _, err := os.Stat(libPath); os.IsNotExist(err) {
+		t.Skipf("Native library not found at %s, skipping native Canny comparison", libPath)
+	}
+	sd, err := New(LibraryConfig{LibPath: "native"})
+	if err != nil {
+		t.Fatalf("Failed to load library: %v", err)
+	}
+	defer sd.Close()
+	if sd.preprocessCanny == nil {
+		t.Skip("library does not export preprocess_canny")
+	}
+
+	got, err := sd.Canny(cannyInput(), CannyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := decodeImageFile(cannyGolden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The implementations differ in border handling and angle binning, so
+	// allow a small fraction of pixels to disagree
+	b := want.Bounds()
+	if diff := edgeDiff(got, want); diff > b.Dx()*b.Dy()/20 {
+		t.Errorf("native Canny differs from the golden image in %d pixels", diff)
+	}
+}
+
+// edgeDiff counts pixels whose red channel differs by more than 1
+func edgeDiff(a, b image.Image) int {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return a.Bounds().Dx() * a.Bounds().Dy()
+	}
+	diff := 0
+	ab, bb := a.Bounds(), b.Bounds()
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			ra, _, _, _ := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			rb, _, _, _ := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			if d := int(ra>>8) - int(rb>>8); d > 1 || d < -1 {
+				diff++
+			}
+		}
+	}
+	return diff
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/kawai-network/stablediffusion/modelinfo"
+)
+
+// maxMetadataValue truncates long metadata values in text output
+const maxMetadataValue = 120
+
+func runInfo(args []string) error {
+	fs := flag.NewFlagSet("info", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON instead of text")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no model files given")
+	}
+
+	for _, path := range fs.Args() {
+		info, err := modelinfo.Inspect(path)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			if err := printInfoJSON(os.Stdout, info); err != nil {
+				return err
+			}
+			continue
+		}
+		printInfo(os.Stdout, info)
+	}
+	return nil
+}
+
+func printInfo(w io.Writer, info *modelinfo.Info) {
+	fmt.Fprintf(w, "%s\n", info.Path)
+	fmt.Fprintf(w, "  format:     %s\n", info.Format)
+	fmt.Fprintf(w, "  detected:   %s\n", modelinfo.Classify(info).Type)
+	fmt.Fprintf(w, "  file size:  %s\n", formatBytes(info.FileSize))
+	fmt.Fprintf(w, "  tensors:    %d\n", info.TensorCount())
+	fmt.Fprintf(w, "  parameters: %s\n", formatCount(info.ParamCount()))
+
+	dtypes := info.DTypes()
+	fmt.Fprintf(w, "  dtypes:\n")
+	for _, name := range sortedKeys(dtypes) {
+		fmt.Fprintf(w, "    %-10s %d\n", name, dtypes[name])
+	}
+
+	if len(info.Metadata) > 0 {
+		fmt.Fprintf(w, "  metadata:\n")
+		for _, key := range sortedKeys(info.Metadata) {
+			value := fmt.Sprint(info.Metadata[key])
+			if len(value) > maxMetadataValue {
+				value = value[:maxMetadataValue] + "..."
+			}
+			fmt.Fprintf(w, "    %s: %s\n", key, value)
+		}
+	}
+}
+
+func printInfoJSON(w io.Writer, info *modelinfo.Info) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]any{
+		"path":       info.Path,
+		"format":     info.Format.String(),
+		"detected":   modelinfo.Classify(info).Type,
+		"file_size":  info.FileSize,
+		"tensors":    info.TensorCount(),
+		"parameters": info.ParamCount(),
+		"dtypes":     info.DTypes(),
+		"metadata":   info.Metadata,
+	})
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatCount(n uint64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.2fB", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.2fM", float64(n)/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.2fK", float64(n)/1e3)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
@@ -0,0 +1,115 @@
+package stablediffusion
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+)
+
+// defaultControlStrength is used when ControlNet.Strength is zero
+const defaultControlStrength = 0.9
+
+// ControlNet conditions generation on one or more control images.
+// stable-diffusion.cpp loads a single ControlNet model per context and takes
+// one control image per call, so each entry in Images is a separate generation.
+type ControlNet struct {
+	// ModelPath is the ControlNet model; the context must be created with it
+	ModelPath string
+	// KeepOnCPU keeps the ControlNet weights in system memory
+	KeepOnCPU bool
+	// Images are the control images, fitted to the target size with Fit
+	Images []image.Image
+	// Fit maps control images onto the target aspect ratio. Defaults to FitLetterbox.
+	Fit ImageFit
+	// Preprocess turns each image into a control map before fitting. This
+	// package cannot import preprocess, so select a preprocessor by name with
+	// preprocess.Lookup and assign the result, e.g.
+	//
+	//	cn.Preprocess, err = preprocess.Lookup("canny")
+	//
+	// Images are used as is when nil.
+	Preprocess func(image.Image) (image.Image, error)
+	// Strength is the control strength. Defaults to 0.9.
+	Strength float32
+}
+
+// ApplyContext sets the ControlNet model on context params before NewContext
+func (c *ControlNet) ApplyContext(params *SDContextParams) {
+	params.ControlNetPath = CString(c.ModelPath)
+	params.KeepControlNetOnCPU = c.KeepOnCPU
+}
+
+// Validate checks that ctx was created with this ControlNet
+func (c *ControlNet) Validate(ctx *SDContext) error {
+	if ctx == nil || ctx.ptr == nil {
+		return fmt.Errorf("SD context is not initialized")
+	}
+	if ctx.controlNetPath == "" {
+		return fmt.Errorf("SD context was created without a ControlNet model")
+	}
+	if c.ModelPath != "" && filepath.Clean(c.ModelPath) != filepath.Clean(ctx.controlNetPath) {
+		return fmt.Errorf("SD context was created with ControlNet %s, not %s", ctx.controlNetPath, c.ModelPath)
+	}
+	if c.KeepOnCPU != ctx.controlNetOnCPU {
+		return fmt.Errorf("SD context was created with KeepControlNetOnCPU=%v", ctx.controlNetOnCPU)
+	}
+	return nil
+}
+
+// ControlImage preprocesses and fits img to width x height
+func (c *ControlNet) ControlImage(img image.Image, width, height int) (image.Image, error) {
+	if img == nil {
+		return nil, fmt.Errorf("control image is nil")
+	}
+	if c.Preprocess != nil {
+		processed, err := c.Preprocess(img)
+		if err != nil {
+			return nil, fmt.Errorf("failed to preprocess control image: %w", err)
+		}
+		img = processed
+	}
+	return FitImage(img, width, height, c.Fit), nil
+}
+
+// GenerateControlNet validates ctx against cn and generates one image per
+// control image, sharing the rest of params
+func (ctx *SDContext) GenerateControlNet(params *SDImgGenParams, cn *ControlNet) ([]*image.RGBA, error) {
+	if ctx == nil || ctx.ptr == nil {
+		return nil, fmt.Errorf("SD context is not initialized")
+	}
+	if params == nil {
+		return nil, fmt.Errorf("image generation params are nil")
+	}
+	if cn == nil || len(cn.Images) == 0 {
+		return nil, fmt.Errorf("no control images")
+	}
+	if params.Width <= 0 || params.Height <= 0 {
+		return nil, fmt.Errorf("invalid target size %dx%d", params.Width, params.Height)
+	}
+	if err := cn.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	strength := cn.Strength
+	if strength == 0 {
+		strength = defaultControlStrength
+	}
+
+	results := make([]*image.RGBA, 0, len(cn.Images))
+	for i, img := range cn.Images {
+		control, err := cn.ControlImage(img, int(params.Width), int(params.Height))
+		if err != nil {
+			return nil, fmt.Errorf("control image %d: %w", i, err)
+		}
+
+		p := *params
+		p.ControlImage = ImageToSDImage(control)
+		p.ControlStrength = strength
+		out, err := ctx.Generate(&p)
+		if err != nil {
+			return nil, fmt.Errorf("control image %d: %w", i, err)
+		}
+		results = append(results, out)
+	}
+	return results, nil
+}
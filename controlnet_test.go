@@ -0,0 +1,151 @@
+package stablediffusion
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestFitImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for i := range src.Pix {
+		src.Pix[i] = 200
+	}
+
+	letterbox := FitImage(src, 40, 40, FitLetterbox)
+	if letterbox.Bounds().Dx() != 40 || letterbox.Bounds().Dy() != 40 {
+		t.Fatalf("unexpected size %v", letterbox.Bounds())
+	}
+	if c := letterbox.RGBAAt(20, 2); c.R != 0 {
+		t.Errorf("letterbox should pad with black, got %v", c)
+	}
+	if c := letterbox.RGBAAt(20, 20); c.R < 190 {
+		t.Errorf("letterbox lost the image center, got %v", c)
+	}
+
+	crop := FitImage(src, 40, 40, FitCrop)
+	if c := crop.RGBAAt(20, 2); c.R < 190 {
+		t.Errorf("crop should cover the target, got %v", c)
+	}
+
+	stretch := FitImage(src, 10, 30, FitStretch)
+	if stretch.Bounds().Dx() != 10 || stretch.Bounds().Dy() != 30 {
+		t.Errorf("unexpected stretch size %v", stretch.Bounds())
+	}
+}
+
+func TestControlNetValidate(t *testing.T) {
+	sd, _ := newFakeSD()
+	ctx := newFakeContext(sd)
+
+	cn := &ControlNet{ModelPath: "models/control_canny.safetensors"}
+	if err := cn.Validate(ctx); err == nil {
+		t.Error("expected error for context without ControlNet")
+	}
+
+	ctx.controlNetPath = "models/control_depth.safetensors"
+	if err := cn.Validate(ctx); err == nil {
+		t.Error("expected error for mismatched ControlNet model")
+	}
+
+	ctx.controlNetPath = "models/./control_canny.safetensors"
+	if err := cn.Validate(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cn.KeepOnCPU = true
+	if err := cn.Validate(ctx); err == nil || !strings.Contains(err.Error(), "KeepControlNetOnCPU") {
+		t.Errorf("expected KeepControlNetOnCPU mismatch, got %v", err)
+	}
+
+	var nilCtx *SDContext
+	if err := cn.Validate(nilCtx); err == nil {
+		t.Error("expected error for nil context")
+	}
+}
+
+func TestGenerateControlNet(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx := newFakeContext(sd)
+	ctx.controlNetPath = "control.safetensors"
+
+	wide := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	tall := image.NewGray(image.Rect(0, 0, 10, 40))
+	preprocessed := 0
+	cn := &ControlNet{
+		Images: []image.Image{wide, tall},
+		Preprocess: func(img image.Image) (image.Image, error) {
+			preprocessed++
+			out := image.NewGray(img.Bounds())
+			for i := range out.Pix {
+				out.Pix[i] = 255
+			}
+			return out, nil
+		},
+	}
+
+	params := &SDImgGenParams{Width: 64, Height: 32}
+	images, err := ctx.GenerateControlNet(params, cn)
+	if err != nil {
+		t.Fatalf("GenerateControlNet failed: %v", err)
+	}
+	if len(images) != 2 || len(fake.imgCalls) != 2 || preprocessed != 2 {
+		t.Fatalf("expected 2 generations, got %d images, %d calls, %d preprocessed", len(images), len(fake.imgCalls), preprocessed)
+	}
+	for i, call := range fake.imgCalls {
+		if call.ControlImage.Width != 64 || call.ControlImage.Height != 32 || call.ControlImage.Channel != 3 {
+			t.Errorf("call %d: control image not fitted to target, got %dx%dx%d", i, call.ControlImage.Width, call.ControlImage.Height, call.ControlImage.Channel)
+		}
+		if call.ControlStrength != defaultControlStrength {
+			t.Errorf("call %d: expected default strength, got %v", i, call.ControlStrength)
+		}
+	}
+	if params.ControlImage.Data != nil {
+		t.Error("GenerateControlNet should not modify params")
+	}
+
+	// The tall image is letterboxed so its left edge is padding
+	control, err := SDImageToImage(&fake.imgCalls[1].ControlImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := control.At(0, 16).(color.RGBA); c.R != 0 {
+		t.Errorf("expected letterbox padding, got %v", c)
+	}
+}
+
+func TestGenerateControlNetErrors(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx := newFakeContext(sd)
+	params := &SDImgGenParams{Width: 64, Height: 64}
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+
+	if _, err := ctx.GenerateControlNet(params, &ControlNet{Images: []image.Image{img}}); err == nil {
+		t.Error("expected error for context without ControlNet")
+	}
+
+	ctx.controlNetPath = "control.safetensors"
+	if _, err := ctx.GenerateControlNet(params, &ControlNet{}); err == nil {
+		t.Error("expected error without control images")
+	}
+	if _, err := ctx.GenerateControlNet(&SDImgGenParams{}, &ControlNet{Images: []image.Image{img}}); err == nil {
+		t.Error("expected error for missing target size")
+	}
+
+	failing := &ControlNet{
+		Images:     []image.Image{img},
+		Preprocess: func(image.Image) (image.Image, error) { return nil, errors.New("boom") },
+	}
+	if _, err := ctx.GenerateControlNet(params, failing); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected preprocess error, got %v", err)
+	}
+	var nilCtx *SDContext
+	if _, err := nilCtx.GenerateControlNet(params, failing); err == nil {
+		t.Error("expected error for nil context")
+	}
+	if len(fake.imgCalls) != 0 {
+		t.Errorf("expected no generations, got %d", len(fake.imgCalls))
+	}
+}
@@ -0,0 +1,185 @@
+package stablediffusion
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ConvertOptions configures a model conversion
+type ConvertOptions struct {
+	InputPath       string
+	VAEPath         string
+	OutputPath      string
+	OutputType      SDType
+	TensorTypeRules string
+	ConvertName     bool
+	// Progress is called by ConvertDir after each conversion with the number done
+	Progress func(done, total int)
+}
+
+// convertibleExts lists the model formats ConvertDir picks up
+var convertibleExts = []string{".safetensors", ".ckpt", ".pt", ".pth", ".gguf"}
+
+// Convert converts a model to GGUF with the requested quantization.
+// The output is written to a temporary file next to OutputPath and renamed
+// into place only on success, so a failed conversion never leaves a partial file.
+func (sd *StableDiffusion) Convert(opts ConvertOptions) error {
+	if err := validateConvertOptions(opts); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(opts.OutputPath)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(opts.OutputPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary output: %w", err)
+	}
+	tmpPath := tmp.Name()
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to create temporary output: %w", err)
+	}
+	defer os.Remove(tmpPath)
+
+	cInputPath := CString(opts.InputPath)
+	cVaePath := CString(opts.VAEPath)
+	cOutputPath := CString(tmpPath)
+	cTensorTypeRules := CString(opts.TensorTypeRules)
+
+	defer func() {
+		FreeCString(cInputPath)
+		FreeCString(cVaePath)
+		FreeCString(cOutputPath)
+		FreeCString(cTensorTypeRules)
+	}()
+
+	// Native logging is global, so conversions on one instance run one at a time
+	sd.convertMu.Lock()
+	var ok bool
+	lines := sd.captureLogs(func() {
+		ok = sd.convert(cInputPath, cVaePath, cOutputPath, opts.OutputType, cTensorTypeRules, opts.ConvertName)
+	})
+	sd.convertMu.Unlock()
+
+	if !ok {
+		if len(lines) > 0 {
+			return fmt.Errorf("convert %s failed: %s", opts.InputPath, strings.Join(lines, "; "))
+		}
+		return fmt.Errorf("convert %s failed", opts.InputPath)
+	}
+
+	if err := os.Rename(tmpPath, opts.OutputPath); err != nil {
+		return fmt.Errorf("failed to move output into place: %w", err)
+	}
+	return nil
+}
+
+func validateConvertOptions(opts ConvertOptions) error {
+	if opts.InputPath == "" {
+		return fmt.Errorf("input path is required")
+	}
+	if opts.OutputPath == "" {
+		return fmt.Errorf("output path is required")
+	}
+	if !opts.OutputType.Valid() {
+		return fmt.Errorf("invalid output type %v", opts.OutputType)
+	}
+
+	info, err := os.Stat(opts.InputPath)
+	if err != nil {
+		return fmt.Errorf("invalid input path: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("input path %s is a directory", opts.InputPath)
+	}
+	if opts.VAEPath != "" {
+		if _, err := os.Stat(opts.VAEPath); err != nil {
+			return fmt.Errorf("invalid VAE path: %w", err)
+		}
+	}
+
+	if dir, err := os.Stat(filepath.Dir(opts.OutputPath)); err != nil {
+		return fmt.Errorf("invalid output directory: %w", err)
+	} else if !dir.IsDir() {
+		return fmt.Errorf("output directory %s is not a directory", filepath.Dir(opts.OutputPath))
+	}
+
+	inAbs, err := filepath.Abs(opts.InputPath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	outAbs, err := filepath.Abs(opts.OutputPath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if inAbs == outAbs {
+		return fmt.Errorf("output path must differ from input path")
+	}
+	return nil
+}
+
+// ConvertResult reports the outcome of one conversion in a batch
+type ConvertResult struct {
+	InputPath  string
+	OutputPath string
+	OutputType SDType
+	Err        error
+}
+
+// ConvertDir converts every model file in inputDir to each of types.
+// Outputs are named <model>-<type>.gguf in outputDir. VAEPath, TensorTypeRules
+// and ConvertName are taken from opts. All conversions are attempted; the
+// returned error joins the individual failures.
+func (sd *StableDiffusion) ConvertDir(inputDir, outputDir string, types []SDType, opts ConvertOptions) ([]ConvertResult, error) {
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no output types requested")
+	}
+
+	entries, err := os.ReadDir(inputDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input directory: %w", err)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	var inputs []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && slices.Contains(convertibleExts, ext) {
+			inputs = append(inputs, entry.Name())
+		}
+	}
+
+	total := len(inputs) * len(types)
+	var results []ConvertResult
+	var errs []error
+	for _, name := range inputs {
+		base := strings.TrimSuffix(name, filepath.Ext(name))
+		for _, typ := range types {
+			job := opts
+			job.InputPath = filepath.Join(inputDir, name)
+			job.OutputPath = filepath.Join(outputDir, fmt.Sprintf("%s-%s.gguf", base, typ))
+			job.OutputType = typ
+
+			err := sd.Convert(job)
+			results = append(results, ConvertResult{
+				InputPath:  job.InputPath,
+				OutputPath: job.OutputPath,
+				OutputType: typ,
+				Err:        err,
+			})
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if opts.Progress != nil {
+				opts.Progress(len(results), total)
+			}
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,130 @@
+package stablediffusion
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	sd, fake := newFakeSD()
+	dir := t.TempDir()
+	in := filepath.Join(dir, "model.safetensors")
+	out := filepath.Join(dir, "model-q8_0.gguf")
+	if err := os.WriteFile(in, []byte("weights"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sd.Convert(ConvertOptions{InputPath: in, OutputPath: out, OutputType: SDTypeQ8_0}); err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil || string(data) != "q8_0" {
+		t.Errorf("unexpected output %q, %v", data, err)
+	}
+	if len(fake.convertedTo) != 1 || fake.convertedTo[0] == out {
+		t.Error("convert should write to a temporary path first")
+	}
+	if _, err := os.Stat(fake.convertedTo[0]); !os.IsNotExist(err) {
+		t.Error("temporary file was not moved into place")
+	}
+}
+
+func TestConvertFailureReason(t *testing.T) {
+	sd, fake := newFakeSD()
+	dir := t.TempDir()
+	in := filepath.Join(dir, "model.ckpt")
+	out := filepath.Join(dir, "out.gguf")
+	if err := os.WriteFile(in, []byte("weights"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.convertErr = "unsupported tensor layout"
+	err := sd.Convert(ConvertOptions{InputPath: in, OutputPath: out, OutputType: SDTypeF16})
+	if err == nil || !strings.Contains(err.Error(), "unsupported tensor layout") {
+		t.Fatalf("expected native log line in error, got %v", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Error("failed conversion must not create the output")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temporary files left behind: %d entries", len(entries))
+	}
+}
+
+func TestConvertValidation(t *testing.T) {
+	sd, _ := newFakeSD()
+	dir := t.TempDir()
+	in := filepath.Join(dir, "model.safetensors")
+	if err := os.WriteFile(in, []byte("weights"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []ConvertOptions{
+		{OutputPath: filepath.Join(dir, "out.gguf")},
+		{InputPath: in},
+		{InputPath: filepath.Join(dir, "missing"), OutputPath: filepath.Join(dir, "out.gguf")},
+		{InputPath: in, OutputPath: filepath.Join(dir, "out.gguf"), OutputType: SDType(5)},
+		{InputPath: in, OutputPath: filepath.Join(dir, "missing", "out.gguf")},
+		{InputPath: in, OutputPath: in},
+		{InputPath: in, VAEPath: filepath.Join(dir, "missing.vae"), OutputPath: filepath.Join(dir, "out.gguf")},
+	}
+
+	for i, opts := range tests {
+		if err := sd.Convert(opts); err == nil {
+			t.Errorf("case %d: expected validation error", i)
+		}
+	}
+}
+
+func TestConvertDir(t *testing.T) {
+	sd, _ := newFakeSD()
+	in := t.TempDir()
+	out := filepath.Join(t.TempDir(), "converted")
+	for _, name := range []string{"a.safetensors", "b.ckpt", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(in, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var progress []int
+	opts := ConvertOptions{Progress: func(done, total int) {
+		if total != 4 {
+			t.Errorf("expected total 4, got %d", total)
+		}
+		progress = append(progress, done)
+	}}
+	results, err := sd.ConvertDir(in, out, []SDType{SDTypeQ4_0, SDTypeQ8_0}, opts)
+	if err != nil {
+		t.Fatalf("ConvertDir failed: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 conversions, got %d", len(results))
+	}
+	if !slices.Equal(progress, []int{1, 2, 3, 4}) {
+		t.Errorf("unexpected progress %v", progress)
+	}
+	for _, name := range []string{"a-q4_0.gguf", "a-q8_0.gguf", "b-q4_0.gguf", "b-q8_0.gguf"} {
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Errorf("missing output %s", name)
+		}
+	}
+}
+
+func TestSDTypeNames(t *testing.T) {
+	if SDType(SDTypeQ4_K).String() != "q4_K" {
+		t.Errorf("unexpected name %s", SDType(SDTypeQ4_K))
+	}
+	typ, err := ParseSDType("Q4_k")
+	if err != nil || typ != SDTypeQ4_K {
+		t.Errorf("ParseSDType returned %v, %v", typ, err)
+	}
+	if SDType(5).Valid() {
+		t.Error("SDType(5) should not be valid")
+	}
+}
@@ -0,0 +1,422 @@
+// Package downloader fetches model files over HTTP(S) with resume, parallel
+// chunks and checksum verification.
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kawai-network/stablediffusion/registry"
+)
+
+// Defaults used when the Downloader fields are zero
+const (
+	DefaultChunkSize = 64 << 20
+	DefaultParallel  = 4
+)
+
+// partSuffix and stateSuffix name the in-progress file and its chunk state
+const (
+	partSuffix  = ".part"
+	stateSuffix = ".part.json"
+)
+
+// Progress reports the state of a running download
+type Progress struct {
+	URL        string
+	Path       string
+	Downloaded int64
+	// Total is -1 when the server did not report a size
+	Total int64
+}
+
+// Request describes one file to fetch
+type Request struct {
+	URL  string
+	Dest string
+	// SHA256 is verified after download when set
+	SHA256 string
+	// Size is used for the disk space check when the server does not report one
+	Size int64
+}
+
+// Downloader fetches files; the zero value is ready to use
+type Downloader struct {
+	Client *http.Client
+	// Parallel is the number of concurrent range requests per file
+	Parallel int
+	// ChunkSize is the size of each range request in parallel mode
+	ChunkSize int64
+	// Progress is called as bytes arrive; it may be called from several goroutines
+	Progress func(Progress)
+	// SkipSpaceCheck disables the free disk space precheck
+	SkipSpaceCheck bool
+}
+
+func (d *Downloader) client() *http.Client {
+	if d.Client != nil {
+		return d.Client
+	}
+	return http.DefaultClient
+}
+
+// Download fetches req.URL into req.Dest. An interrupted download leaves a
+// .part file that the next call resumes. The destination is only written
+// once the data is complete and its checksum matches.
+func (d *Downloader) Download(ctx context.Context, req Request) error {
+	if req.URL == "" || req.Dest == "" {
+		return fmt.Errorf("url and destination are required")
+	}
+	if err := os.MkdirAll(filepath.Dir(req.Dest), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	size, ranges, err := d.probe(ctx, req.URL)
+	if err != nil {
+		return err
+	}
+	if size < 0 && req.Size > 0 {
+		size = req.Size
+	}
+
+	part := req.Dest + partSuffix
+	if !d.SkipSpaceCheck && size > 0 {
+		have := int64(0)
+		if info, err := os.Stat(part); err == nil {
+			have = info.Size()
+		}
+		if err := checkDiskSpace(filepath.Dir(req.Dest), size-have); err != nil {
+			return err
+		}
+	}
+
+	parallel := d.Parallel
+	if parallel <= 0 {
+		parallel = DefaultParallel
+	}
+	chunkSize := d.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	if ranges && size > chunkSize && parallel > 1 {
+		err = d.fetchChunks(ctx, req.URL, part, size, chunkSize, parallel)
+	} else {
+		err = d.fetchSequential(ctx, req.URL, part, size)
+	}
+	if err != nil {
+		return err
+	}
+
+	if req.SHA256 != "" {
+		sum, err := registry.FileSHA256(part)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(sum, req.SHA256) {
+			// Drop the chunk state too, or a retry would trust the bad data as complete
+			os.Remove(part)
+			os.Remove(req.Dest + stateSuffix)
+			return fmt.Errorf("checksum mismatch for %s: got %s, want %s", req.URL, sum, req.SHA256)
+		}
+	}
+
+	if err := os.Rename(part, req.Dest); err != nil {
+		return fmt.Errorf("failed to move download into place: %w", err)
+	}
+	os.Remove(req.Dest + stateSuffix)
+	return nil
+}
+
+// probe asks the server for the file size and range support
+func (d *Downloader) probe(ctx context.Context, url string) (int64, bool, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	resp, err := d.client().Do(httpReq)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to reach %s: %w", url, err)
+	}
+	resp.Body.Close()
+
+	// Some servers reject HEAD; fall back to a plain sequential fetch
+	if resp.StatusCode != http.StatusOK {
+		return -1, false, nil
+	}
+	return resp.ContentLength, resp.Header.Get("Accept-Ranges") == "bytes", nil
+}
+
+// fetchSequential streams the file, resuming from an existing part file with a Range request
+func (d *Downloader) fetchSequential(ctx context.Context, url, part string, size int64) error {
+	// A part file with chunk state is preallocated and sparse, so its size says nothing
+	// about how much was downloaded; restart
+	statePath := strings.TrimSuffix(part, partSuffix) + stateSuffix
+	if _, err := os.Stat(statePath); err == nil {
+		os.Remove(part)
+		os.Remove(statePath)
+	}
+
+	offset := int64(0)
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+	if size > 0 && offset == size {
+		return nil
+	}
+	if size > 0 && offset > size {
+		offset = 0
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		httpReq.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := d.client().Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// Server ignored the range; start over
+		offset = 0
+		flags |= os.O_TRUNC
+	default:
+		return fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+
+	total := size
+	if total <= 0 && resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+
+	file, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", part, err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close file: %v", err)
+		}
+	}()
+
+	var done atomic.Int64
+	done.Store(offset)
+	w := &progressWriter{w: file, done: &done, report: func(n int64) {
+		d.report(url, part, n, total)
+	}}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("download of %s interrupted: %w", url, err)
+	}
+	if total > 0 && done.Load() != total {
+		return fmt.Errorf("download of %s incomplete: %d of %d bytes", url, done.Load(), total)
+	}
+	return nil
+}
+
+// chunkState records which chunks of a parallel download are complete
+type chunkState struct {
+	Size      int64  `json:"size"`
+	ChunkSize int64  `json:"chunk_size"`
+	Done      []bool `json:"done"`
+}
+
+// fetchChunks downloads fixed-size ranges concurrently into a preallocated part file
+func (d *Downloader) fetchChunks(ctx context.Context, url, part string, size, chunkSize int64, parallel int) error {
+	statePath := strings.TrimSuffix(part, partSuffix) + stateSuffix
+	chunks := int((size + chunkSize - 1) / chunkSize)
+
+	state := chunkState{Size: size, ChunkSize: chunkSize, Done: make([]bool, chunks)}
+	info, partErr := os.Stat(part)
+	if data, err := os.ReadFile(statePath); err == nil {
+		// The state only describes the part file it was saved with
+		var saved chunkState
+		if json.Unmarshal(data, &saved) == nil && saved.Size == size && saved.ChunkSize == chunkSize && len(saved.Done) == chunks &&
+			partErr == nil && info.Size() == size {
+			state = saved
+		}
+	} else if partErr == nil {
+		// A part file without chunk state came from a sequential attempt; restart
+		os.Remove(part)
+	}
+
+	file, err := os.OpenFile(part, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", part, err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close file: %v", err)
+		}
+	}()
+	if err := file.Truncate(size); err != nil {
+		return fmt.Errorf("failed to allocate %s: %w", part, err)
+	}
+
+	var done atomic.Int64
+	for i, ok := range state.Done {
+		if ok {
+			done.Add(chunkLen(i, size, chunkSize))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+		jobs     = make(chan int)
+	)
+	saveState := func(i int) {
+		mu.Lock()
+		defer mu.Unlock()
+		state.Done[i] = true
+		if data, err := json.Marshal(state); err == nil {
+			os.WriteFile(statePath, data, 0644)
+		}
+	}
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mu.Unlock()
+		cancel()
+	}
+
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := d.fetchRange(ctx, url, file, int64(i)*chunkSize, chunkLen(i, size, chunkSize), &done, part, size); err != nil {
+					fail(err)
+					return
+				}
+				saveState(i)
+			}
+		}()
+	}
+
+feed:
+	for i, ok := range state.Done {
+		if ok {
+			continue
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// fetchRange downloads length bytes at offset into file
+func (d *Downloader) fetchRange(ctx context.Context, url string, file *os.File, offset, length int64, done *atomic.Int64, part string, total int64) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-"+strconv.FormatInt(offset+length-1, 10))
+
+	resp, err := d.client().Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("range request for %s returned %s", url, resp.Status)
+	}
+
+	w := &progressWriter{
+		w:    io.NewOffsetWriter(file, offset),
+		done: done,
+		report: func(n int64) {
+			d.report(url, part, n, total)
+		},
+	}
+	n, err := io.Copy(w, io.LimitReader(resp.Body, length))
+	if err != nil {
+		return fmt.Errorf("download of %s interrupted: %w", url, err)
+	}
+	if n != length {
+		return fmt.Errorf("short range response for %s: %d of %d bytes", url, n, length)
+	}
+	return nil
+}
+
+func chunkLen(i int, size, chunkSize int64) int64 {
+	return min(chunkSize, size-int64(i)*chunkSize)
+}
+
+func (d *Downloader) report(url, path string, downloaded, total int64) {
+	if d.Progress == nil {
+		return
+	}
+	if total <= 0 {
+		total = -1
+	}
+	d.Progress(Progress{URL: url, Path: strings.TrimSuffix(path, partSuffix), Downloaded: downloaded, Total: total})
+}
+
+// progressWriter counts bytes written and reports the running total
+type progressWriter struct {
+	w      io.Writer
+	done   *atomic.Int64
+	report func(int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.report(p.done.Add(int64(n)))
+	return n, err
+}
+
+// ErrInsufficientSpace is returned when the destination lacks room for the download
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+func checkDiskSpace(dir string, need int64) error {
+	if need <= 0 {
+		return nil
+	}
+	free, err := freeSpace(dir)
+	if err != nil {
+		// Not all filesystems report free space; let the write fail naturally instead
+		return nil
+	}
+	if free < uint64(need) {
+		return fmt.Errorf("%w: need %d bytes in %s, have %d", ErrInsufficientSpace, need, dir, free)
+	}
+	return nil
+}
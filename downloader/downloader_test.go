@@ -0,0 +1,293 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kawai-network/stablediffusion/registry"
+)
+
+func testPayload(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// serveBytes serves data with Range support and counts GET requests
+func serveBytes(data []byte, gets *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && gets != nil {
+			gets.Add(1)
+		}
+		http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(data))
+	})
+}
+
+func TestDownloadSequential(t *testing.T) {
+	data := testPayload(1000)
+	srv := httptest.NewServer(serveBytes(data, nil))
+	defer srv.Close()
+
+	var last Progress
+	d := &Downloader{Progress: func(p Progress) { last = p }}
+	dest := filepath.Join(t.TempDir(), "sub", "model.bin")
+	if err := d.Download(context.Background(), Request{URL: srv.URL, Dest: dest, SHA256: digest(data)}); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, data) {
+		t.Error("downloaded data differs")
+	}
+	if last.Downloaded != 1000 || last.Total != 1000 || last.Path != dest {
+		t.Errorf("unexpected final progress %+v", last)
+	}
+	if _, err := os.Stat(dest + partSuffix); !os.IsNotExist(err) {
+		t.Error("part file left behind")
+	}
+}
+
+func TestDownloadParallelChunks(t *testing.T) {
+	data := testPayload(10_000)
+	var gets atomic.Int32
+	srv := httptest.NewServer(serveBytes(data, &gets))
+	defer srv.Close()
+
+	d := &Downloader{Parallel: 3, ChunkSize: 1024}
+	dest := filepath.Join(t.TempDir(), "model.bin")
+	if err := d.Download(context.Background(), Request{URL: srv.URL, Dest: dest, SHA256: digest(data)}); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, data) {
+		t.Error("downloaded data differs")
+	}
+	if gets.Load() != 10 {
+		t.Errorf("expected 10 range requests, got %d", gets.Load())
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	data := testPayload(5000)
+	var sawRange atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			sawRange.Store(r.Header.Get("Range"))
+		}
+		http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "model.bin")
+	if err := os.WriteFile(dest+partSuffix, data[:2000], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Downloader{Parallel: 1}
+	if err := d.Download(context.Background(), Request{URL: srv.URL, Dest: dest, SHA256: digest(data)}); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if sawRange.Load() != "bytes=2000-" {
+		t.Errorf("expected resume range, got %q", sawRange.Load())
+	}
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, data) {
+		t.Error("resumed data differs")
+	}
+}
+
+func TestDownloadInterruptedThenResumed(t *testing.T) {
+	data := testPayload(4000)
+	var broken atomic.Bool
+	broken.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && broken.Load() && r.Header.Get("Range") == "" {
+			// Promise the full body but drop the connection halfway
+			w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(data[:1500])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "model.bin")
+	d := &Downloader{Parallel: 1}
+	if err := d.Download(context.Background(), Request{URL: srv.URL, Dest: dest}); err == nil {
+		t.Fatal("expected interrupted download to fail")
+	}
+	if info, err := os.Stat(dest + partSuffix); err != nil || info.Size() == 0 {
+		t.Fatal("partial data was not kept for resume")
+	}
+
+	broken.Store(false)
+	if err := d.Download(context.Background(), Request{URL: srv.URL, Dest: dest, SHA256: digest(data)}); err != nil {
+		t.Fatalf("resumed Download failed: %v", err)
+	}
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, data) {
+		t.Error("resumed data differs")
+	}
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	data := testPayload(100)
+	srv := httptest.NewServer(serveBytes(data, nil))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "model.bin")
+	err := (&Downloader{}).Download(context.Background(), Request{URL: srv.URL, Dest: dest, SHA256: strings.Repeat("0", 64)})
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	for _, path := range []string{dest, dest + partSuffix} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should not exist after a failed verification", path)
+		}
+	}
+}
+
+func TestDownloadChecksumMismatchThenRetry(t *testing.T) {
+	data := testPayload(10_000)
+	var gets atomic.Int32
+	srv := httptest.NewServer(serveBytes(data, &gets))
+	defer srv.Close()
+
+	d := &Downloader{Parallel: 3, ChunkSize: 1024}
+	dest := filepath.Join(t.TempDir(), "model.bin")
+	if err := d.Download(context.Background(), Request{URL: srv.URL, Dest: dest, SHA256: strings.Repeat("0", 64)}); err == nil {
+		t.Fatal("expected checksum mismatch")
+	}
+	if _, err := os.Stat(dest + stateSuffix); !os.IsNotExist(err) {
+		t.Error("chunk state left behind after a failed verification")
+	}
+
+	gets.Store(0)
+	if err := d.Download(context.Background(), Request{URL: srv.URL, Dest: dest, SHA256: digest(data)}); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if gets.Load() != 10 {
+		t.Errorf("expected the retry to fetch all 10 chunks, got %d requests", gets.Load())
+	}
+}
+
+func TestDownloadIgnoresStateWithoutPartFile(t *testing.T) {
+	data := testPayload(10_000)
+	srv := httptest.NewServer(serveBytes(data, nil))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "model.bin")
+	state := `{"size":10000,"chunk_size":1024,"done":[true,true,true,true,true,true,true,true,true,true]}`
+	if err := os.WriteFile(dest+stateSuffix, []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Downloader{Parallel: 3, ChunkSize: 1024}
+	if err := d.Download(context.Background(), Request{URL: srv.URL, Dest: dest, SHA256: digest(data)}); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+}
+
+func TestDownloadSequentialAfterChunks(t *testing.T) {
+	data := testPayload(10_000)
+	srv := httptest.NewServer(serveBytes(data, nil))
+	defer srv.Close()
+
+	// A preallocated chunked part file has the full size but holds zeros
+	dest := filepath.Join(t.TempDir(), "model.bin")
+	if err := os.WriteFile(dest+partSuffix, make([]byte, len(data)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state := `{"size":10000,"chunk_size":1024,"done":[true,false,false,false,false,false,false,false,false,false]}`
+	if err := os.WriteFile(dest+stateSuffix, []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Downloader{Parallel: 1}
+	if err := d.Download(context.Background(), Request{URL: srv.URL, Dest: dest, SHA256: digest(data)}); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if _, err := os.Stat(dest + stateSuffix); !os.IsNotExist(err) {
+		t.Error("chunk state left behind")
+	}
+}
+
+func TestDownloadHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	err := (&Downloader{}).Download(context.Background(), Request{URL: srv.URL, Dest: filepath.Join(t.TempDir(), "x")})
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected 404 error, got %v", err)
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	if err := checkDiskSpace(t.TempDir(), 1); err != nil {
+		t.Errorf("unexpected error for 1 byte: %v", err)
+	}
+	if err := checkDiskSpace(t.TempDir(), 1<<62); !errors.Is(err, ErrInsufficientSpace) {
+		t.Errorf("expected ErrInsufficientSpace, got %v", err)
+	}
+}
+
+func TestFetchModel(t *testing.T) {
+	data := testPayload(3000)
+	var gets atomic.Int32
+	srv := httptest.NewServer(serveBytes(data, &gets))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	manifest := fmt.Sprintf(`{"models":[{"name":"tiny","kind":"diffusion",
+		"paths":{"model":"tiny.safetensors"},
+		"files":[{"path":"tiny.safetensors","url":%q,"sha256":%q}]}]}`, srv.URL, digest(data))
+	path := filepath.Join(dir, "models.json")
+	if err := os.WriteFile(path, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := registry.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Downloader{}
+	if err := d.FetchModel(context.Background(), r, "tiny"); err != nil {
+		t.Fatalf("FetchModel failed: %v", err)
+	}
+	if err := r.Verify("tiny"); err != nil {
+		t.Errorf("Verify failed after fetch: %v", err)
+	}
+
+	// A second fetch finds the verified file and does not download again
+	before := gets.Load()
+	if err := d.FetchModel(context.Background(), r, "tiny"); err != nil {
+		t.Fatalf("second FetchModel failed: %v", err)
+	}
+	if gets.Load() != before {
+		t.Error("verified file was downloaded again")
+	}
+}
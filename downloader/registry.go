@@ -0,0 +1,48 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kawai-network/stablediffusion/registry"
+)
+
+// FetchModel downloads every file of the named registry model that has a URL.
+// Files already on disk with a matching SHA-256 are skipped, so a node can be
+// bootstrapped by calling FetchModel for each name in its manifest.
+func (d *Downloader) FetchModel(ctx context.Context, r *registry.Registry, name string) error {
+	m, err := r.Get(name)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range m.Files {
+		dest := r.Abs(f.Path)
+		if _, err := os.Stat(dest); err == nil {
+			if f.SHA256 == "" {
+				continue
+			}
+			sum, err := r.Hash(dest)
+			if err != nil {
+				return fmt.Errorf("model %q: %w", name, err)
+			}
+			if strings.EqualFold(sum, f.SHA256) {
+				continue
+			}
+			// A stale or corrupt copy is replaced
+			if err := os.Remove(dest); err != nil {
+				return fmt.Errorf("model %q: %w", name, err)
+			}
+		}
+		if f.URL == "" {
+			return fmt.Errorf("model %q: %s is missing and has no url", name, f.Path)
+		}
+
+		if err := d.Download(ctx, Request{URL: f.URL, Dest: dest, SHA256: f.SHA256, Size: f.Size}); err != nil {
+			return fmt.Errorf("model %q: %w", name, err)
+		}
+	}
+	return nil
+}
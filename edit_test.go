@@ -0,0 +1,130 @@
+package stablediffusion
+
+import (
+	"image"
+	"testing"
+	"unsafe"
+)
+
+func TestEdit(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx := newFakeContext(sd)
+
+	first := image.NewRGBA(image.Rect(0, 0, 100, 70))
+	second := image.NewRGBA(image.Rect(0, 0, 30, 30))
+	base := &SDImgGenParams{Seed: 42, Strength: 0.75}
+
+	out, err := ctx.Edit(EditRequest{
+		Instruction:      "make it purple",
+		References:       []image.Image{first, second},
+		IncreaseRefIndex: true,
+		Params:           base,
+	})
+	if err != nil {
+		t.Fatalf("Edit failed: %v", err)
+	}
+	if len(fake.imgCalls) != 1 {
+		t.Fatalf("expected 1 call, got %d", len(fake.imgCalls))
+	}
+
+	call := fake.imgCalls[0]
+	if call.Width != 96 || call.Height != 64 {
+		t.Errorf("expected 96x64 output, got %dx%d", call.Width, call.Height)
+	}
+	if out.Bounds().Dx() != 96 || out.Bounds().Dy() != 64 {
+		t.Errorf("unexpected result size %v", out.Bounds())
+	}
+	if CGoString(call.Prompt) != "make it purple" || call.Seed != 42 || call.Strength != 0.75 {
+		t.Errorf("unexpected params: prompt %q seed %d strength %v", CGoString(call.Prompt), call.Seed, call.Strength)
+	}
+	if call.RefImagesCount != 2 || !call.IncreaseRefIndex || call.AutoResizeRefImage {
+		t.Fatalf("unexpected reference params: count %d increase %v auto %v", call.RefImagesCount, call.IncreaseRefIndex, call.AutoResizeRefImage)
+	}
+
+	refs := unsafe.Slice(call.RefImages, call.RefImagesCount)
+	want := [][2]uint32{{96, 64}, {32, 32}}
+	for i, ref := range refs {
+		if ref.Width != want[i][0] || ref.Height != want[i][1] || ref.Channel != 3 {
+			t.Errorf("reference %d: got %dx%dx%d, want %dx%d", i, ref.Width, ref.Height, ref.Channel, want[i][0], want[i][1])
+		}
+	}
+	if base.RefImages != nil || base.Prompt != nil {
+		t.Error("Edit should not modify the base params")
+	}
+}
+
+func TestEditSizing(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx := newFakeContext(sd)
+	ref := image.NewRGBA(image.Rect(0, 0, 100, 70))
+
+	if _, err := ctx.Edit(EditRequest{
+		References:   []image.Image{ref},
+		Width:        1000,
+		Height:       700,
+		SizeMultiple: EditMultipleQwen,
+		AutoResize:   true,
+	}); err != nil {
+		t.Fatalf("Edit failed: %v", err)
+	}
+	call := fake.imgCalls[0]
+	if call.Width != 1024 || call.Height != 704 {
+		t.Errorf("expected 1024x704, got %dx%d", call.Width, call.Height)
+	}
+	if r := call.RefImages; r.Width != 100 || r.Height != 70 || !call.AutoResizeRefImage {
+		t.Errorf("auto resized reference should be passed as is, got %dx%d", r.Width, r.Height)
+	}
+
+	if _, err := ctx.Edit(EditRequest{Instruction: "x"}); err == nil {
+		t.Error("expected error without references")
+	}
+	if _, err := ctx.Edit(EditRequest{References: []image.Image{nil}}); err == nil {
+		t.Error("expected error for nil reference")
+	}
+}
+
+func TestRoundToMultiple(t *testing.T) {
+	for _, tc := range []struct{ n, m, want int }{
+		{100, 16, 96}, {70, 16, 64}, {72, 16, 80}, {5, 16, 16}, {700, 64, 704},
+	} {
+		if got := roundToMultiple(tc.n, tc.m); got != tc.want {
+			t.Errorf("roundToMultiple(%d, %d) = %d, want %d", tc.n, tc.m, got, tc.want)
+		}
+	}
+}
+
+func TestEditDefaultMultiple(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx, err := sd.NewContext(&SDContextParams{
+		DiffusionModelPath: CString("qwen-image-edit.gguf"),
+		LLMPath:            CString("qwen2.5-vl.gguf"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ctx.Edit(EditRequest{References: []image.Image{image.NewRGBA(image.Rect(0, 0, 100, 70))}}); err != nil {
+		t.Fatalf("Edit failed: %v", err)
+	}
+	if call := fake.imgCalls[0]; call.Width != 128 || call.Height != 64 {
+		t.Errorf("expected Qwen 128x64 output, got %dx%d", call.Width, call.Height)
+	}
+
+	tests := []struct {
+		params SDContextParams
+		want   int
+	}{
+		{SDContextParams{DiffusionModelPath: CString("flux-kontext.gguf"), T5XXLPath: CString("t5.gguf")}, EditMultipleFlux},
+		{SDContextParams{LLMPath: CString("qwen2.5-vl.gguf")}, EditMultipleQwen},
+		{SDContextParams{LLMPath: CString("mistral.gguf"), Prediction: Flux2FlowPred}, EditMultipleFlux},
+	}
+	for i, tt := range tests {
+		if got := editSizeMultiple(&tt.params); got != tt.want {
+			t.Errorf("case %d: editSizeMultiple = %d, want %d", i, got, tt.want)
+		}
+	}
+
+	var nilCtx *SDContext
+	if _, err := nilCtx.Edit(EditRequest{References: []image.Image{image.NewRGBA(image.Rect(0, 0, 16, 16))}}); err == nil {
+		t.Error("expected error for nil context")
+	}
+}
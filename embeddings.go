@@ -0,0 +1,91 @@
+package stablediffusion
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// embeddingExts lists the textual inversion file extensions ScanEmbeddings picks up
+var embeddingExts = []string{".pt", ".safetensors", ".bin"}
+
+// Embedding is a textual inversion file. Name is the token used in prompts.
+type Embedding struct {
+	Name string
+	Path string
+}
+
+// ScanEmbeddings finds the embeddings under dir, including subdirectories.
+// The name is the file name without its extension; two files with the same
+// name are reported as a collision because the prompt token would be ambiguous.
+func ScanEmbeddings(dir string) ([]Embedding, error) {
+	byName := make(map[string][]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !slices.Contains(embeddingExts, strings.ToLower(filepath.Ext(path))) {
+			return nil
+		}
+		name := strings.TrimSuffix(d.Name(), filepath.Ext(d.Name()))
+		byName[name] = append(byName[name], path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan embeddings: %w", err)
+	}
+
+	var (
+		embeddings []Embedding
+		collisions []string
+	)
+	for name, paths := range byName {
+		if len(paths) > 1 {
+			collisions = append(collisions, fmt.Sprintf("%s (%s)", name, strings.Join(paths, ", ")))
+			continue
+		}
+		embeddings = append(embeddings, Embedding{Name: name, Path: paths[0]})
+	}
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		return nil, fmt.Errorf("embedding name collision: %s", strings.Join(collisions, "; "))
+	}
+	sort.Slice(embeddings, func(i, j int) bool { return embeddings[i].Name < embeddings[j].Name })
+	return embeddings, nil
+}
+
+// SetEmbeddings sets params.Embeddings and EmbeddingCount from embeddings
+func (params *SDContextParams) SetEmbeddings(embeddings []Embedding) {
+	if len(embeddings) == 0 {
+		params.Embeddings, params.EmbeddingCount = nil, 0
+		return
+	}
+	arr := make([]SDEmbedding, len(embeddings))
+	for i, e := range embeddings {
+		arr[i] = SDEmbedding{Name: CString(e.Name), Path: CString(e.Path)}
+	}
+	params.Embeddings, params.EmbeddingCount = &arr[0], uint32(len(arr))
+}
+
+// WithEmbeddingsDir loads every embedding in dir into params and returns what
+// was loaded, e.g. for prompt.LintEmbeddings
+func (params *SDContextParams) WithEmbeddingsDir(dir string) ([]Embedding, error) {
+	embeddings, err := ScanEmbeddings(dir)
+	if err != nil {
+		return nil, err
+	}
+	params.SetEmbeddings(embeddings)
+	return embeddings, nil
+}
+
+// EmbeddingNames returns the prompt tokens of embeddings
+func EmbeddingNames(embeddings []Embedding) []string {
+	names := make([]string, len(embeddings))
+	for i, e := range embeddings {
+		names[i] = e.Name
+	}
+	return names
+}
@@ -0,0 +1,44 @@
+package stablediffusion
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestWithEmbeddingsDir(t *testing.T) {
+	dir := touchFiles(t, "easynegative.safetensors", "sub/badhands.pt", "style.bin", "notes.txt")
+
+	var params SDContextParams
+	embeddings, err := params.WithEmbeddingsDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(EmbeddingNames(embeddings), ","); got != "badhands,easynegative,style" {
+		t.Errorf("names = %s", got)
+	}
+	if params.EmbeddingCount != 3 {
+		t.Fatalf("EmbeddingCount = %d, want 3", params.EmbeddingCount)
+	}
+	arr := unsafe.Slice(params.Embeddings, params.EmbeddingCount)
+	if CGoString(arr[0].Name) != "badhands" || CGoString(arr[0].Path) != filepath.Join(dir, "sub", "badhands.pt") {
+		t.Errorf("first embedding = %s %s", CGoString(arr[0].Name), CGoString(arr[0].Path))
+	}
+}
+
+func TestScanEmbeddingsCollision(t *testing.T) {
+	dir := touchFiles(t, "style.pt", "other/style.safetensors", "unique.bin")
+	_, err := ScanEmbeddings(dir)
+	if err == nil || !strings.Contains(err.Error(), "collision: style") {
+		t.Errorf("expected collision error, got %v", err)
+	}
+}
+
+func TestSetEmbeddingsEmpty(t *testing.T) {
+	params := SDContextParams{EmbeddingCount: 2}
+	params.SetEmbeddings(nil)
+	if params.Embeddings != nil || params.EmbeddingCount != 0 {
+		t.Error("empty embeddings should clear the params")
+	}
+}
@@ -0,0 +1,128 @@
+package stablediffusion
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Go-side name tables matching the native *_name functions, so enums can be
+// parsed and printed without loading the library
+
+var sampleMethodNames = []string{
+	EulerSampleMethod:        "euler",
+	EulerASampleMethod:       "euler_a",
+	HeunSampleMethod:         "heun",
+	DPM2SampleMethod:         "dpm2",
+	DPMPP2SASampleMethod:     "dpm++2s_a",
+	DPMPP2MSampleMethod:      "dpm++2m",
+	DPMPP2Mv2SampleMethod:    "dpm++2mv2",
+	IPNDMSampleMethod:        "ipndm",
+	IPNDMSampleMethodV:       "ipndm_v",
+	LCMSampleMethod:          "lcm",
+	DDIMTrailingSampleMethod: "ddim_trailing",
+	TCDSampleMethod:          "tcd",
+}
+
+var schedulerNames = []string{
+	DiscreteScheduler:    "discrete",
+	KarrasScheduler:      "karras",
+	ExponentialScheduler: "exponential",
+	AYSScheduler:         "ays",
+	GITScheduler:         "gits",
+	SGMUniformScheduler:  "sgm_uniform",
+	SimpleScheduler:      "simple",
+	SmoothstepScheduler:  "smoothstep",
+	KLOptimalScheduler:   "kl_optimal",
+	LCMScheduler:         "lcm",
+}
+
+var predictionNames = []string{
+	EPSPred:       "eps",
+	VPred:         "v",
+	EDMVPred:      "edm_v",
+	FlowPred:      "sd3_flow",
+	FluxFlowPred:  "flux_flow",
+	Flux2FlowPred: "flux2_flow",
+}
+
+func enumName(names []string, v int32, typ string) string {
+	if v >= 0 && int(v) < len(names) {
+		return names[v]
+	}
+	return fmt.Sprintf("%s(%d)", typ, v)
+}
+
+func parseEnum(names []string, s, typ string) (int32, error) {
+	for i, name := range names {
+		if strings.EqualFold(name, s) {
+			return int32(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown %s %q", typ, s)
+}
+
+func (m SampleMethod) String() string {
+	return enumName(sampleMethodNames, int32(m), "SampleMethod")
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (m SampleMethod) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (m *SampleMethod) UnmarshalText(text []byte) error {
+	v, err := ParseSampleMethod(string(text))
+	*m = v
+	return err
+}
+
+// ParseSampleMethod parses a sample method name such as "euler_a"
+func ParseSampleMethod(s string) (SampleMethod, error) {
+	v, err := parseEnum(sampleMethodNames, s, "sample method")
+	return SampleMethod(v), err
+}
+
+func (s Scheduler) String() string {
+	return enumName(schedulerNames, int32(s), "Scheduler")
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s Scheduler) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (s *Scheduler) UnmarshalText(text []byte) error {
+	v, err := ParseScheduler(string(text))
+	*s = v
+	return err
+}
+
+// ParseScheduler parses a scheduler name such as "karras"
+func ParseScheduler(s string) (Scheduler, error) {
+	v, err := parseEnum(schedulerNames, s, "scheduler")
+	return Scheduler(v), err
+}
+
+func (p Prediction) String() string {
+	return enumName(predictionNames, int32(p), "Prediction")
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (p Prediction) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (p *Prediction) UnmarshalText(text []byte) error {
+	v, err := ParsePrediction(string(text))
+	*p = v
+	return err
+}
+
+// ParsePrediction parses a prediction name such as "flux_flow"
+func ParsePrediction(s string) (Prediction, error) {
+	v, err := parseEnum(predictionNames, s, "prediction")
+	return Prediction(v), err
+}
@@ -0,0 +1,49 @@
+package stablediffusion
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEnumNames(t *testing.T) {
+	if EulerASampleMethod.String() != "euler_a" || KarrasScheduler.String() != "karras" || FluxFlowPred.String() != "flux_flow" {
+		t.Error("unexpected enum names")
+	}
+	if SampleMethodCount.String() != "SampleMethod(12)" {
+		t.Errorf("unexpected out of range name %s", SampleMethodCount)
+	}
+
+	m, err := ParseSampleMethod("DPM++2M")
+	if err != nil || m != DPMPP2MSampleMethod {
+		t.Errorf("ParseSampleMethod returned %v, %v", m, err)
+	}
+	if _, err := ParseScheduler("nope"); err == nil {
+		t.Error("expected error for unknown scheduler")
+	}
+}
+
+func TestEnumJSON(t *testing.T) {
+	type config struct {
+		Method     SampleMethod `json:"method"`
+		Scheduler  Scheduler    `json:"scheduler"`
+		Prediction Prediction   `json:"prediction"`
+		Type       SDType       `json:"type"`
+	}
+
+	in := config{LCMSampleMethod, LCMScheduler, VPred, SDTypeQ8_0}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"method":"lcm","scheduler":"lcm","prediction":"v","type":"q8_0"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+
+	var out config
+	if err := json.Unmarshal(data, &out); err != nil || out != in {
+		t.Errorf("round trip returned %+v, %v", out, err)
+	}
+	if err := json.Unmarshal([]byte(`{"method":"bogus"}`), &out); err == nil {
+		t.Error("expected error for unknown method")
+	}
+}
@@ -0,0 +1,105 @@
+package stablediffusion
+
+import (
+	"os"
+	"unsafe"
+)
+
+// fakeBackend stands in for the native library in unit tests
+type fakeBackend struct {
+	imgCalls []SDImgGenParams
+	vidCalls []SDVidGenParams
+	// fill is the value written to every generated pixel
+	fill uint8
+	// failAfter makes generate_image return nil after that many calls when > 0
+	failAfter int
+
+	upscaleFactor int32
+	upscaleCalls  int
+	upscaleFail   bool
+
+	logCallback SDLogCallback
+	// convertErr makes convert log the message and fail when set
+	convertErr  string
+	convertedTo []string
+}
+
+// newFakeSD returns a StableDiffusion whose native calls are served by a fakeBackend
+func newFakeSD() (*StableDiffusion, *fakeBackend) {
+	fake := &fakeBackend{fill: 128, upscaleFactor: 4}
+	sd := &StableDiffusion{}
+	// The init functions set a few of the defaults sd.cpp uses
+	sd.sdImgGenParamsInit = func(params *SDImgGenParams) {
+		*params = SDImgGenParams{Width: 512, Height: 512, Seed: -1, BatchCount: 1, Strength: 0.75}
+		params.SampleParams.SampleSteps = 20
+	}
+	sd.sdVidGenParamsInit = func(params *SDVidGenParams) {
+		*params = SDVidGenParams{Width: 512, Height: 512, Seed: -1, VideoFrames: 1, Strength: 0.75}
+		params.SampleParams.SampleSteps = 20
+	}
+	sd.newSDContext = func(params *SDContextParams) unsafe.Pointer {
+		return unsafe.Pointer(new(byte))
+	}
+	sd.generateImage = func(ctx unsafe.Pointer, params *SDImgGenParams) *SDImage {
+		fake.imgCalls = append(fake.imgCalls, *params)
+		if fake.failAfter > 0 && len(fake.imgCalls) > fake.failAfter {
+			return nil
+		}
+		img := solidImage(int(params.Width), int(params.Height), fake.fill)
+		return &img
+	}
+	sd.generateVideo = func(ctx unsafe.Pointer, params *SDVidGenParams, numFramesOut *int32) *SDImage {
+		fake.vidCalls = append(fake.vidCalls, *params)
+		if fake.failAfter > 0 && len(fake.vidCalls) > fake.failAfter {
+			return nil
+		}
+		frames := make([]SDImage, params.VideoFrames)
+		for i := range frames {
+			frames[i] = solidImage(int(params.Width), int(params.Height), fake.fill+uint8(i))
+		}
+		*numFramesOut = params.VideoFrames
+		return &frames[0]
+	}
+	sd.getUpscaleFactor = func(ctx unsafe.Pointer) int32 {
+		return fake.upscaleFactor
+	}
+	sd.upscale = func(ctx unsafe.Pointer, input *SDImage, factor uint32) *SDImage {
+		fake.upscaleCalls++
+		if fake.upscaleFail {
+			return nil
+		}
+		img := solidImage(int(input.Width*factor), int(input.Height*factor), fake.fill)
+		return &img
+	}
+	sd.sdSetLogCallback = func(cb SDLogCallback, data unsafe.Pointer) {
+		fake.logCallback = cb
+	}
+	sd.convert = func(inputPath, vaePath, outputPath *uint8, outputType SDType, tensorTypeRules *uint8, convertName bool) bool {
+		if fake.convertErr != "" {
+			fake.logCallback(SDLogError, CString(fake.convertErr+"\n"), nil)
+			return false
+		}
+		path := CGoString(outputPath)
+		fake.convertedTo = append(fake.convertedTo, path)
+		return os.WriteFile(path, []byte(outputType.String()), 0644) == nil
+	}
+	return sd, fake
+}
+
+// newFakeUpscaler wraps sd in an UpscalerContext with a non-nil native handle
+func newFakeUpscaler(sd *StableDiffusion) *UpscalerContext {
+	return &UpscalerContext{ptr: unsafe.Pointer(new(byte)), sd: sd}
+}
+
+// newFakeContext wraps sd in an SDContext with a non-nil native handle
+func newFakeContext(sd *StableDiffusion) *SDContext {
+	return &SDContext{ptr: unsafe.Pointer(new(byte)), sd: sd}
+}
+
+func solidImage(width, height int, value uint8) SDImage {
+	data := make([]uint8, width*height*3)
+	for i := range data {
+		data[i] = value
+	}
+	return SDImage{Width: uint32(width), Height: uint32(height), Channel: 3, Data: &data[0]}
+}
@@ -4,5 +4,7 @@ go 1.25.5
 
 require (
 	github.com/ebitengine/purego v0.9.1
+	golang.org/x/image v0.24.0
 	golang.org/x/sys v0.30.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,111 @@
+package stablediffusion
+
+import (
+	"fmt"
+	"image"
+)
+
+// HiresFixOptions configures the second pass of a HiresFix run
+type HiresFixOptions struct {
+	// Scale multiplies the first pass size; ignored when Width and Height are set. Defaults to 2.
+	Scale float32
+	// Width and Height set an explicit second pass size
+	Width  int32
+	Height int32
+	// Upscaler upscales with an ESRGAN model instead of Lanczos when set
+	Upscaler *UpscalerContext
+	// Strength is the second pass denoising strength. Defaults to 0.5.
+	Strength float32
+	// SampleParams overrides the second pass sampler, scheduler, steps and guidance.
+	// The first pass sample params are reused when nil.
+	SampleParams *SDSampleParams
+}
+
+// HiresFixResult holds the images produced by HiresFix
+type HiresFixResult struct {
+	FirstPass image.Image
+	Upscaled  image.Image
+	Final     image.Image
+}
+
+// HiresFix generates an image at the size in params, upscales it and runs a
+// second img2img pass at the larger size with the same prompt and seed.
+// A negative seed is resolved to one random seed shared by both passes.
+// MaskImage and ControlImage only apply to the first pass.
+func (ctx *SDContext) HiresFix(params *SDImgGenParams, opts HiresFixOptions) (*HiresFixResult, error) {
+	if ctx == nil || ctx.ptr == nil {
+		return nil, fmt.Errorf("SD context is not initialized")
+	}
+	if params == nil {
+		return nil, fmt.Errorf("image generation params are nil")
+	}
+	if opts.Upscaler != nil && opts.Upscaler.ptr == nil {
+		return nil, fmt.Errorf("upscaler context is not initialized")
+	}
+
+	width, height := hiresTarget(params.Width, params.Height, opts)
+	if width <= params.Width && height <= params.Height {
+		return nil, fmt.Errorf("hires target %dx%d is not larger than %dx%d", width, height, params.Width, params.Height)
+	}
+
+	firstParams := *params
+	firstParams.BatchCount = 1
+	firstParams.Seed = ResolveSeed(params.Seed)
+	first, err := ctx.Generate(&firstParams)
+	if err != nil {
+		return nil, fmt.Errorf("first pass failed: %w", err)
+	}
+
+	upscaled, err := hiresUpscale(first, int(width), int(height), opts.Upscaler)
+	if err != nil {
+		return nil, err
+	}
+
+	secondParams := firstParams
+	secondParams.Width = width
+	secondParams.Height = height
+	secondParams.InitImage = ImageToSDImage(upscaled)
+	secondParams.MaskImage = SDImage{}
+	secondParams.ControlImage = SDImage{}
+	secondParams.Strength = opts.Strength
+	if secondParams.Strength <= 0 {
+		secondParams.Strength = 0.5
+	}
+	if opts.SampleParams != nil {
+		secondParams.SampleParams = *opts.SampleParams
+	}
+
+	final, err := ctx.Generate(&secondParams)
+	if err != nil {
+		return nil, fmt.Errorf("second pass failed: %w", err)
+	}
+
+	return &HiresFixResult{FirstPass: first, Upscaled: upscaled, Final: final}, nil
+}
+
+// hiresTarget computes the second pass size rounded down to a multiple of 8
+func hiresTarget(width, height int32, opts HiresFixOptions) (int32, int32) {
+	if opts.Width > 0 && opts.Height > 0 {
+		return opts.Width / 8 * 8, opts.Height / 8 * 8
+	}
+	scale := opts.Scale
+	if scale <= 0 {
+		scale = 2
+	}
+	return int32(float32(width)*scale) / 8 * 8, int32(float32(height)*scale) / 8 * 8
+}
+
+// hiresUpscale resizes img to the target size, going through ESRGAN first when available
+func hiresUpscale(img image.Image, width, height int, upscaler *UpscalerContext) (image.Image, error) {
+	if upscaler == nil {
+		return ResizeLanczos(img, width, height), nil
+	}
+
+	bounds := img.Bounds()
+	factor := max((width+bounds.Dx()-1)/bounds.Dx(), (height+bounds.Dy()-1)/bounds.Dy())
+	esrgan, err := upscaler.Upscale(img, uint32(factor))
+	if err != nil {
+		return nil, fmt.Errorf("upscale failed: %w", err)
+	}
+	return ResizeLanczos(esrgan, width, height), nil
+}
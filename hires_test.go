@@ -0,0 +1,125 @@
+package stablediffusion
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestResizeLanczos(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		img.Pix[i] = 200
+	}
+
+	out := ResizeLanczos(img, 8, 6)
+	if out.Rect.Dx() != 8 || out.Rect.Dy() != 6 {
+		t.Fatalf("unexpected size %v", out.Rect)
+	}
+	// A flat image must stay flat
+	for i, v := range out.Pix {
+		if v != 200 {
+			t.Fatalf("pixel byte %d = %d, want 200", i, v)
+		}
+	}
+
+	same := ResizeLanczos(img, 4, 4)
+	if &same.Pix[0] == &img.Pix[0] {
+		t.Error("same-size resize should return a copy")
+	}
+}
+
+func TestResizeLanczosDownscale(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 1))
+	for x := 0; x < 8; x++ {
+		v := uint8(0)
+		if x%2 == 0 {
+			v = 255
+		}
+		img.SetRGBA(x, 0, color.RGBA{v, v, v, 255})
+	}
+
+	out := ResizeLanczos(img, 2, 1)
+	for x := 0; x < 2; x++ {
+		if r := out.RGBAAt(x, 0).R; r < 100 || r > 155 {
+			t.Errorf("expected averaged value at %d, got %d", x, r)
+		}
+	}
+}
+
+func TestHiresFix(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx := newFakeContext(sd)
+
+	var params SDImgGenParams
+	params.Width = 64
+	params.Height = 48
+	params.Seed = 7
+	params.Prompt = CString("a cat")
+	params.SampleParams.SampleSteps = 20
+
+	second := SDSampleParams{SampleSteps: 10, SampleMethod: DPMPP2MSampleMethod}
+	result, err := ctx.HiresFix(&params, HiresFixOptions{Strength: 0.4, SampleParams: &second})
+	if err != nil {
+		t.Fatalf("HiresFix failed: %v", err)
+	}
+
+	if len(fake.imgCalls) != 2 {
+		t.Fatalf("expected 2 generate calls, got %d", len(fake.imgCalls))
+	}
+	pass2 := fake.imgCalls[1]
+	if pass2.Width != 128 || pass2.Height != 96 {
+		t.Errorf("second pass size %dx%d, want 128x96", pass2.Width, pass2.Height)
+	}
+	if pass2.InitImage.Width != 128 || pass2.InitImage.Height != 96 || pass2.InitImage.Data == nil {
+		t.Error("second pass init image not set to upscaled first pass")
+	}
+	if pass2.Strength != 0.4 || pass2.Seed != 7 || pass2.Prompt != params.Prompt {
+		t.Error("second pass did not reuse seed and prompt with the requested strength")
+	}
+	if pass2.SampleParams.SampleSteps != 10 || pass2.SampleParams.SampleMethod != DPMPP2MSampleMethod {
+		t.Error("second pass sample params not applied")
+	}
+
+	if result.FirstPass.Bounds().Dx() != 64 || result.Final.Bounds().Dx() != 128 {
+		t.Error("unexpected result image sizes")
+	}
+}
+
+func TestHiresFixErrors(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx := newFakeContext(sd)
+	params := SDImgGenParams{Width: 64, Height: 64}
+
+	if _, err := ctx.HiresFix(&params, HiresFixOptions{Scale: 1}); err == nil {
+		t.Error("expected error when target is not larger")
+	}
+
+	fake.failAfter = 1
+	if _, err := ctx.HiresFix(&params, HiresFixOptions{}); err == nil {
+		t.Error("expected error when second pass fails")
+	}
+
+	if _, err := (&SDContext{sd: sd}).HiresFix(&params, HiresFixOptions{}); err == nil {
+		t.Error("expected error for freed context")
+	}
+	var nilCtx *SDContext
+	if _, err := nilCtx.HiresFix(&params, HiresFixOptions{}); err == nil {
+		t.Error("expected error for nil context")
+	}
+}
+
+func TestHiresFixRandomSeed(t *testing.T) {
+	sd, fake := newFakeSD()
+	params := SDImgGenParams{Width: 64, Height: 64, Seed: -1}
+	if _, err := newFakeContext(sd).HiresFix(&params, HiresFixOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	first, second := fake.imgCalls[0].Seed, fake.imgCalls[1].Seed
+	if first < 0 || first != second {
+		t.Errorf("both passes should share one resolved seed, got %d and %d", first, second)
+	}
+	if params.Seed != -1 {
+		t.Error("HiresFix should not modify params")
+	}
+}
@@ -0,0 +1,34 @@
+// Package glyph draws labels with basicfont.Face7x13 for the sweep contact
+// sheets and the sigma plots.
+package glyph
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Glyph metrics of basicfont.Face7x13
+const (
+	Width  = 7
+	Height = 13
+	Ascent = 11
+)
+
+// Mask renders text into an alpha mask of len(text) glyphs by Height pixels
+func Mask(text string) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, len([]rune(text))*Width, Height))
+	d := font.Drawer{Dst: mask, Src: image.Opaque, Face: basicfont.Face7x13, Dot: fixed.P(0, Ascent)}
+	d.DrawString(text)
+	return mask
+}
+
+// Draw draws text in c with its top-left corner at pt
+func Draw(dst draw.Image, pt image.Point, text string, c color.Color) {
+	d := font.Drawer{Dst: dst, Src: image.NewUniform(c), Face: basicfont.Face7x13, Dot: fixed.P(pt.X, pt.Y+Ascent)}
+	d.DrawString(text)
+}
@@ -0,0 +1,40 @@
+package glyph
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestMask(t *testing.T) {
+	mask := Mask("ab")
+	if mask.Bounds() != image.Rect(0, 0, 2*Width, Height) {
+		t.Fatalf("unexpected mask size %v", mask.Bounds())
+	}
+	inked := 0
+	for _, a := range mask.Pix {
+		if a >= 128 {
+			inked++
+		}
+	}
+	if inked == 0 {
+		t.Error("mask has no ink")
+	}
+}
+
+func TestDraw(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	Draw(img, image.Pt(2, 2), "x", color.RGBA{255, 0, 0, 255})
+	found := false
+	for y := 0; y < 20 && !found; y++ {
+		for x := 0; x < 20 && !found; x++ {
+			found = img.RGBAAt(x, y) == color.RGBA{255, 0, 0, 255}
+		}
+	}
+	if !found {
+		t.Error("text was not drawn")
+	}
+	if img.RGBAAt(0, 0).A != 0 || img.RGBAAt(2+Width, 2+Height).A != 0 {
+		t.Error("text drawn outside its cell")
+	}
+}
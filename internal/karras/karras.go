@@ -0,0 +1,27 @@
+// Package karras computes the Karras et al. (2022) noise schedule shared by
+// the scheduled prompts of the root package and the sigmas package.
+package karras
+
+import "math"
+
+// Default Karras range of the SD1/SD2/SDXL discrete schedules and the usual rho
+const (
+	SigmaMin = 0.0292
+	SigmaMax = 14.6146
+	Rho      = 7.0
+)
+
+// Sigmas returns steps+1 Karras sigmas from sigmaMax down to sigmaMin, ending in 0
+func Sigmas(steps int, sigmaMin, sigmaMax, rho float64) []float32 {
+	sigmas := make([]float32, steps+1)
+	minInv := math.Pow(sigmaMin, 1/rho)
+	maxInv := math.Pow(sigmaMax, 1/rho)
+	for i := range steps {
+		t := 0.0
+		if steps > 1 {
+			t = float64(i) / float64(steps-1)
+		}
+		sigmas[i] = float32(math.Pow(maxInv+t*(minInv-maxInv), rho))
+	}
+	return sigmas
+}
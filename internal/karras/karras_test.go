@@ -0,0 +1,21 @@
+package karras
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSigmas(t *testing.T) {
+	s := Sigmas(10, SigmaMin, SigmaMax, Rho)
+	if len(s) != 11 || s[10] != 0 {
+		t.Fatalf("expected 11 sigmas ending in 0, got %v", s)
+	}
+	if math.Abs(float64(s[0])-SigmaMax) > 1e-3 || math.Abs(float64(s[9])-SigmaMin) > 1e-4 {
+		t.Errorf("range = %v..%v", s[0], s[9])
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] >= s[i-1] {
+			t.Fatalf("sigmas not decreasing at %d: %v", i, s)
+		}
+	}
+}
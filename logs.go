@@ -0,0 +1,65 @@
+package stablediffusion
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// SetLogCallback forwards native log lines to cb. Passing nil stops forwarding.
+func (sd *StableDiffusion) SetLogCallback(cb func(level SDLogLevel, text string)) {
+	sd.installLogHook()
+
+	sd.logMu.Lock()
+	sd.logHandler = cb
+	sd.logMu.Unlock()
+}
+
+// installLogHook registers the native log callback once per instance.
+// Native callbacks are a limited resource, so later changes only swap the Go handler.
+func (sd *StableDiffusion) installLogHook() {
+	sd.logOnce.Do(func() {
+		if sd.sdSetLogCallback == nil {
+			return
+		}
+		sd.sdSetLogCallback(func(level SDLogLevel, text *uint8, data unsafe.Pointer) {
+			sd.dispatchLog(level, strings.TrimRight(CGoString(text), "\n"))
+		}, nil)
+	})
+}
+
+// dispatchLog records a native log line and forwards it to the user handler
+func (sd *StableDiffusion) dispatchLog(level SDLogLevel, text string) {
+	sd.logMu.Lock()
+	handler := sd.logHandler
+	if sd.logCapture != nil && level >= SDLogWarn {
+		*sd.logCapture = append(*sd.logCapture, text)
+	}
+	sd.logMu.Unlock()
+
+	if handler != nil {
+		handler(level, text)
+	}
+}
+
+// captureLogs runs fn and returns the warning and error lines logged while it ran.
+// Callers must serialize captureLogs themselves since native logging is global.
+func (sd *StableDiffusion) captureLogs(fn func()) []string {
+	sd.installLogHook()
+
+	var lines []string
+	sd.logMu.Lock()
+	sd.logCapture = &lines
+	sd.logMu.Unlock()
+
+	defer func() {
+		sd.logMu.Lock()
+		sd.logCapture = nil
+		sd.logMu.Unlock()
+	}()
+
+	fn()
+
+	sd.logMu.Lock()
+	defer sd.logMu.Unlock()
+	return append([]string(nil), lines...)
+}
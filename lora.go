@@ -0,0 +1,112 @@
+package stablediffusion
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/kawai-network/stablediffusion/prompt"
+)
+
+// loraExts lists the file extensions tried when resolving a LoRA name, in order
+var loraExts = []string{".safetensors", ".gguf", ".ckpt"}
+
+// ResolveLora finds the file for a LoRA name in dir. The name may include
+// subdirectories and may already carry its extension. Names that are absolute
+// or escape dir with ".." are rejected, since they usually come from prompts.
+func ResolveLora(dir, name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("lora name %q must be a relative path inside %s", name, dir)
+	}
+	base := filepath.Join(dir, name)
+	if info, err := os.Stat(base); err == nil && !info.IsDir() {
+		return base, nil
+	}
+	for _, ext := range loraExts {
+		if info, err := os.Stat(base + ext); err == nil && !info.IsDir() {
+			return base + ext, nil
+		}
+	}
+	return "", fmt.Errorf("lora %q not found in %s", name, dir)
+}
+
+// PromptLoras extracts the <lora:...> tags from text, resolves them against dir
+// and returns the stripped prompt with the matching SDLora entries. Repeated
+// tags for the same file add their multipliers, as the stable-diffusion.cpp CLI does.
+func PromptLoras(text, dir string) (string, []SDLora, error) {
+	stripped, tags, err := prompt.ExtractLoras(text)
+	if err != nil {
+		return "", nil, err
+	}
+
+	type key struct {
+		path string
+		high bool
+	}
+	var (
+		loras []SDLora
+		index = make(map[key]int)
+	)
+	for _, tag := range tags {
+		path, err := ResolveLora(dir, tag.Name)
+		if err != nil {
+			return "", nil, err
+		}
+		k := key{path, tag.HighNoise}
+		if i, ok := index[k]; ok {
+			loras[i].Multiplier += float32(tag.Multiplier)
+			continue
+		}
+		index[k] = len(loras)
+		loras = append(loras, SDLora{
+			IsHighNoise: tag.HighNoise,
+			Multiplier:  float32(tag.Multiplier),
+			Path:        CString(path),
+		})
+	}
+	return stripped, loras, nil
+}
+
+// loraArray returns the pointer and count for an SDLora slice. The slice must
+// stay referenced until generation returns.
+func loraArray(loras []SDLora) (*SDLora, uint32) {
+	if len(loras) == 0 {
+		return nil, 0
+	}
+	return &loras[0], uint32(len(loras))
+}
+
+// ApplyPromptLoras moves the LoRA tags in params.Prompt into params.Loras,
+// appending to any LoRAs already set. The returned slice backs params.Loras
+// and must stay referenced until generation returns.
+func ApplyPromptLoras(params *SDImgGenParams, dir string) ([]SDLora, error) {
+	return applyPromptLoras(&params.Prompt, &params.Loras, &params.LoraCount, dir)
+}
+
+// ApplyVideoPromptLoras is ApplyPromptLoras for video generation, where
+// high-noise tags select the Wan2.2 high-noise expert
+func ApplyVideoPromptLoras(params *SDVidGenParams, dir string) ([]SDLora, error) {
+	return applyPromptLoras(&params.Prompt, &params.Loras, &params.LoraCount, dir)
+}
+
+func applyPromptLoras(text **uint8, first **SDLora, count *uint32, dir string) ([]SDLora, error) {
+	stripped, loras, err := PromptLoras(CGoString(*text), dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(loras) == 0 {
+		return nil, nil
+	}
+	if *count > 0 {
+		loras = append(loraSlice(*first, *count), loras...)
+	}
+	*text = CString(stripped)
+	*first, *count = loraArray(loras)
+	return loras, nil
+}
+
+// loraSlice copies an existing SDLora array into a new slice
+func loraSlice(first *SDLora, count uint32) []SDLora {
+	return append([]SDLora(nil), unsafe.Slice(first, count)...)
+}
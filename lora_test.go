@@ -0,0 +1,116 @@
+package stablediffusion
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+func touchFiles(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestResolveLora(t *testing.T) {
+	dir := touchFiles(t, "detail.safetensors", "style.gguf", "old.ckpt", "sub/anime.safetensors")
+	for name, want := range map[string]string{
+		"detail":             "detail.safetensors",
+		"style":              "style.gguf",
+		"old":                "old.ckpt",
+		"sub/anime":          "sub/anime.safetensors",
+		"detail.safetensors": "detail.safetensors",
+	} {
+		got, err := ResolveLora(dir, name)
+		if err != nil {
+			t.Errorf("ResolveLora(%q) failed: %v", name, err)
+			continue
+		}
+		if got != filepath.Join(dir, want) {
+			t.Errorf("ResolveLora(%q) = %s, want %s", name, got, want)
+		}
+	}
+	if _, err := ResolveLora(dir, "missing"); err == nil {
+		t.Error("expected error for missing lora")
+	}
+}
+
+func TestResolveLoraOutsideDir(t *testing.T) {
+	root := touchFiles(t, "outside.safetensors", "loras/detail.safetensors")
+	dir := filepath.Join(root, "loras")
+	for _, name := range []string{"../outside", "sub/../../outside", filepath.Join(root, "outside.safetensors")} {
+		if _, err := ResolveLora(dir, name); err == nil {
+			t.Errorf("ResolveLora(%q) should be rejected", name)
+		}
+	}
+}
+
+func TestApplyPromptLoras(t *testing.T) {
+	dir := touchFiles(t, "detail.safetensors", "motion.safetensors", "base.safetensors")
+
+	existing := []SDLora{{Multiplier: 0.3, Path: CString(filepath.Join(dir, "base.safetensors"))}}
+	params := SDImgGenParams{
+		Prompt:    CString("a cat <lora:detail:0.5> <lora:motion:0.7:high> <lora:detail:0.25>"),
+		Loras:     &existing[0],
+		LoraCount: 1,
+	}
+	loras, err := ApplyPromptLoras(&params, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := CGoString(params.Prompt); got != "a cat" {
+		t.Errorf("prompt = %q, want tags stripped", got)
+	}
+	if params.LoraCount != 3 || params.Loras != &loras[0] {
+		t.Fatalf("LoraCount = %d, want 3 backed by the returned slice", params.LoraCount)
+	}
+	got := unsafe.Slice(params.Loras, params.LoraCount)
+	if got[0].Multiplier != 0.3 {
+		t.Errorf("existing lora not kept first: %+v", got[0])
+	}
+	if CGoString(got[1].Path) != filepath.Join(dir, "detail.safetensors") || got[1].Multiplier != 0.75 || got[1].IsHighNoise {
+		t.Errorf("repeated tags not merged: %+v", got[1])
+	}
+	if !got[2].IsHighNoise || got[2].Multiplier != 0.7 {
+		t.Errorf("high-noise lora = %+v", got[2])
+	}
+}
+
+func TestApplyPromptLorasErrors(t *testing.T) {
+	dir := touchFiles(t)
+	params := SDImgGenParams{Prompt: CString("a cat <lora:missing:1>")}
+	if _, err := ApplyPromptLoras(&params, dir); err == nil {
+		t.Error("expected error for missing lora")
+	}
+	if CGoString(params.Prompt) != "a cat <lora:missing:1>" {
+		t.Error("prompt changed despite error")
+	}
+
+	params = SDImgGenParams{Prompt: CString("plain prompt")}
+	loras, err := ApplyPromptLoras(&params, dir)
+	if err != nil || loras != nil || params.Loras != nil {
+		t.Errorf("untagged prompt produced loras: %v %v", loras, err)
+	}
+}
+
+func TestApplyVideoPromptLoras(t *testing.T) {
+	dir := touchFiles(t, "wan_high.safetensors")
+	params := SDVidGenParams{Prompt: CString("a wave <lora:wan_high:1:high>")}
+	if _, err := ApplyVideoPromptLoras(&params, dir); err != nil {
+		t.Fatal(err)
+	}
+	if params.LoraCount != 1 || !params.Loras.IsHighNoise {
+		t.Errorf("expected one high-noise lora, got %d", params.LoraCount)
+	}
+}
@@ -0,0 +1,266 @@
+// Package mask builds single-channel inpainting masks for SDImgGenParams.MaskImage.
+//
+// Masks are *image.Gray values where white (255) marks pixels the model should
+// repaint and black (0) marks pixels it should keep.
+package mask
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"slices"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// New returns an empty (all keep) mask of the given size
+func New(width, height int) *image.Gray {
+	return image.NewGray(image.Rect(0, 0, width, height))
+}
+
+// FromAlpha builds a mask from the alpha channel of img.
+// Transparent pixels become white (repaint), opaque pixels become black (keep).
+func FromAlpha(img image.Image) *image.Gray {
+	bounds := img.Bounds()
+	m := New(bounds.Dx(), bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			m.SetGray(x-bounds.Min.X, y-bounds.Min.Y, color.Gray{Y: 255 - uint8(a>>8)})
+		}
+	}
+	return m
+}
+
+// Rect builds a mask with the rectangle r marked for repainting
+func Rect(width, height int, r image.Rectangle) *image.Gray {
+	m := New(width, height)
+	draw.Draw(m, r.Intersect(m.Bounds()), image.White, image.Point{}, draw.Src)
+	return m
+}
+
+// Polygon builds a mask with the polygon pts marked for repainting.
+// The polygon is closed implicitly and filled with the even-odd rule.
+func Polygon(width, height int, pts []image.Point) *image.Gray {
+	m := New(width, height)
+	FillPolygon(m, pts)
+	return m
+}
+
+// FillPolygon marks the polygon pts for repainting on an existing mask
+func FillPolygon(m *image.Gray, pts []image.Point) {
+	if len(pts) < 3 {
+		return
+	}
+
+	bounds := m.Bounds()
+	xs := make([]float64, 0, len(pts))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		// Sample at the pixel center so shared vertices are not counted twice
+		cy := float64(y) + 0.5
+		xs = xs[:0]
+		for i := range pts {
+			a := pts[i]
+			b := pts[(i+1)%len(pts)]
+			ay, by := float64(a.Y), float64(b.Y)
+			if (ay <= cy && by > cy) || (by <= cy && ay > cy) {
+				t := (cy - ay) / (by - ay)
+				xs = append(xs, float64(a.X)+t*float64(b.X-a.X))
+			}
+		}
+		slices.Sort(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			x0 := int(math.Ceil(xs[i] - 0.5))
+			x1 := int(math.Ceil(xs[i+1] - 0.5))
+			for x := max(x0, bounds.Min.X); x < min(x1, bounds.Max.X); x++ {
+				m.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+}
+
+// Stroke is a brush stroke through Points with the given Radius in pixels
+type Stroke struct {
+	Points []image.Point
+	Radius float64
+}
+
+// Brush builds a mask from brush strokes
+func Brush(width, height int, strokes []Stroke) *image.Gray {
+	m := New(width, height)
+	for _, s := range strokes {
+		Paint(m, s)
+	}
+	return m
+}
+
+// Paint draws a brush stroke onto an existing mask
+func Paint(m *image.Gray, s Stroke) {
+	if len(s.Points) == 0 || s.Radius <= 0 {
+		return
+	}
+	if len(s.Points) == 1 {
+		paintSegment(m, s.Points[0], s.Points[0], s.Radius)
+		return
+	}
+	for i := 0; i+1 < len(s.Points); i++ {
+		paintSegment(m, s.Points[i], s.Points[i+1], s.Radius)
+	}
+}
+
+// paintSegment fills every pixel within radius of the segment a-b
+func paintSegment(m *image.Gray, a, b image.Point, radius float64) {
+	r := int(math.Ceil(radius))
+	area := image.Rect(min(a.X, b.X)-r, min(a.Y, b.Y)-r, max(a.X, b.X)+r+1, max(a.Y, b.Y)+r+1).Intersect(m.Bounds())
+
+	ax, ay := float64(a.X), float64(a.Y)
+	dx, dy := float64(b.X-a.X), float64(b.Y-a.Y)
+	lenSq := dx*dx + dy*dy
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			px, py := float64(x), float64(y)
+			t := 0.0
+			if lenSq > 0 {
+				t = math.Max(0, math.Min(1, ((px-ax)*dx+(py-ay)*dy)/lenSq))
+			}
+			ex, ey := px-(ax+t*dx), py-(ay+t*dy)
+			if ex*ex+ey*ey <= radius*radius {
+				m.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+}
+
+// Invert returns a copy of m with repaint and keep swapped
+func Invert(m *image.Gray) *image.Gray {
+	out := image.NewGray(m.Bounds())
+	for i, v := range m.Pix {
+		out.Pix[i] = 255 - v
+	}
+	return out
+}
+
+// Dilate grows the repaint area by radius pixels
+func Dilate(m *image.Gray, radius int) *image.Gray {
+	return morph(m, radius, func(a, b uint8) bool { return a > b })
+}
+
+// Erode shrinks the repaint area by radius pixels
+func Erode(m *image.Gray, radius int) *image.Gray {
+	return morph(m, radius, func(a, b uint8) bool { return a < b })
+}
+
+// morph applies a separable square max/min filter
+func morph(m *image.Gray, radius int, better func(a, b uint8) bool) *image.Gray {
+	if radius <= 0 {
+		return clone(m)
+	}
+	w, h := m.Bounds().Dx(), m.Bounds().Dy()
+	src := clone(m)
+	tmp := image.NewGray(src.Bounds())
+	out := image.NewGray(src.Bounds())
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := src.Pix[y*src.Stride+x]
+			for k := max(0, x-radius); k <= min(w-1, x+radius); k++ {
+				if c := src.Pix[y*src.Stride+k]; better(c, v) {
+					v = c
+				}
+			}
+			tmp.Pix[y*tmp.Stride+x] = v
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := tmp.Pix[y*tmp.Stride+x]
+			for k := max(0, y-radius); k <= min(h-1, y+radius); k++ {
+				if c := tmp.Pix[k*tmp.Stride+x]; better(c, v) {
+					v = c
+				}
+			}
+			out.Pix[y*out.Stride+x] = v
+		}
+	}
+	return out
+}
+
+// Blur applies a gaussian blur with the given sigma in pixels
+func Blur(m *image.Gray, sigma float64) *image.Gray {
+	if sigma <= 0 {
+		return clone(m)
+	}
+	radius := int(math.Ceil(sigma * 3))
+	kernel := make([]float64, 2*radius+1)
+	sum := 0.0
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	w, h := m.Bounds().Dx(), m.Bounds().Dy()
+	src := clone(m)
+	tmp := make([]float64, w*h)
+	out := image.NewGray(src.Bounds())
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			acc := 0.0
+			for i, k := range kernel {
+				sx := min(max(x+i-radius, 0), w-1)
+				acc += k * float64(src.Pix[y*src.Stride+sx])
+			}
+			tmp[y*w+x] = acc
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			acc := 0.0
+			for i, k := range kernel {
+				sy := min(max(y+i-radius, 0), h-1)
+				acc += k * tmp[sy*w+x]
+			}
+			out.Pix[y*out.Stride+x] = uint8(math.Round(math.Min(255, math.Max(0, acc))))
+		}
+	}
+	return out
+}
+
+// Feather softens the mask edge over roughly radius pixels
+func Feather(m *image.Gray, radius int) *image.Gray {
+	return Blur(m, float64(radius)/2)
+}
+
+// ToSDImage converts a mask to a single-channel SDImage for SDImgGenParams.MaskImage
+func ToSDImage(m *image.Gray) stablediffusion.SDImage {
+	bounds := m.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return stablediffusion.SDImage{Width: uint32(width), Height: uint32(height), Channel: 1}
+	}
+
+	data := make([]uint8, width*height)
+	for y := 0; y < height; y++ {
+		copy(data[y*width:(y+1)*width], m.Pix[y*m.Stride:y*m.Stride+width])
+	}
+
+	return stablediffusion.SDImage{
+		Width:   uint32(width),
+		Height:  uint32(height),
+		Channel: 1,
+		Data:    &data[0],
+	}
+}
+
+// clone copies m into a new zero-origin mask
+func clone(m *image.Gray) *image.Gray {
+	bounds := m.Bounds()
+	out := New(bounds.Dx(), bounds.Dy())
+	draw.Draw(out, out.Bounds(), m, bounds.Min, draw.Src)
+	return out
+}
@@ -0,0 +1,143 @@
+package mask
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"unsafe"
+)
+
+func TestFromAlpha(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{10, 20, 30, 255})
+	img.SetNRGBA(1, 0, color.NRGBA{10, 20, 30, 0})
+
+	m := FromAlpha(img)
+	if m.GrayAt(0, 0).Y != 0 {
+		t.Errorf("opaque pixel should be kept, got %d", m.GrayAt(0, 0).Y)
+	}
+	if m.GrayAt(1, 0).Y != 255 {
+		t.Errorf("transparent pixel should be repainted, got %d", m.GrayAt(1, 0).Y)
+	}
+}
+
+func TestRectAndInvert(t *testing.T) {
+	m := Rect(4, 4, image.Rect(1, 1, 3, 3))
+	if count(m) != 4 {
+		t.Errorf("expected 4 masked pixels, got %d", count(m))
+	}
+	if count(Invert(m)) != 12 {
+		t.Errorf("expected 12 masked pixels after invert, got %d", count(Invert(m)))
+	}
+}
+
+func TestPolygon(t *testing.T) {
+	square := []image.Point{{2, 2}, {6, 2}, {6, 6}, {2, 6}}
+	m := Polygon(8, 8, square)
+	if count(m) != 16 {
+		t.Errorf("expected 16 masked pixels, got %d", count(m))
+	}
+	if m.GrayAt(1, 1).Y != 0 || m.GrayAt(3, 3).Y != 255 {
+		t.Error("polygon fill covers the wrong pixels")
+	}
+}
+
+func TestBrush(t *testing.T) {
+	m := Brush(10, 10, []Stroke{{Points: []image.Point{{2, 5}, {7, 5}}, Radius: 1}})
+	if m.GrayAt(2, 5).Y != 255 || m.GrayAt(7, 5).Y != 255 || m.GrayAt(5, 4).Y != 255 {
+		t.Error("brush stroke did not cover its path")
+	}
+	if m.GrayAt(5, 2).Y != 0 {
+		t.Error("brush stroke leaked outside its radius")
+	}
+}
+
+func TestDilateErode(t *testing.T) {
+	m := Rect(9, 9, image.Rect(4, 4, 5, 5))
+	d := Dilate(m, 1)
+	if count(d) != 9 {
+		t.Errorf("expected 9 pixels after dilate, got %d", count(d))
+	}
+	if count(Erode(d, 1)) != 1 {
+		t.Errorf("expected 1 pixel after erode, got %d", count(Erode(d, 1)))
+	}
+}
+
+func TestFeather(t *testing.T) {
+	m := Feather(Rect(20, 1, image.Rect(10, 0, 20, 1)), 4)
+	if v := m.GrayAt(10, 0).Y; v == 0 || v == 255 {
+		t.Errorf("expected soft edge at boundary, got %d", v)
+	}
+	if m.GrayAt(0, 0).Y != 0 || m.GrayAt(19, 0).Y != 255 {
+		t.Error("feather changed pixels far from the edge")
+	}
+}
+
+func TestToSDImage(t *testing.T) {
+	sdImg := ToSDImage(Rect(3, 2, image.Rect(0, 0, 1, 1)))
+	if sdImg.Width != 3 || sdImg.Height != 2 || sdImg.Channel != 1 {
+		t.Fatalf("unexpected shape %dx%dx%d", sdImg.Width, sdImg.Height, sdImg.Channel)
+	}
+	data := unsafe.Slice(sdImg.Data, 6)
+	if data[0] != 255 || data[1] != 0 {
+		t.Errorf("unexpected mask data %v", data)
+	}
+}
+
+func TestOutpaint(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i := range img.Pix {
+		img.Pix[i] = 100
+	}
+
+	for _, fill := range []Fill{FillEdge, FillNoise, FillMirror} {
+		initImg, maskImg, err := Outpaint(img, 2, 1, 2, 0, fill)
+		if err != nil {
+			t.Fatalf("Outpaint failed: %v", err)
+		}
+		if initImg.Width != 7 || initImg.Height != 3 || initImg.Channel != 3 {
+			t.Errorf("unexpected init image shape %dx%dx%d", initImg.Width, initImg.Height, initImg.Channel)
+		}
+		if maskImg.Width != 7 || maskImg.Height != 3 || maskImg.Channel != 1 {
+			t.Errorf("unexpected mask shape %dx%dx%d", maskImg.Width, maskImg.Height, maskImg.Channel)
+		}
+
+		maskData := unsafe.Slice(maskImg.Data, 21)
+		masked := 0
+		for _, v := range maskData {
+			if v == 255 {
+				masked++
+			}
+		}
+		if masked != 21-6 {
+			t.Errorf("expected 15 masked pixels, got %d", masked)
+		}
+		// Pixel (2,1) is the top-left of the original image
+		if maskData[1*7+2] != 0 || unsafe.Slice(initImg.Data, 63)[(1*7+2)*3] != 100 {
+			t.Error("original image area was not preserved")
+		}
+	}
+
+	if _, _, err := Outpaint(img, -1, 0, 0, 0, FillEdge); err == nil {
+		t.Error("expected error for negative margin")
+	}
+}
+
+func TestMirror(t *testing.T) {
+	want := []int{2, 1, 0, 1, 2, 1, 0}
+	for i, w := range want {
+		if got := mirror(i-2, 3); got != w {
+			t.Errorf("mirror(%d, 3) = %d, want %d", i-2, got, w)
+		}
+	}
+}
+
+func count(m *image.Gray) int {
+	n := 0
+	for _, v := range m.Pix {
+		if v == 255 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,101 @@
+package mask
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand/v2"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// Fill selects how Outpaint seeds the new canvas area
+type Fill int
+
+const (
+	// FillEdge repeats the nearest edge pixel outward
+	FillEdge Fill = iota
+	// FillNoise fills with deterministic RGB noise
+	FillNoise
+	// FillMirror reflects the image across its edges
+	FillMirror
+)
+
+// Outpaint extends img by the given number of pixels on each side.
+// It returns the extended init image and a mask marking only the new area.
+func Outpaint(img image.Image, left, top, right, bottom int, fill Fill) (stablediffusion.SDImage, stablediffusion.SDImage, error) {
+	if left < 0 || top < 0 || right < 0 || bottom < 0 {
+		return stablediffusion.SDImage{}, stablediffusion.SDImage{}, fmt.Errorf("outpaint margins must not be negative")
+	}
+
+	canvas, err := Extend(img, left, top, right, bottom, fill)
+	if err != nil {
+		return stablediffusion.SDImage{}, stablediffusion.SDImage{}, err
+	}
+
+	bounds := img.Bounds()
+	m := Invert(Rect(canvas.Bounds().Dx(), canvas.Bounds().Dy(),
+		image.Rect(left, top, left+bounds.Dx(), top+bounds.Dy())))
+
+	return stablediffusion.ImageToSDImage(canvas), ToSDImage(m), nil
+}
+
+// Extend returns img on a larger canvas with the new area seeded by fill
+func Extend(img image.Image, left, top, right, bottom int, fill Fill) (*image.RGBA, error) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return nil, fmt.Errorf("cannot outpaint an empty image")
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, w+left+right, h+top+bottom))
+	inner := image.Rect(left, top, left+w, top+h)
+
+	var rng *rand.Rand
+	if fill == FillNoise {
+		rng = rand.New(rand.NewPCG(uint64(w), uint64(h)))
+	}
+
+	for y := 0; y < canvas.Rect.Dy(); y++ {
+		for x := 0; x < canvas.Rect.Dx(); x++ {
+			if (image.Point{X: x, Y: y}).In(inner) {
+				continue
+			}
+			switch fill {
+			case FillEdge:
+				sx := min(max(x-left, 0), w-1)
+				sy := min(max(y-top, 0), h-1)
+				canvas.Set(x, y, img.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+			case FillMirror:
+				sx := mirror(x-left, w)
+				sy := mirror(y-top, h)
+				canvas.Set(x, y, img.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+			case FillNoise:
+				v := rng.Uint32()
+				canvas.SetRGBA(x, y, color.RGBA{uint8(v), uint8(v >> 8), uint8(v >> 16), 255})
+			default:
+				return nil, fmt.Errorf("unknown fill mode: %d", fill)
+			}
+		}
+	}
+
+	draw.Draw(canvas, inner, img, bounds.Min, draw.Src)
+	return canvas, nil
+}
+
+// mirror reflects i into [0, n) without repeating the edge pixel
+func mirror(i, n int) int {
+	if n == 1 {
+		return 0
+	}
+	period := 2 * (n - 1)
+	i %= period
+	if i < 0 {
+		i += period
+	}
+	if i >= n {
+		i = period - i
+	}
+	return i
+}
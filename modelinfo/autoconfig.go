@@ -0,0 +1,242 @@
+package modelinfo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// modelExts lists the file extensions AutoConfigure inspects
+var modelExts = []string{".safetensors", ".sft", ".gguf"}
+
+// Components holds the file paths for each SDContextParams model slot
+type Components struct {
+	Model                   string `json:"model,omitempty"`
+	DiffusionModel          string `json:"diffusion_model,omitempty"`
+	HighNoiseDiffusionModel string `json:"high_noise_diffusion_model,omitempty"`
+	VAE                     string `json:"vae,omitempty"`
+	TAESD                   string `json:"taesd,omitempty"`
+	ClipL                   string `json:"clip_l,omitempty"`
+	ClipG                   string `json:"clip_g,omitempty"`
+	ClipVision              string `json:"clip_vision,omitempty"`
+	T5XXL                   string `json:"t5xxl,omitempty"`
+	LLM                     string `json:"llm,omitempty"`
+	ControlNet              string `json:"controlnet,omitempty"`
+}
+
+// Apply sets the non-empty paths on params, leaving the other fields untouched
+func (c Components) Apply(params *stablediffusion.SDContextParams) {
+	set := func(dst **uint8, path string) {
+		if path != "" {
+			*dst = stablediffusion.CString(path)
+		}
+	}
+	set(&params.ModelPath, c.Model)
+	set(&params.DiffusionModelPath, c.DiffusionModel)
+	set(&params.HighNoiseDiffusionModelPath, c.HighNoiseDiffusionModel)
+	set(&params.VAEPath, c.VAE)
+	set(&params.TAESDPath, c.TAESD)
+	set(&params.ClipLPath, c.ClipL)
+	set(&params.ClipGPath, c.ClipG)
+	set(&params.ClipVisionPath, c.ClipVision)
+	set(&params.T5XXLPath, c.T5XXL)
+	set(&params.LLMPath, c.LLM)
+	set(&params.ControlNetPath, c.ControlNet)
+}
+
+// AutoConfig is the result of AutoConfigure
+type AutoConfig struct {
+	Architecture ModelType
+	Components   Components
+	// Detections lists every inspected file
+	Detections []*Detection
+	// Missing describes required components that were not found
+	Missing []string
+	// Ignored lists files that were not assigned to a slot
+	Ignored []string
+}
+
+// Complete reports whether every required component was found
+func (c *AutoConfig) Complete() bool {
+	return c.Architecture != TypeUnknown && len(c.Missing) == 0
+}
+
+// Apply fills the model paths on params. Call ContextParamsInit on params first.
+func (c *AutoConfig) Apply(params *stablediffusion.SDContextParams) {
+	c.Components.Apply(params)
+}
+
+// AutoConfigure inspects the model files in dir and assigns them to context slots.
+// The first diffusion model in name order decides the architecture; the report
+// lists required components that are missing for that architecture.
+func AutoConfigure(dir string) (*AutoConfig, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model directory: %w", err)
+	}
+
+	cfg := &AutoConfig{Architecture: TypeUnknown}
+	for _, entry := range entries {
+		if entry.IsDir() || !slices.Contains(modelExts, strings.ToLower(filepath.Ext(entry.Name()))) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		d, err := DetectModel(path)
+		if err != nil {
+			cfg.Ignored = append(cfg.Ignored, fmt.Sprintf("%s: %v", entry.Name(), err))
+			continue
+		}
+		cfg.Detections = append(cfg.Detections, d)
+	}
+
+	var main *Detection
+	for _, d := range cfg.Detections {
+		if d.Type.IsDiffusion() {
+			main = d
+			break
+		}
+	}
+	if main == nil {
+		cfg.Missing = append(cfg.Missing, "diffusion model")
+		for _, d := range cfg.Detections {
+			cfg.Ignored = append(cfg.Ignored, filepath.Base(d.Path))
+		}
+		return cfg, nil
+	}
+	cfg.Architecture = main.Type
+
+	c := &cfg.Components
+	if usesModelPath(main) {
+		c.Model = main.Path
+	} else {
+		c.DiffusionModel = main.Path
+	}
+
+	var highNoise *Detection
+	if main.Type == TypeWan {
+		highNoise = cfg.pairHighNoise(main)
+	}
+
+	for _, d := range cfg.Detections {
+		if d == main || d == highNoise {
+			continue
+		}
+		if !cfg.assign(d) {
+			cfg.Ignored = append(cfg.Ignored, filepath.Base(d.Path))
+		}
+	}
+
+	cfg.Missing = append(cfg.Missing, missingComponents(main, *c)...)
+	return cfg, nil
+}
+
+// assign places a component into its slot, returning false when it does not fit
+func (cfg *AutoConfig) assign(d *Detection) bool {
+	c := &cfg.Components
+	slot := func(dst *string) bool {
+		if *dst != "" {
+			return false
+		}
+		*dst = d.Path
+		return true
+	}
+
+	switch d.Type {
+	case TypeVAE:
+		return slot(&c.VAE)
+	case TypeTAESD:
+		return slot(&c.TAESD)
+	case TypeCLIPL:
+		return slot(&c.ClipL)
+	case TypeCLIPG:
+		return slot(&c.ClipG)
+	case TypeCLIPVision:
+		return slot(&c.ClipVision)
+	case TypeT5XXL:
+		return slot(&c.T5XXL)
+	case TypeLLM:
+		return slot(&c.LLM)
+	case TypeControlNet:
+		return slot(&c.ControlNet)
+	}
+	return false
+}
+
+// pairHighNoise finds the Wan2.2 high-noise expert that pairs with main and
+// assigns both experts to their slots. It returns the detection that was paired.
+func (cfg *AutoConfig) pairHighNoise(main *Detection) *Detection {
+	for _, d := range cfg.Detections {
+		if d == main || d.Type != TypeWan {
+			continue
+		}
+		low, high := main, d
+		if isHighNoise(main) && !isHighNoise(d) {
+			low, high = d, main
+		}
+		if !isHighNoise(high) {
+			continue
+		}
+		cfg.Components.DiffusionModel = low.Path
+		cfg.Components.HighNoiseDiffusionModel = high.Path
+		return d
+	}
+	return nil
+}
+
+// isHighNoise reports whether a Wan2.2 expert file is named as the high-noise one
+func isHighNoise(d *Detection) bool {
+	name := strings.ToLower(filepath.Base(d.Path))
+	return strings.Contains(name, "high_noise") || strings.Contains(name, "high-noise") || strings.Contains(name, "highnoise")
+}
+
+// usesModelPath reports whether the main model belongs in ModelPath rather than DiffusionModelPath
+func usesModelPath(d *Detection) bool {
+	switch d.Type {
+	case TypeSD1, TypeSD2, TypeSDXL, TypeSVD:
+		return true
+	case TypeSD3:
+		return d.HasTextEncoder
+	}
+	return false
+}
+
+// missingComponents lists the slots the architecture needs that are still empty
+func missingComponents(main *Detection, c Components) []string {
+	var missing []string
+	need := func(path, desc string) {
+		if path == "" {
+			missing = append(missing, desc)
+		}
+	}
+
+	if !main.HasVAE {
+		need(c.VAE, "VAEPath (vae)")
+	}
+	if main.HasTextEncoder {
+		return missing
+	}
+
+	switch main.Type {
+	case TypeSDXL:
+		need(c.ClipL, "ClipLPath (clip-l text encoder)")
+		need(c.ClipG, "ClipGPath (clip-g text encoder)")
+	case TypeSD3:
+		need(c.ClipL, "ClipLPath (clip-l text encoder)")
+		need(c.ClipG, "ClipGPath (clip-g text encoder)")
+		need(c.T5XXL, "T5XXLPath (t5xxl text encoder)")
+	case TypeFlux:
+		need(c.ClipL, "ClipLPath (clip-l text encoder)")
+		need(c.T5XXL, "T5XXLPath (t5xxl text encoder)")
+	case TypeChroma:
+		need(c.T5XXL, "T5XXLPath (t5xxl text encoder)")
+	case TypeWan:
+		need(c.T5XXL, "T5XXLPath (umt5-xxl text encoder)")
+	case TypeQwenImage:
+		need(c.LLM, "LLMPath (qwen2.5-vl text encoder)")
+	}
+	return missing
+}
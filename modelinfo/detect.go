@@ -0,0 +1,203 @@
+package modelinfo
+
+import (
+	"strings"
+)
+
+// ModelType classifies a model file by what it contains
+type ModelType string
+
+const (
+	TypeUnknown ModelType = "unknown"
+
+	// Diffusion models
+	TypeSD1       ModelType = "sd1"
+	TypeSD2       ModelType = "sd2"
+	TypeSDXL      ModelType = "sdxl"
+	TypeSD3       ModelType = "sd3"
+	TypeFlux      ModelType = "flux"
+	TypeChroma    ModelType = "chroma"
+	TypeWan       ModelType = "wan"
+	TypeQwenImage ModelType = "qwen-image"
+	TypeSVD       ModelType = "svd"
+
+	// Components
+	TypeVAE        ModelType = "vae"
+	TypeTAESD      ModelType = "taesd"
+	TypeCLIPL      ModelType = "clip-l"
+	TypeCLIPG      ModelType = "clip-g"
+	TypeCLIPVision ModelType = "clip-vision"
+	TypeT5XXL      ModelType = "t5xxl"
+	TypeLLM        ModelType = "llm"
+	TypeControlNet ModelType = "controlnet"
+	TypeLoRA       ModelType = "lora"
+	TypeESRGAN     ModelType = "esrgan"
+)
+
+// IsDiffusion reports whether t is a diffusion model rather than a component
+func (t ModelType) IsDiffusion() bool {
+	switch t {
+	case TypeSD1, TypeSD2, TypeSDXL, TypeSD3, TypeFlux, TypeChroma, TypeWan, TypeQwenImage, TypeSVD:
+		return true
+	}
+	return false
+}
+
+// Detection is the result of DetectModel
+type Detection struct {
+	Path string
+	Type ModelType
+	// HasVAE and HasTextEncoder are set for checkpoints that bundle those components
+	HasVAE         bool
+	HasTextEncoder bool
+	Info           *Info
+}
+
+// diffusionPrefix is the tensor prefix used by full checkpoints
+const diffusionPrefix = "model.diffusion_model."
+
+// DetectModel inspects the tensor names in path and classifies the file
+func DetectModel(path string) (*Detection, error) {
+	info, err := Inspect(path)
+	if err != nil {
+		return nil, err
+	}
+	d := Classify(info)
+	d.Path = path
+	return d, nil
+}
+
+// Classify classifies an already inspected model
+func Classify(info *Info) *Detection {
+	names := newNameSet(info)
+	d := &Detection{Path: info.Path, Info: info, Type: TypeUnknown}
+
+	d.HasVAE = names.hasPrefix("first_stage_model.") || names.hasPrefix("vae.")
+	d.HasTextEncoder = names.hasPrefix("cond_stage_model.") || names.hasPrefix("conditioner.") ||
+		names.hasPrefix("text_encoders.")
+
+	switch {
+	case names.contains("lora_up.") || names.contains("lora_down.") ||
+		names.contains("lora_A.") || names.contains("lora_B.") || names.hasPrefix("lora_"):
+		d.Type = TypeLoRA
+	case names.hasPrefix("control_model.") || names.hasDiffusion("input_hint_block.") || names.hasDiffusion("zero_convs."):
+		d.Type = TypeControlNet
+	case names.hasDiffusion("distilled_guidance_layer."):
+		d.Type = TypeChroma
+	case names.hasDiffusion("double_blocks.") && names.hasDiffusion("single_blocks."):
+		d.Type = TypeFlux
+	case names.hasDiffusion("joint_blocks."):
+		d.Type = TypeSD3
+	case names.hasDiffusion("transformer_blocks.") && names.hasDiffusion("img_in."):
+		d.Type = TypeQwenImage
+	case names.hasDiffusion("patch_embedding.") && names.hasDiffusion("blocks."):
+		d.Type = TypeWan
+	case names.hasDiffusion("input_blocks."):
+		d.Type = classifyUNet(names)
+	case names.hasPrefix("conv_first.") || (names.has("model.0.weight") && names.hasPrefix("model.1.sub.")):
+		d.Type = TypeESRGAN
+	case names.hasPrefix("encoder.block.") || names.hasPrefix("enc.blk.") || names.hasPrefix("shared."):
+		d.Type = TypeT5XXL
+	// LLM text encoders such as Qwen2.5-VL carry a vision tower, so check them before CLIP vision
+	case names.hasPrefix("model.layers.") || names.hasPrefix("layers.") || names.hasPrefix("blk."):
+		d.Type = TypeLLM
+	case names.hasPrefix("vision_model.") || names.contains("visual.blocks."):
+		d.Type = TypeCLIPVision
+	case names.contains("text_model.encoder.layers.") || names.contains("transformer.resblocks."):
+		d.Type = classifyCLIP(names)
+	case names.contains("decoder.up.") || names.contains("decoder.up_blocks.") || names.contains("decoder.upsamples."):
+		d.Type = TypeVAE
+	case names.hasPrefix("decoder.layers.") || names.hasPrefix("encoder.layers."):
+		d.Type = TypeTAESD
+	}
+
+	return d
+}
+
+// classifyUNet separates the UNet families by conditioning layers and context width
+func classifyUNet(names nameSet) ModelType {
+	switch {
+	case names.hasDiffusion("time_stack.") || names.contains("time_mixer."):
+		return TypeSVD
+	case names.hasDiffusion("label_emb.") || names.hasPrefix("conditioner.embedders.1."):
+		return TypeSDXL
+	case names.hasPrefix("cond_stage_model.model."):
+		return TypeSD2
+	}
+
+	// The cross-attention key projection maps the text encoder width: 768 for SD1, 1024 for SD2
+	for _, t := range names.info.Tensors {
+		if strings.HasSuffix(t.Name, "input_blocks.1.1.transformer_blocks.0.attn2.to_k.weight") {
+			for _, dim := range t.Shape {
+				if dim == 1024 {
+					return TypeSD2
+				}
+			}
+		}
+	}
+	return TypeSD1
+}
+
+// classifyCLIP separates CLIP-L from CLIP-G by hidden size
+func classifyCLIP(names nameSet) ModelType {
+	for _, t := range names.info.Tensors {
+		if strings.HasSuffix(t.Name, "token_embedding.weight") {
+			for _, dim := range t.Shape {
+				if dim == 1280 {
+					return TypeCLIPG
+				}
+			}
+			return TypeCLIPL
+		}
+	}
+	if names.contains("encoder.layers.31.") {
+		return TypeCLIPG
+	}
+	return TypeCLIPL
+}
+
+// nameSet answers prefix and substring queries over tensor names
+type nameSet struct {
+	info  *Info
+	names []string
+}
+
+func newNameSet(info *Info) nameSet {
+	names := make([]string, len(info.Tensors))
+	for i, t := range info.Tensors {
+		names[i] = t.Name
+	}
+	return nameSet{info: info, names: names}
+}
+
+func (s nameSet) has(name string) bool {
+	for _, n := range s.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s nameSet) hasPrefix(prefix string) bool {
+	for _, n := range s.names {
+		if strings.HasPrefix(n, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s nameSet) contains(substr string) bool {
+	for _, n := range s.names {
+		if strings.Contains(n, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDiffusion matches prefix with or without the full-checkpoint diffusion prefix
+func (s nameSet) hasDiffusion(prefix string) bool {
+	return s.hasPrefix(prefix) || s.hasPrefix(diffusionPrefix+prefix)
+}
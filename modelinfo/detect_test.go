@@ -0,0 +1,154 @@
+package modelinfo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// fakeModel writes a safetensors header with the given tensor names and shapes
+func fakeModel(t *testing.T, dir, name string, tensors map[string][]int) string {
+	t.Helper()
+	header := make(map[string]any)
+	for tensor, shape := range tensors {
+		if shape == nil {
+			shape = []int{1}
+		}
+		header[tensor] = map[string]any{"dtype": "F16", "shape": shape, "data_offsets": []int{0, 0}}
+	}
+	path := filepath.Join(dir, name)
+	writeSafetensors(t, path, header, 0)
+	return path
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name    string
+		tensors map[string][]int
+		want    ModelType
+	}{
+		{"sd1", map[string][]int{
+			"model.diffusion_model.input_blocks.1.1.transformer_blocks.0.attn2.to_k.weight": {320, 768},
+			"cond_stage_model.transformer.text_model.encoder.layers.0.mlp.fc1.weight":       nil,
+		}, TypeSD1},
+		{"sd2", map[string][]int{
+			"model.diffusion_model.input_blocks.1.1.transformer_blocks.0.attn2.to_k.weight": {320, 1024},
+		}, TypeSD2},
+		{"sdxl", map[string][]int{
+			"model.diffusion_model.input_blocks.0.0.weight": nil,
+			"model.diffusion_model.label_emb.0.0.weight":    nil,
+		}, TypeSDXL},
+		{"svd", map[string][]int{
+			"model.diffusion_model.input_blocks.0.0.weight":                   nil,
+			"model.diffusion_model.input_blocks.1.0.time_mixer.mix_factor":    nil,
+			"model.diffusion_model.input_blocks.1.0.time_stack.in_layers.0.w": nil,
+		}, TypeSVD},
+		{"sd3", map[string][]int{"model.diffusion_model.joint_blocks.0.x_block.attn.qkv.weight": nil}, TypeSD3},
+		{"flux", map[string][]int{"double_blocks.0.img_attn.qkv.weight": nil, "single_blocks.0.linear1.weight": nil}, TypeFlux},
+		{"chroma", map[string][]int{
+			"double_blocks.0.img_attn.qkv.weight":     nil,
+			"single_blocks.0.linear1.weight":          nil,
+			"distilled_guidance_layer.in_proj.weight": nil,
+		}, TypeChroma},
+		{"wan", map[string][]int{"patch_embedding.weight": nil, "blocks.0.self_attn.q.weight": nil}, TypeWan},
+		{"qwen", map[string][]int{"img_in.weight": nil, "transformer_blocks.0.img_mod.1.weight": nil}, TypeQwenImage},
+		{"vae", map[string][]int{"decoder.up.0.block.0.conv1.weight": nil, "encoder.down.0.block.0.conv1.weight": nil}, TypeVAE},
+		{"taesd", map[string][]int{"decoder.layers.0.weight": nil}, TypeTAESD},
+		{"clip-l", map[string][]int{
+			"text_model.encoder.layers.0.mlp.fc1.weight":   nil,
+			"text_model.embeddings.token_embedding.weight": {49408, 768},
+		}, TypeCLIPL},
+		{"clip-g", map[string][]int{
+			"text_model.encoder.layers.0.mlp.fc1.weight":   nil,
+			"text_model.embeddings.token_embedding.weight": {49408, 1280},
+		}, TypeCLIPG},
+		{"t5", map[string][]int{"encoder.block.0.layer.0.SelfAttention.q.weight": nil, "shared.weight": nil}, TypeT5XXL},
+		{"llm", map[string][]int{"model.layers.0.self_attn.q_proj.weight": nil}, TypeLLM},
+		{"vision llm", map[string][]int{"model.layers.0.self_attn.q_proj.weight": nil, "visual.blocks.0.attn.qkv.weight": nil}, TypeLLM},
+		{"controlnet", map[string][]int{"control_model.input_hint_block.0.weight": nil}, TypeControlNet},
+		{"lora", map[string][]int{"lora_unet_down_blocks_0_attentions_0_proj_in.lora_down.weight": nil}, TypeLoRA},
+		{"esrgan", map[string][]int{"conv_first.weight": nil, "body.0.rdb1.conv1.weight": nil}, TypeESRGAN},
+		{"unknown", map[string][]int{"something.weight": nil}, TypeUnknown},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := fakeModel(t, dir, tt.name+".safetensors", tt.tensors)
+		d, err := DetectModel(path)
+		if err != nil {
+			t.Fatalf("%s: DetectModel failed: %v", tt.name, err)
+		}
+		if d.Type != tt.want {
+			t.Errorf("%s: detected %s, want %s", tt.name, d.Type, tt.want)
+		}
+	}
+}
+
+func TestAutoConfigureFlux(t *testing.T) {
+	dir := t.TempDir()
+	flux := fakeModel(t, dir, "a-flux1-schnell.safetensors", map[string][]int{
+		"double_blocks.0.img_attn.qkv.weight": nil,
+		"single_blocks.0.linear1.weight":      nil,
+	})
+	ae := fakeModel(t, dir, "ae.safetensors", map[string][]int{"decoder.up.0.block.0.conv1.weight": nil})
+	clip := fakeModel(t, dir, "clip_l.safetensors", map[string][]int{"text_model.encoder.layers.0.mlp.fc1.weight": nil})
+	os.WriteFile(filepath.Join(dir, "readme.txt"), []byte("notes"), 0644)
+
+	cfg, err := AutoConfigure(dir)
+	if err != nil {
+		t.Fatalf("AutoConfigure failed: %v", err)
+	}
+
+	if cfg.Architecture != TypeFlux {
+		t.Errorf("architecture %s, want flux", cfg.Architecture)
+	}
+	want := Components{DiffusionModel: flux, VAE: ae, ClipL: clip}
+	if cfg.Components != want {
+		t.Errorf("components %+v, want %+v", cfg.Components, want)
+	}
+	if !reflect.DeepEqual(cfg.Missing, []string{"T5XXLPath (t5xxl text encoder)"}) || cfg.Complete() {
+		t.Errorf("unexpected missing report %v", cfg.Missing)
+	}
+
+	var params stablediffusion.SDContextParams
+	cfg.Apply(&params)
+	if stablediffusion.CGoString(params.DiffusionModelPath) != flux || params.ModelPath != nil || params.T5XXLPath != nil {
+		t.Error("Apply set the wrong slots")
+	}
+}
+
+func TestAutoConfigureWanHighNoise(t *testing.T) {
+	dir := t.TempDir()
+	wan := map[string][]int{"patch_embedding.weight": nil, "blocks.0.self_attn.q.weight": nil}
+	high := fakeModel(t, dir, "wan2.2_high_noise.safetensors", wan)
+	low := fakeModel(t, dir, "wan2.2_low_noise.safetensors", wan)
+	fakeModel(t, dir, "umt5.safetensors", map[string][]int{"encoder.block.0.layer.0.SelfAttention.q.weight": nil})
+	fakeModel(t, dir, "wan_vae.safetensors", map[string][]int{"decoder.upsamples.0.weight": nil})
+
+	cfg, err := AutoConfigure(dir)
+	if err != nil {
+		t.Fatalf("AutoConfigure failed: %v", err)
+	}
+	if cfg.Components.DiffusionModel != low || cfg.Components.HighNoiseDiffusionModel != high {
+		t.Errorf("experts not paired: %+v", cfg.Components)
+	}
+	if !cfg.Complete() {
+		t.Errorf("expected complete config, missing %v", cfg.Missing)
+	}
+}
+
+func TestAutoConfigureNoDiffusionModel(t *testing.T) {
+	dir := t.TempDir()
+	fakeModel(t, dir, "ae.safetensors", map[string][]int{"decoder.up.0.block.0.conv1.weight": nil})
+
+	cfg, err := AutoConfigure(dir)
+	if err != nil {
+		t.Fatalf("AutoConfigure failed: %v", err)
+	}
+	if cfg.Complete() || cfg.Architecture != TypeUnknown || len(cfg.Ignored) != 1 {
+		t.Errorf("unexpected report %+v", cfg)
+	}
+}
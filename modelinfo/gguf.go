@@ -0,0 +1,207 @@
+package modelinfo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+const ggufMagic = "GGUF"
+
+// Limits guarding against corrupt headers
+const (
+	maxGGUFString = 1 << 20
+	maxGGUFArray  = 1 << 24
+	maxGGUFCount  = 1 << 24
+	maxGGUFDims   = 8
+	// ggufPrealloc caps capacity taken from header counts, so a few corrupt
+	// bytes cannot force a large allocation before the data runs out
+	ggufPrealloc = 1024
+)
+
+// GGUF metadata value types
+const (
+	ggufUint8 uint32 = iota
+	ggufInt8
+	ggufUint16
+	ggufInt16
+	ggufUint32
+	ggufInt32
+	ggufFloat32
+	ggufBool
+	ggufString
+	ggufArray
+	ggufUint64
+	ggufInt64
+	ggufFloat64
+)
+
+// ReadGGUF parses GGUF metadata and the tensor table from r
+func ReadGGUF(r io.Reader) (*Info, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, fmt.Errorf("failed to read GGUF magic: %v", err)
+	}
+	if string(magic) != ggufMagic {
+		return nil, fmt.Errorf("not a GGUF file")
+	}
+
+	version, err := readUint32(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GGUF version: %v", err)
+	}
+	if version < 2 || version > 3 {
+		return nil, fmt.Errorf("unsupported GGUF version %d", version)
+	}
+
+	tensorCount, err := readUint64(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GGUF tensor count: %v", err)
+	}
+	kvCount, err := readUint64(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GGUF metadata count: %v", err)
+	}
+	if tensorCount > maxGGUFCount || kvCount > maxGGUFCount {
+		return nil, fmt.Errorf("GGUF header counts out of range")
+	}
+
+	info := &Info{Format: FormatGGUF, Metadata: make(map[string]any, min(kvCount, ggufPrealloc))}
+	for i := uint64(0); i < kvCount; i++ {
+		key, err := readGGUFString(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata key %d: %v", i, err)
+		}
+		typ, err := readUint32(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata type for %q: %v", key, err)
+		}
+		value, err := readGGUFValue(r, typ)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata %q: %v", key, err)
+		}
+		info.Metadata[key] = value
+	}
+
+	info.Tensors = make([]Tensor, 0, min(tensorCount, ggufPrealloc))
+	for i := uint64(0); i < tensorCount; i++ {
+		name, err := readGGUFString(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tensor name %d: %v", i, err)
+		}
+		nDims, err := readUint32(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dims for %q: %v", name, err)
+		}
+		if nDims > maxGGUFDims {
+			return nil, fmt.Errorf("tensor %q has %d dims", name, nDims)
+		}
+		shape := make([]uint64, nDims)
+		for d := range shape {
+			if shape[d], err = readUint64(r); err != nil {
+				return nil, fmt.Errorf("failed to read shape for %q: %v", name, err)
+			}
+		}
+		ggmlType, err := readUint32(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read type for %q: %v", name, err)
+		}
+		if _, err := readUint64(r); err != nil {
+			return nil, fmt.Errorf("failed to read offset for %q: %v", name, err)
+		}
+
+		typ := stablediffusion.SDType(ggmlType)
+		dtype := typ.String()
+		if !typ.Valid() {
+			typ = UnknownType
+		}
+		info.Tensors = append(info.Tensors, Tensor{Name: name, DType: dtype, Type: typ, Shape: shape})
+	}
+
+	sortTensors(info.Tensors)
+	return info, nil
+}
+
+func readGGUFString(r io.Reader) (string, error) {
+	n, err := readUint64(r)
+	if err != nil {
+		return "", err
+	}
+	if n > maxGGUFString {
+		return "", fmt.Errorf("string length %d out of range", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func readGGUFValue(r io.Reader, typ uint32) (any, error) {
+	switch typ {
+	case ggufUint8:
+		var v uint8
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case ggufInt8:
+		var v int8
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case ggufUint16:
+		var v uint16
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case ggufInt16:
+		var v int16
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case ggufUint32:
+		return readUint32(r)
+	case ggufInt32:
+		v, err := readUint32(r)
+		return int32(v), err
+	case ggufFloat32:
+		v, err := readUint32(r)
+		return math.Float32frombits(v), err
+	case ggufBool:
+		var v uint8
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v != 0, err
+	case ggufString:
+		return readGGUFString(r)
+	case ggufArray:
+		elemType, err := readUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		n, err := readUint64(r)
+		if err != nil {
+			return nil, err
+		}
+		if n > maxGGUFArray {
+			return nil, fmt.Errorf("array length %d out of range", n)
+		}
+		values := make([]any, 0, min(n, ggufPrealloc))
+		for range n {
+			v, err := readGGUFValue(r, elemType)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case ggufUint64:
+		return readUint64(r)
+	case ggufInt64:
+		v, err := readUint64(r)
+		return int64(v), err
+	case ggufFloat64:
+		v, err := readUint64(r)
+		return math.Float64frombits(v), err
+	default:
+		return nil, fmt.Errorf("unknown value type %d", typ)
+	}
+}
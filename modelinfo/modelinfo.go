@@ -0,0 +1,167 @@
+// Package modelinfo reads safetensors and GGUF headers without loading weights.
+package modelinfo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// Format identifies a model container format
+type Format int
+
+const (
+	FormatUnknown Format = iota
+	FormatSafetensors
+	FormatGGUF
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatSafetensors:
+		return "safetensors"
+	case FormatGGUF:
+		return "gguf"
+	default:
+		return "unknown"
+	}
+}
+
+// UnknownType marks a tensor whose dtype has no SDType equivalent
+const UnknownType stablediffusion.SDType = -1
+
+// Tensor describes one tensor entry in a model header
+type Tensor struct {
+	Name string
+	// DType is the dtype as written in the file, e.g. "F16" or "q4_K"
+	DType string
+	// Type is the matching SDType, or UnknownType
+	Type  stablediffusion.SDType
+	Shape []uint64
+}
+
+// Params returns the number of elements in the tensor
+func (t Tensor) Params() uint64 {
+	n := uint64(1)
+	for _, d := range t.Shape {
+		n *= d
+	}
+	return n
+}
+
+// Info summarizes a model file
+type Info struct {
+	Path     string
+	Format   Format
+	FileSize int64
+	Tensors  []Tensor
+	// Metadata holds safetensors __metadata__ strings or GGUF key/value pairs
+	Metadata map[string]any
+}
+
+// TensorCount returns the number of tensors
+func (info *Info) TensorCount() int {
+	return len(info.Tensors)
+}
+
+// ParamCount returns the total number of parameters across all tensors
+func (info *Info) ParamCount() uint64 {
+	var n uint64
+	for _, t := range info.Tensors {
+		n += t.Params()
+	}
+	return n
+}
+
+// DTypes counts tensors per file dtype
+func (info *Info) DTypes() map[string]int {
+	counts := make(map[string]int)
+	for _, t := range info.Tensors {
+		counts[t.DType]++
+	}
+	return counts
+}
+
+// Types counts tensors per SDType, skipping dtypes without an equivalent
+func (info *Info) Types() map[stablediffusion.SDType]int {
+	counts := make(map[stablediffusion.SDType]int)
+	for _, t := range info.Tensors {
+		if t.Type != UnknownType {
+			counts[t.Type]++
+		}
+	}
+	return counts
+}
+
+// TensorNames returns all tensor names in sorted order
+func (info *Info) TensorNames() []string {
+	names := make([]string, len(info.Tensors))
+	for i, t := range info.Tensors {
+		names[i] = t.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Inspect reads the header of a safetensors or GGUF file
+func Inspect(path string) (*Info, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open model file: %v", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close file: %v", err)
+		}
+	}()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat model file: %v", err)
+	}
+
+	r := bufio.NewReader(file)
+	magic, err := r.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model header: %v", err)
+	}
+
+	var info *Info
+	if string(magic) == ggufMagic {
+		info, err = ReadGGUF(r)
+	} else {
+		info, err = ReadSafetensors(r, stat.Size())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	info.Path = path
+	info.FileSize = stat.Size()
+	return info, nil
+}
+
+// sortTensors orders tensors by name so output is stable
+func sortTensors(tensors []Tensor) {
+	sort.Slice(tensors, func(i, j int) bool { return tensors[i].Name < tensors[j].Name })
+}
+
+// readUint64 reads a little-endian uint64
+func readUint64(r io.Reader) (uint64, error) {
+	var v uint64
+	err := binary.Read(r, binary.LittleEndian, &v)
+	return v, err
+}
+
+// readUint32 reads a little-endian uint32
+func readUint32(r io.Reader) (uint32, error) {
+	var v uint32
+	err := binary.Read(r, binary.LittleEndian, &v)
+	return v, err
+}
@@ -0,0 +1,191 @@
+package modelinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+func writeSafetensors(t *testing.T, path string, header map[string]any, dataSize int) {
+	t.Helper()
+	raw, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint64(len(raw)))
+	buf.Write(raw)
+	buf.Write(make([]byte, dataSize))
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type ggufTensor struct {
+	name  string
+	shape []uint64
+	typ   uint32
+}
+
+func writeGGUF(t *testing.T, path string, kv map[string]string, tensors []ggufTensor) {
+	t.Helper()
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	str := func(s string) {
+		binary.Write(&buf, le, uint64(len(s)))
+		buf.WriteString(s)
+	}
+
+	buf.WriteString("GGUF")
+	binary.Write(&buf, le, uint32(3))
+	binary.Write(&buf, le, uint64(len(tensors)))
+	binary.Write(&buf, le, uint64(len(kv)+1))
+	for k, v := range kv {
+		str(k)
+		binary.Write(&buf, le, ggufString)
+		str(v)
+	}
+	str("general.file_type")
+	binary.Write(&buf, le, ggufArray)
+	binary.Write(&buf, le, ggufUint32)
+	binary.Write(&buf, le, uint64(2))
+	binary.Write(&buf, le, uint32(1))
+	binary.Write(&buf, le, uint32(2))
+
+	for _, tensor := range tensors {
+		str(tensor.name)
+		binary.Write(&buf, le, uint32(len(tensor.shape)))
+		for _, d := range tensor.shape {
+			binary.Write(&buf, le, d)
+		}
+		binary.Write(&buf, le, tensor.typ)
+		binary.Write(&buf, le, uint64(0))
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInspectSafetensors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.safetensors")
+	writeSafetensors(t, path, map[string]any{
+		"__metadata__": map[string]string{"ss_base_model_version": "sdxl_base_v1-0"},
+		"b.weight":     map[string]any{"dtype": "F16", "shape": []int{4, 8}, "data_offsets": []int{0, 64}},
+		"a.bias":       map[string]any{"dtype": "F32", "shape": []int{8}, "data_offsets": []int{64, 96}},
+		"c.scale":      map[string]any{"dtype": "F8_E4M3", "shape": []int{2}, "data_offsets": []int{96, 98}},
+	}, 98)
+
+	info, err := Inspect(path)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+
+	if info.Format != FormatSafetensors || info.TensorCount() != 3 {
+		t.Fatalf("unexpected format %v with %d tensors", info.Format, info.TensorCount())
+	}
+	if info.Tensors[0].Name != "a.bias" {
+		t.Errorf("tensors should be sorted, got %s first", info.Tensors[0].Name)
+	}
+	if info.ParamCount() != 32+8+2 {
+		t.Errorf("unexpected param count %d", info.ParamCount())
+	}
+	types := info.Types()
+	if types[stablediffusion.SDTypeF16] != 1 || types[stablediffusion.SDTypeF32] != 1 || len(types) != 2 {
+		t.Errorf("unexpected types %v", types)
+	}
+	if info.DTypes()["F8_E4M3"] != 1 || info.Tensors[2].Type != UnknownType {
+		t.Error("unmapped dtype should be kept as UnknownType")
+	}
+	if info.Metadata["ss_base_model_version"] != "sdxl_base_v1-0" {
+		t.Errorf("unexpected metadata %v", info.Metadata)
+	}
+	if stat, _ := os.Stat(path); info.FileSize != stat.Size() {
+		t.Errorf("unexpected file size %d", info.FileSize)
+	}
+}
+
+func TestInspectSafetensorsInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	truncated := filepath.Join(dir, "truncated.safetensors")
+	writeSafetensors(t, truncated, map[string]any{
+		"w": map[string]any{"dtype": "F16", "shape": []int{4}, "data_offsets": []int{0, 8}},
+	}, 4)
+	if _, err := Inspect(truncated); err == nil {
+		t.Error("expected error for tensor past end of file")
+	}
+
+	garbage := filepath.Join(dir, "garbage.bin")
+	os.WriteFile(garbage, []byte("this is not a model file at all"), 0644)
+	if _, err := Inspect(garbage); err == nil {
+		t.Error("expected error for garbage file")
+	}
+}
+
+func TestInspectGGUF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.gguf")
+	writeGGUF(t, path, map[string]string{"general.architecture": "flux"}, []ggufTensor{
+		{"model.diffusion_model.img_in.weight", []uint64{64, 3072}, uint32(stablediffusion.SDTypeQ4_0)},
+		{"model.diffusion_model.img_in.bias", []uint64{3072}, uint32(stablediffusion.SDTypeF32)},
+	})
+
+	info, err := Inspect(path)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+
+	if info.Format != FormatGGUF || info.TensorCount() != 2 {
+		t.Fatalf("unexpected format %v with %d tensors", info.Format, info.TensorCount())
+	}
+	if info.ParamCount() != 64*3072+3072 {
+		t.Errorf("unexpected param count %d", info.ParamCount())
+	}
+	if info.DTypes()["q4_0"] != 1 || info.Types()[stablediffusion.SDTypeQ4_0] != 1 {
+		t.Errorf("unexpected dtypes %v", info.DTypes())
+	}
+	if info.Metadata["general.architecture"] != "flux" {
+		t.Errorf("unexpected metadata %v", info.Metadata)
+	}
+	if arr, ok := info.Metadata["general.file_type"].([]any); !ok || len(arr) != 2 {
+		t.Errorf("array metadata not decoded: %v", info.Metadata["general.file_type"])
+	}
+}
+
+func TestReadGGUFTruncated(t *testing.T) {
+	data := []byte("GGUF\x03\x00\x00\x00\x01\x00")
+	if _, err := ReadGGUF(bytes.NewReader(data)); err == nil {
+		t.Error("expected error for truncated header")
+	}
+}
+
+func TestReadGGUFHugeCounts(t *testing.T) {
+	// Maximum tensor and metadata counts, then an array header claiming the
+	// maximum length, with no data behind any of them
+	var buf bytes.Buffer
+	buf.WriteString("GGUF")
+	binary.Write(&buf, binary.LittleEndian, uint32(3))
+	binary.Write(&buf, binary.LittleEndian, uint64(maxGGUFCount))
+	binary.Write(&buf, binary.LittleEndian, uint64(maxGGUFCount))
+	binary.Write(&buf, binary.LittleEndian, uint64(1))
+	buf.WriteString("k")
+	binary.Write(&buf, binary.LittleEndian, ggufArray)
+	binary.Write(&buf, binary.LittleEndian, ggufUint64)
+	binary.Write(&buf, binary.LittleEndian, uint64(maxGGUFArray))
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	before := stats.TotalAlloc
+	if _, err := ReadGGUF(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Error("expected error for truncated data")
+	}
+	runtime.ReadMemStats(&stats)
+	if grown := stats.TotalAlloc - before; grown > 1<<20 {
+		t.Errorf("reading a %d byte file allocated %d bytes", buf.Len(), grown)
+	}
+}
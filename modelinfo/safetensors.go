@@ -0,0 +1,99 @@
+package modelinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// maxSafetensorsHeader bounds the JSON header size to guard against corrupt files
+const maxSafetensorsHeader = 256 << 20
+
+// safetensorsTypes maps safetensors dtypes to SDType
+var safetensorsTypes = map[string]stablediffusion.SDType{
+	"F64":  stablediffusion.SDTypeF64,
+	"F32":  stablediffusion.SDTypeF32,
+	"F16":  stablediffusion.SDTypeF16,
+	"BF16": stablediffusion.SDTypeBF16,
+	"I64":  stablediffusion.SDTypeI64,
+	"I32":  stablediffusion.SDTypeI32,
+	"I16":  stablediffusion.SDTypeI16,
+	"I8":   stablediffusion.SDTypeI8,
+}
+
+type safetensorsEntry struct {
+	DType       string   `json:"dtype"`
+	Shape       []uint64 `json:"shape"`
+	DataOffsets []uint64 `json:"data_offsets"`
+}
+
+// ReadSafetensors parses a safetensors header from r.
+// size is the total file size used to validate tensor offsets; pass 0 to skip the check.
+func ReadSafetensors(r io.Reader, size int64) (*Info, error) {
+	headerLen, err := readUint64(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read safetensors header length: %v", err)
+	}
+	if headerLen < 2 || headerLen > maxSafetensorsHeader {
+		return nil, fmt.Errorf("not a safetensors file: header length %d", headerLen)
+	}
+	if size > 0 && int64(headerLen)+8 > size {
+		return nil, fmt.Errorf("safetensors header length %d exceeds file size", headerLen)
+	}
+
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read safetensors header: %v", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(header, &raw); err != nil {
+		return nil, fmt.Errorf("invalid safetensors header: %v", err)
+	}
+
+	info := &Info{Format: FormatSafetensors, Metadata: make(map[string]any)}
+	dataSize := uint64(0)
+	if size > 0 {
+		dataSize = uint64(size) - 8 - headerLen
+	}
+
+	for name, msg := range raw {
+		if name == "__metadata__" {
+			var meta map[string]string
+			if err := json.Unmarshal(msg, &meta); err != nil {
+				return nil, fmt.Errorf("invalid __metadata__: %v", err)
+			}
+			for k, v := range meta {
+				info.Metadata[k] = v
+			}
+			continue
+		}
+
+		var entry safetensorsEntry
+		if err := json.Unmarshal(msg, &entry); err != nil {
+			return nil, fmt.Errorf("invalid tensor entry %q: %v", name, err)
+		}
+		if len(entry.DataOffsets) != 2 || entry.DataOffsets[0] > entry.DataOffsets[1] {
+			return nil, fmt.Errorf("invalid data offsets for tensor %q", name)
+		}
+		if size > 0 && entry.DataOffsets[1] > dataSize {
+			return nil, fmt.Errorf("tensor %q extends past end of file", name)
+		}
+
+		typ, ok := safetensorsTypes[entry.DType]
+		if !ok {
+			typ = UnknownType
+		}
+		info.Tensors = append(info.Tensors, Tensor{
+			Name:  name,
+			DType: entry.DType,
+			Type:  typ,
+			Shape: entry.Shape,
+		})
+	}
+
+	sortTensors(info.Tensors)
+	return info, nil
+}
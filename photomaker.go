@@ -0,0 +1,237 @@
+package stablediffusion
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+const (
+	// DefaultPhotoMakerTrigger is the trigger word PhotoMaker models are trained with
+	DefaultPhotoMakerTrigger = "img"
+	// PhotoMakerEmbedFile is the ID embedding file LoadPhotoMakerDir picks up
+	PhotoMakerEmbedFile = "id_embeds.bin"
+
+	defaultPhotoMakerSize  = 512
+	defaultStyleStrength   = 20
+	photoMakerSizeMultiple = 8
+)
+
+// photoMakerExts are the face photo extensions picked up by LoadPhotoMakerDir
+var photoMakerExts = []string{".png", ".jpg", ".jpeg"}
+
+// PhotoMaker holds prepared face photos and the ID embedding for PhotoMaker
+// generation. It is built once and reused across generations.
+type PhotoMaker struct {
+	// TriggerWord must appear in the prompt. Defaults to "img".
+	TriggerWord string
+	// StyleStrength is the PhotoMaker style strength in percent. Defaults to 20.
+	StyleStrength float32
+
+	images    []SDImage
+	embedPath string
+	embedC    *uint8
+
+	// triggerRe matches triggerFor and is rebuilt only when TriggerWord changes
+	triggerRe  *regexp.Regexp
+	triggerFor string
+}
+
+// NewPhotoMaker center-crops and resizes faces to size x size squares.
+// size defaults to 512 and is rounded down to a multiple of 8.
+func NewPhotoMaker(faces []image.Image, size int) (*PhotoMaker, error) {
+	if len(faces) == 0 {
+		return nil, fmt.Errorf("no face images")
+	}
+	if size <= 0 {
+		size = defaultPhotoMakerSize
+	}
+	size = size / photoMakerSizeMultiple * photoMakerSizeMultiple
+	if size == 0 {
+		return nil, fmt.Errorf("face image size must be at least %d", photoMakerSizeMultiple)
+	}
+
+	pm := &PhotoMaker{
+		TriggerWord:   DefaultPhotoMakerTrigger,
+		StyleStrength: defaultStyleStrength,
+		images:        make([]SDImage, len(faces)),
+	}
+	for i, face := range faces {
+		if face == nil {
+			return nil, fmt.Errorf("face image %d is nil", i)
+		}
+		if b := face.Bounds(); b.Empty() {
+			return nil, fmt.Errorf("face image %d is empty", i)
+		}
+		pm.images[i] = ImageToSDImage(FitImage(face, size, size, FitCrop))
+	}
+	return pm, nil
+}
+
+// LoadPhotoMaker reads face photos from paths and prepares them with NewPhotoMaker
+func LoadPhotoMaker(paths []string, size int) (*PhotoMaker, error) {
+	faces := make([]image.Image, len(paths))
+	for i, path := range paths {
+		img, err := decodeImageFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load face image %s: %w", path, err)
+		}
+		faces[i] = img
+	}
+	return NewPhotoMaker(faces, size)
+}
+
+// LoadPhotoMakerDir loads every face photo in dir in name order and uses
+// dir/id_embeds.bin as the ID embedding when it exists
+func LoadPhotoMakerDir(dir string, size int) (*PhotoMaker, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read face image directory: %w", err)
+	}
+
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() && slices.Contains(photoMakerExts, strings.ToLower(filepath.Ext(e.Name()))) {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no face images in %s", dir)
+	}
+
+	pm, err := LoadPhotoMaker(paths, size)
+	if err != nil {
+		return nil, err
+	}
+	embed := filepath.Join(dir, PhotoMakerEmbedFile)
+	if _, err := os.Stat(embed); err == nil {
+		if err := pm.SetIDEmbedding(embed); err != nil {
+			return nil, err
+		}
+	}
+	return pm, nil
+}
+
+// SetIDEmbedding sets the ID embedding file used by PhotoMaker v2.
+// The path is resolved and checked once and reused by every Apply.
+func (pm *PhotoMaker) SetIDEmbedding(path string) error {
+	if path == "" {
+		pm.embedPath, pm.embedC = "", nil
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve ID embedding path: %w", err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return fmt.Errorf("failed to stat ID embedding: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("ID embedding %s is a directory", abs)
+	}
+	pm.embedPath, pm.embedC = abs, CString(abs)
+	return nil
+}
+
+// IDEmbedding returns the ID embedding file, or "" when none is set
+func (pm *PhotoMaker) IDEmbedding() string {
+	return pm.embedPath
+}
+
+// Len returns the number of prepared face images
+func (pm *PhotoMaker) Len() int {
+	return len(pm.images)
+}
+
+// Validate checks that ctx was created with a PhotoMaker model and that
+// prompt contains the trigger word
+func (pm *PhotoMaker) Validate(ctx *SDContext, prompt string) error {
+	if ctx == nil || ctx.ptr == nil {
+		return fmt.Errorf("SD context is not initialized")
+	}
+	if ctx.photoMakerPath == "" {
+		return fmt.Errorf("SD context was created without a PhotoMaker model")
+	}
+	trigger := pm.TriggerWord
+	if trigger == "" {
+		trigger = DefaultPhotoMakerTrigger
+	}
+	if pm.triggerRe == nil || pm.triggerFor != trigger {
+		pm.triggerRe = regexp.MustCompile(`(^|\W)` + regexp.QuoteMeta(trigger) + `($|\W)`)
+		pm.triggerFor = trigger
+	}
+	if !pm.triggerRe.MatchString(prompt) {
+		return fmt.Errorf("prompt does not contain PhotoMaker trigger word %q", trigger)
+	}
+	return nil
+}
+
+// Apply validates ctx and the prompt in params and sets params.PMParams.
+// params references Go memory owned by pm, so callers passing params to C
+// themselves must keep pm alive and pinned until generation returns;
+// GeneratePhotoMaker does both.
+func (pm *PhotoMaker) Apply(ctx *SDContext, params *SDImgGenParams) error {
+	if len(pm.images) == 0 {
+		return fmt.Errorf("no face images")
+	}
+	if err := pm.Validate(ctx, CGoString(params.Prompt)); err != nil {
+		return err
+	}
+
+	style := pm.StyleStrength
+	if style <= 0 {
+		style = defaultStyleStrength
+	}
+	params.PMParams = SDPMParams{
+		IDImages:      &pm.images[0],
+		IDImagesCount: int32(len(pm.images)),
+		IDEmbedPath:   pm.embedC,
+		StyleStrength: style,
+	}
+	return nil
+}
+
+// GeneratePhotoMaker generates an image conditioned on the faces in pm
+func (ctx *SDContext) GeneratePhotoMaker(params *SDImgGenParams, pm *PhotoMaker) (*image.RGBA, error) {
+	if ctx == nil || ctx.ptr == nil {
+		return nil, fmt.Errorf("SD context is not initialized")
+	}
+	if params == nil {
+		return nil, fmt.Errorf("image generation params are nil")
+	}
+	if pm == nil {
+		return nil, fmt.Errorf("PhotoMaker is nil")
+	}
+
+	p := *params
+	if err := pm.Apply(ctx, &p); err != nil {
+		return nil, err
+	}
+
+	// The C side reads the image array and the pixel buffers it points to,
+	// which are Go allocations nested behind params
+	var pinner runtime.Pinner
+	defer pinner.Unpin()
+	pm.pin(&pinner)
+	return ctx.Generate(&p)
+}
+
+// pin pins the face image array, every pixel buffer and the embedding path
+func (pm *PhotoMaker) pin(pinner *runtime.Pinner) {
+	pinner.Pin(&pm.images[0])
+	for i := range pm.images {
+		if pm.images[i].Data != nil {
+			pinner.Pin(pm.images[i].Data)
+		}
+	}
+	if pm.embedC != nil {
+		pinner.Pin(pm.embedC)
+	}
+}
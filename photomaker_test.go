@@ -0,0 +1,177 @@
+package stablediffusion
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestNewPhotoMakerCrops(t *testing.T) {
+	// A wide photo with a bright center column; the crop keeps only the center
+	face := image.NewRGBA(image.Rect(0, 0, 90, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 90; x++ {
+			v := uint8(0)
+			if x >= 30 && x < 60 {
+				v = 255
+			}
+			face.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+
+	pm, err := NewPhotoMaker([]image.Image{face, face}, 20)
+	if err != nil {
+		t.Fatalf("NewPhotoMaker failed: %v", err)
+	}
+	if pm.Len() != 2 {
+		t.Fatalf("expected 2 images, got %d", pm.Len())
+	}
+	img := pm.images[0]
+	if img.Width != 16 || img.Height != 16 || img.Channel != 3 {
+		t.Fatalf("unexpected shape %dx%dx%d", img.Width, img.Height, img.Channel)
+	}
+	data := unsafe.Slice(img.Data, 16*16*3)
+	for i, v := range data {
+		if v < 200 {
+			t.Fatalf("pixel %d outside the center crop: %d", i/3, v)
+		}
+	}
+
+	if _, err := NewPhotoMaker(nil, 0); err == nil {
+		t.Error("expected error without faces")
+	}
+	if _, err := NewPhotoMaker([]image.Image{nil}, 0); err == nil {
+		t.Error("expected error for nil face")
+	}
+}
+
+func TestLoadPhotoMakerDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.png", "a.PNG"} {
+		writeTestPNG(t, filepath.Join(dir, name), 40, 40)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, PhotoMakerEmbedFile), []byte("embed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pm, err := LoadPhotoMakerDir(dir, 0)
+	if err != nil {
+		t.Fatalf("LoadPhotoMakerDir failed: %v", err)
+	}
+	if pm.Len() != 2 || pm.images[0].Width != defaultPhotoMakerSize {
+		t.Errorf("expected 2 images of %d, got %d of %d", defaultPhotoMakerSize, pm.Len(), pm.images[0].Width)
+	}
+	if pm.IDEmbedding() != filepath.Join(dir, PhotoMakerEmbedFile) {
+		t.Errorf("unexpected ID embedding %q", pm.IDEmbedding())
+	}
+
+	if err := pm.SetIDEmbedding(filepath.Join(dir, "missing.bin")); err == nil {
+		t.Error("expected error for missing ID embedding")
+	}
+	if _, err := LoadPhotoMakerDir(t.TempDir(), 0); err == nil {
+		t.Error("expected error for empty directory")
+	}
+}
+
+func TestGeneratePhotoMaker(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx := newFakeContext(sd)
+
+	pm, err := NewPhotoMaker([]image.Image{image.NewRGBA(image.Rect(0, 0, 32, 32))}, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	embed := filepath.Join(dir, "id.bin")
+	if err := os.WriteFile(embed, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.SetIDEmbedding(embed); err != nil {
+		t.Fatal(err)
+	}
+
+	params := &SDImgGenParams{Prompt: CString("a man img, portrait"), Width: 64, Height: 64}
+	if _, err := ctx.GeneratePhotoMaker(params, pm); err == nil || !strings.Contains(err.Error(), "without a PhotoMaker") {
+		t.Errorf("expected missing PhotoMaker error, got %v", err)
+	}
+
+	ctx.photoMakerPath = "photomaker.safetensors"
+	if _, err := ctx.GeneratePhotoMaker(&SDImgGenParams{Prompt: CString("a man, imgur")}, pm); err == nil || !strings.Contains(err.Error(), "trigger word") {
+		t.Errorf("expected trigger word error, got %v", err)
+	}
+	if len(fake.imgCalls) != 0 {
+		t.Fatalf("expected no generations, got %d", len(fake.imgCalls))
+	}
+
+	for range 2 {
+		if _, err := ctx.GeneratePhotoMaker(params, pm); err != nil {
+			t.Fatalf("GeneratePhotoMaker failed: %v", err)
+		}
+	}
+	for _, call := range fake.imgCalls {
+		pmp := call.PMParams
+		if pmp.IDImagesCount != 1 || pmp.IDImages != &pm.images[0] || pmp.StyleStrength != defaultStyleStrength {
+			t.Errorf("unexpected PhotoMaker params %+v", pmp)
+		}
+		if CGoString(pmp.IDEmbedPath) != embed {
+			t.Errorf("unexpected ID embedding %q", CGoString(pmp.IDEmbedPath))
+		}
+	}
+	if fake.imgCalls[0].PMParams.IDEmbedPath != fake.imgCalls[1].PMParams.IDEmbedPath {
+		t.Error("ID embedding path should be reused across generations")
+	}
+	if params.PMParams.IDImages != nil {
+		t.Error("GeneratePhotoMaker should not modify params")
+	}
+
+	var nilCtx *SDContext
+	if _, err := nilCtx.GeneratePhotoMaker(params, pm); err == nil {
+		t.Error("expected error for nil context")
+	}
+	if err := pm.Validate(nil, "img"); err == nil {
+		t.Error("expected Validate error for nil context")
+	}
+}
+
+func TestPhotoMakerTriggerWord(t *testing.T) {
+	sd, _ := newFakeSD()
+	ctx := newFakeContext(sd)
+	ctx.photoMakerPath = "photomaker.safetensors"
+	pm := &PhotoMaker{}
+
+	if err := pm.Validate(ctx, "a man img"); err != nil {
+		t.Fatalf("default trigger rejected: %v", err)
+	}
+	re := pm.triggerRe
+	if err := pm.Validate(ctx, "img of a man"); err != nil || pm.triggerRe != re {
+		t.Errorf("trigger regexp should be reused, err %v", err)
+	}
+
+	pm.TriggerWord = "ohwx"
+	if err := pm.Validate(ctx, "a man img"); err == nil {
+		t.Error("expected error after changing the trigger word")
+	}
+	if err := pm.Validate(ctx, "ohwx, portrait"); err != nil {
+		t.Errorf("new trigger rejected: %v", err)
+	}
+}
+
+func writeTestPNG(t *testing.T, path string, width, height int) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+}
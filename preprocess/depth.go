@@ -0,0 +1,97 @@
+package preprocess
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// DepthOptions configures Depth
+type DepthOptions struct {
+	// Invert treats dark regions as near, e.g. for night scenes
+	Invert bool
+	// Vertical is how much the lower part of the frame is assumed nearer, 0..1 (default 0.5)
+	Vertical float64
+	// Blur smooths the map, as a fraction of the image's short side (default 0.02)
+	Blur float64
+}
+
+// Depth estimates a MiDaS-style depth map (white is near) from luminance and
+// the common ground-plane prior. It is a heuristic, not a learned estimator,
+// and works best on simple, evenly lit scenes.
+func Depth(img image.Image, opts DepthOptions) *image.Gray {
+	if opts.Vertical <= 0 {
+		opts.Vertical = 0.5
+	}
+	if opts.Blur <= 0 {
+		opts.Blur = 0.02
+	}
+
+	lum := luminance(img)
+	sigma := opts.Blur * float64(min(lum.w, lum.h))
+	smooth := lum.blur(sigma).normalize()
+
+	out := newPlane(lum.w, lum.h)
+	for y := 0; y < lum.h; y++ {
+		ground := 0.0
+		if lum.h > 1 {
+			ground = float64(y) / float64(lum.h-1)
+		}
+		for x := 0; x < lum.w; x++ {
+			v := smooth.pix[y*lum.w+x]
+			if opts.Invert {
+				v = 1 - v
+			}
+			out.pix[y*lum.w+x] = (1-opts.Vertical)*v + opts.Vertical*ground
+		}
+	}
+	return out.normalize().gray()
+}
+
+// TileOptions configures Tile
+type TileOptions struct {
+	// Factor is the downscale factor before scaling back up (default 8)
+	Factor int
+}
+
+// Tile produces the low-detail guide used by tile ControlNets by downscaling
+// img and scaling it back to its original size with Lanczos
+func Tile(img image.Image, opts TileOptions) *image.RGBA {
+	if opts.Factor <= 0 {
+		opts.Factor = 8
+	}
+	b := img.Bounds()
+	w, h := max(b.Dx()/opts.Factor, 1), max(b.Dy()/opts.Factor, 1)
+	small := stablediffusion.ResizeLanczos(img, w, h)
+	return stablediffusion.ResizeLanczos(small, b.Dx(), b.Dy())
+}
+
+// Blur applies a gaussian blur to each channel; sigma 0 uses 1% of the short side
+func Blur(img image.Image, sigma float64) *image.RGBA {
+	b := img.Bounds()
+	if sigma <= 0 {
+		sigma = max(float64(min(b.Dx(), b.Dy()))*0.01, 1)
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	channels := make([]*plane, 4)
+	for c := range channels {
+		p := newPlane(b.Dx(), b.Dy())
+		for i := range p.pix {
+			p.pix[i] = float64(src.Pix[i*4+c]) / 255
+		}
+		channels[c] = p.blur(sigma)
+	}
+
+	out := image.NewRGBA(src.Bounds())
+	for i := 0; i < b.Dx()*b.Dy(); i++ {
+		out.Pix[i*4+0] = unit8(channels[0].pix[i])
+		out.Pix[i*4+1] = unit8(channels[1].pix[i])
+		out.Pix[i*4+2] = unit8(channels[2].pix[i])
+		out.Pix[i*4+3] = unit8(channels[3].pix[i])
+	}
+	return out
+}
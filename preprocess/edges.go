@@ -0,0 +1,129 @@
+package preprocess
+
+import (
+	"image"
+	"math"
+)
+
+// Sobel returns the normalized Sobel gradient magnitude of img
+func Sobel(img image.Image) *image.Gray {
+	mag, _ := luminance(img).sobel()
+	return mag.normalize().gray()
+}
+
+// Laplacian returns the normalized absolute 3x3 Laplacian of img
+func Laplacian(img image.Image) *image.Gray {
+	p := luminance(img)
+	out := newPlane(p.w, p.h)
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			v := 4*p.at(x, y) - p.at(x-1, y) - p.at(x+1, y) - p.at(x, y-1) - p.at(x, y+1)
+			out.pix[y*p.w+x] = math.Abs(v)
+		}
+	}
+	return out.normalize().gray()
+}
+
+// SoftEdgeOptions configures SoftEdge
+type SoftEdgeOptions struct {
+	// Sigmas are the blur scales whose edges are combined (default 1, 2, 4)
+	Sigmas []float64
+	// Gamma shapes the response; below 1 brightens weak edges (default 0.6)
+	Gamma float64
+}
+
+// SoftEdge approximates HED soft edges by averaging Sobel responses over
+// several blur scales, so strong object boundaries dominate fine texture
+func SoftEdge(img image.Image, opts SoftEdgeOptions) *image.Gray {
+	if len(opts.Sigmas) == 0 {
+		opts.Sigmas = []float64{1, 2, 4}
+	}
+	if opts.Gamma <= 0 {
+		opts.Gamma = 0.6
+	}
+
+	lum := luminance(img)
+	acc := newPlane(lum.w, lum.h)
+	for _, sigma := range opts.Sigmas {
+		mag, _ := lum.blur(sigma).sobel()
+		// Coarser scales lose contrast, so weight them back up
+		w := math.Max(sigma, 1)
+		for i, v := range mag.pix {
+			acc.pix[i] += v * w
+		}
+	}
+	acc = acc.normalize()
+	for i, v := range acc.pix {
+		acc.pix[i] = math.Pow(v, opts.Gamma)
+	}
+	return acc.gray()
+}
+
+// LineArtOptions configures LineArt
+type LineArtOptions struct {
+	// Sigma is the blur used to find dark strokes (default 1.5)
+	Sigma float64
+	// Strength amplifies the line response (default 8)
+	Strength float64
+}
+
+// LineArt extracts dark strokes as white lines on black using a
+// difference-of-gaussians, similar to a pencil-sketch filter
+func LineArt(img image.Image, opts LineArtOptions) *image.Gray {
+	if opts.Sigma <= 0 {
+		opts.Sigma = 1.5
+	}
+	if opts.Strength <= 0 {
+		opts.Strength = 8
+	}
+
+	lum := luminance(img)
+	blurred := lum.blur(opts.Sigma)
+	out := newPlane(lum.w, lum.h)
+	for i := range out.pix {
+		// Pixels darker than their surroundings are strokes
+		out.pix[i] = math.Max(0, blurred.pix[i]-lum.pix[i]) * opts.Strength
+	}
+	return out.gray()
+}
+
+// ScribbleOptions configures Scribble
+type ScribbleOptions struct {
+	// Threshold on the soft edge response, 0..1 (default 0.35)
+	Threshold float64
+	// Thickness is the stroke radius in pixels (default 2)
+	Thickness int
+}
+
+// Scribble turns img into bold binary strokes like a hand-drawn scribble
+func Scribble(img image.Image, opts ScribbleOptions) *image.Gray {
+	if opts.Threshold <= 0 {
+		opts.Threshold = 0.35
+	}
+	if opts.Thickness <= 0 {
+		opts.Thickness = 2
+	}
+
+	edges := SoftEdge(img, SoftEdgeOptions{Sigmas: []float64{2, 4}})
+	w, h := edges.Bounds().Dx(), edges.Bounds().Dy()
+	out := image.NewGray(edges.Bounds())
+	limit := uint8(opts.Threshold * 255)
+	r := opts.Thickness
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if edges.Pix[y*edges.Stride+x] < limit {
+				continue
+			}
+			for dy := -r; dy <= r; dy++ {
+				for dx := -r; dx <= r; dx++ {
+					px, py := x+dx, y+dy
+					if dx*dx+dy*dy > r*r || px < 0 || py < 0 || px >= w || py >= h {
+						continue
+					}
+					out.Pix[py*out.Stride+px] = 255
+				}
+			}
+		}
+	}
+	return out
+}
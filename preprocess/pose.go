@@ -0,0 +1,169 @@
+package preprocess
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Keypoint is one OpenPose joint; C is the confidence and 0 marks a missing joint
+type Keypoint struct {
+	X, Y, C float64
+}
+
+// Pose is the COCO-18 body of one person
+type Pose [18]Keypoint
+
+// PoseData is a parsed OpenPose JSON document
+type PoseData struct {
+	CanvasWidth  int
+	CanvasHeight int
+	People       []Pose
+}
+
+// body25ToCOCO maps COCO-18 joint indices to BODY_25 indices
+var body25ToCOCO = [18]int{0, 1, 2, 3, 4, 5, 6, 7, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18}
+
+// poseLimbs are the COCO-18 joint pairs drawn by the ControlNet OpenPose annotator
+var poseLimbs = [17][2]int{
+	{1, 2}, {1, 5}, {2, 3}, {3, 4}, {5, 6}, {6, 7}, {1, 8}, {8, 9}, {9, 10},
+	{1, 11}, {11, 12}, {12, 13}, {1, 0}, {0, 14}, {14, 16}, {0, 15}, {15, 17},
+}
+
+// poseColors are the OpenPose joint and limb colours
+var poseColors = [18]color.RGBA{
+	{255, 0, 0, 255}, {255, 85, 0, 255}, {255, 170, 0, 255}, {255, 255, 0, 255},
+	{170, 255, 0, 255}, {85, 255, 0, 255}, {0, 255, 0, 255}, {0, 255, 85, 255},
+	{0, 255, 170, 255}, {0, 255, 255, 255}, {0, 170, 255, 255}, {0, 85, 255, 255},
+	{0, 0, 255, 255}, {85, 0, 255, 255}, {170, 0, 255, 255}, {255, 0, 255, 255},
+	{255, 0, 170, 255}, {255, 0, 85, 255},
+}
+
+// ParsePose reads OpenPose JSON with COCO-18 or BODY_25 keypoints. Coordinates
+// in [0, 1] are treated as normalized and scaled by the canvas size.
+func ParsePose(data []byte) (*PoseData, error) {
+	var doc struct {
+		CanvasWidth  int `json:"canvas_width"`
+		CanvasHeight int `json:"canvas_height"`
+		People       []struct {
+			Keypoints []float64 `json:"pose_keypoints_2d"`
+		} `json:"people"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid pose JSON: %w", err)
+	}
+
+	pd := &PoseData{CanvasWidth: doc.CanvasWidth, CanvasHeight: doc.CanvasHeight}
+	for i, person := range doc.People {
+		kp := person.Keypoints
+		var pose Pose
+		switch len(kp) {
+		case 18 * 3:
+			for j := range pose {
+				pose[j] = Keypoint{kp[j*3], kp[j*3+1], kp[j*3+2]}
+			}
+		case 25 * 3:
+			for j, src := range body25ToCOCO {
+				pose[j] = Keypoint{kp[src*3], kp[src*3+1], kp[src*3+2]}
+			}
+		default:
+			return nil, fmt.Errorf("person %d has %d keypoint values, want %d (COCO-18) or %d (BODY_25)", i, len(kp), 18*3, 25*3)
+		}
+		pd.People = append(pd.People, pose)
+	}
+
+	if pd.normalized() {
+		if pd.CanvasWidth <= 0 || pd.CanvasHeight <= 0 {
+			return nil, fmt.Errorf("normalized keypoints need canvas_width and canvas_height")
+		}
+		for i := range pd.People {
+			for j := range pd.People[i] {
+				pd.People[i][j].X *= float64(pd.CanvasWidth)
+				pd.People[i][j].Y *= float64(pd.CanvasHeight)
+			}
+		}
+	}
+	return pd, nil
+}
+
+func (pd *PoseData) normalized() bool {
+	seen := false
+	for _, pose := range pd.People {
+		for _, k := range pose {
+			if k.C <= 0 {
+				continue
+			}
+			if k.X > 1 || k.Y > 1 {
+				return false
+			}
+			seen = true
+		}
+	}
+	return seen
+}
+
+// RenderPose draws the skeletons on a black canvas of width x height, scaling
+// from the canvas size in the JSON. Zero width or height uses the JSON canvas.
+func RenderPose(pd *PoseData, width, height int) (*image.RGBA, error) {
+	if width <= 0 || height <= 0 {
+		width, height = pd.CanvasWidth, pd.CanvasHeight
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("pose canvas size is unknown")
+	}
+	sx, sy := 1.0, 1.0
+	if pd.CanvasWidth > 0 && pd.CanvasHeight > 0 {
+		sx, sy = float64(width)/float64(pd.CanvasWidth), float64(height)/float64(pd.CanvasHeight)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	// The annotator uses 4px limbs on 512px images
+	stick := 4 * math.Max(1, float64(min(width, height))/512)
+	for _, pose := range pd.People {
+		for i, limb := range poseLimbs {
+			a, b := pose[limb[0]], pose[limb[1]]
+			if a.C <= 0 || b.C <= 0 {
+				continue
+			}
+			c := poseColors[i]
+			// Limbs are blended at 60% over the black canvas
+			dim := color.RGBA{uint8(float64(c.R) * 0.6), uint8(float64(c.G) * 0.6), uint8(float64(c.B) * 0.6), 255}
+			drawCapsule(img, a.X*sx, a.Y*sy, b.X*sx, b.Y*sy, stick, dim)
+		}
+		for i, k := range pose {
+			if k.C <= 0 {
+				continue
+			}
+			drawCapsule(img, k.X*sx, k.Y*sy, k.X*sx, k.Y*sy, stick, poseColors[i])
+		}
+	}
+	return img, nil
+}
+
+// drawCapsule fills every pixel within radius of the segment a-b
+func drawCapsule(img *image.RGBA, ax, ay, bx, by, radius float64, c color.RGBA) {
+	r := image.Rect(
+		int(math.Floor(math.Min(ax, bx)-radius)), int(math.Floor(math.Min(ay, by)-radius)),
+		int(math.Ceil(math.Max(ax, bx)+radius))+1, int(math.Ceil(math.Max(ay, by)+radius))+1,
+	).Intersect(img.Bounds())
+
+	dx, dy := bx-ax, by-ay
+	lenSq := dx*dx + dy*dy
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			px, py := float64(x)+0.5, float64(y)+0.5
+			t := 0.0
+			if lenSq > 0 {
+				t = math.Max(0, math.Min(1, ((px-ax)*dx+(py-ay)*dy)/lenSq))
+			}
+			if math.Hypot(px-ax-t*dx, py-ay-t*dy) <= radius {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
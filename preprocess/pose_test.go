@@ -0,0 +1,67 @@
+package preprocess
+
+import (
+	"encoding/json"
+	"image/color"
+	"testing"
+)
+
+func TestRenderPose(t *testing.T) {
+	// Neck at (50,20) and right shoulder at (30,20); everything else missing
+	kp := make([]float64, 18*3)
+	kp[1*3], kp[1*3+1], kp[1*3+2] = 50, 20, 1
+	kp[2*3], kp[2*3+1], kp[2*3+2] = 30, 20, 1
+	data := []byte(`{"canvas_width":100,"canvas_height":100,"people":[{"pose_keypoints_2d":` + floats(kp) + `}]}`)
+
+	pd, err := ParsePose(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := RenderPose(pd, 200, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Joints are drawn in full colour at twice the JSON scale
+	if got := img.RGBAAt(100, 40); got != poseColors[1] {
+		t.Errorf("neck = %v, want %v", got, poseColors[1])
+	}
+	// The limb between them is the first limb colour at 60%
+	if got := img.RGBAAt(80, 40); got != (color.RGBA{153, 0, 0, 255}) {
+		t.Errorf("limb = %v", got)
+	}
+	if got := img.RGBAAt(100, 100); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("background = %v", got)
+	}
+}
+
+func TestParsePoseFormats(t *testing.T) {
+	body25 := make([]float64, 25*3)
+	// BODY_25 RHip (9) becomes COCO RHip (8); normalized coordinates scale by the canvas
+	body25[9*3], body25[9*3+1], body25[9*3+2] = 0.5, 0.25, 0.9
+	pd, err := ParsePose([]byte(`{"canvas_width":64,"canvas_height":32,"people":[{"pose_keypoints_2d":` + floats(body25) + `}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := pd.People[0][8]; k.X != 32 || k.Y != 8 || k.C != 0.9 {
+		t.Errorf("RHip = %+v", k)
+	}
+
+	for _, bad := range []string{
+		`{"people":[{"pose_keypoints_2d":[1,2,3]}]}`,
+		`{"people":[{"pose_keypoints_2d":` + floats(body25) + `}]}`,
+		`not json`,
+	} {
+		if _, err := ParsePose([]byte(bad)); err == nil {
+			t.Errorf("expected error for %.40s", bad)
+		}
+	}
+	if _, err := RenderPose(&PoseData{}, 0, 0); err == nil {
+		t.Error("expected error without a canvas size")
+	}
+}
+
+func floats(v []float64) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
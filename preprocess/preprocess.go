@@ -0,0 +1,179 @@
+// Package preprocess implements ControlNet preprocessors in pure Go.
+//
+// Each preprocessor takes an image.Image and returns a new image; the input
+// is never modified. Edge and line preprocessors follow the ControlNet
+// convention of white lines on a black background. Use ToSDImage to pass the
+// result as SDImgGenParams.ControlImage.
+package preprocess
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// Func is a preprocessor with its default settings
+type Func func(img image.Image) (image.Image, error)
+
+// registry maps preprocessor names to their default form
+var registry = map[string]Func{
+	"lineart":   func(img image.Image) (image.Image, error) { return LineArt(img, LineArtOptions{}), nil },
+	"scribble":  func(img image.Image) (image.Image, error) { return Scribble(img, ScribbleOptions{}), nil },
+	"softedge":  func(img image.Image) (image.Image, error) { return SoftEdge(img, SoftEdgeOptions{}), nil },
+	"sobel":     func(img image.Image) (image.Image, error) { return Sobel(img), nil },
+	"laplacian": func(img image.Image) (image.Image, error) { return Laplacian(img), nil },
+	"depth":     func(img image.Image) (image.Image, error) { return Depth(img, DepthOptions{}), nil },
+	"tile":      func(img image.Image) (image.Image, error) { return Tile(img, TileOptions{}), nil },
+	"blur":      func(img image.Image) (image.Image, error) { return Blur(img, 0), nil },
+	"canny": func(img image.Image) (image.Image, error) {
+		return stablediffusion.Canny(img, stablediffusion.CannyOptions{})
+	},
+}
+
+// Register adds or replaces a named preprocessor
+func Register(name string, fn Func) {
+	registry[name] = fn
+}
+
+// Lookup returns the preprocessor registered under name
+func Lookup(name string) (Func, error) {
+	fn, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preprocessor %q (available: %v)", name, Names())
+	}
+	return fn, nil
+}
+
+// Names returns the registered preprocessor names in sorted order
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ToSDImage converts a preprocessor result to the 3-channel SDImage ControlNet expects
+func ToSDImage(img image.Image) stablediffusion.SDImage {
+	return stablediffusion.ImageToSDImage(img)
+}
+
+// plane is a single-channel float image with values nominally in [0, 1]
+type plane struct {
+	w, h int
+	pix  []float64
+}
+
+func newPlane(w, h int) *plane {
+	return &plane{w: w, h: h, pix: make([]float64, w*h)}
+}
+
+func (p *plane) at(x, y int) float64 {
+	x = min(max(x, 0), p.w-1)
+	y = min(max(y, 0), p.h-1)
+	return p.pix[y*p.w+x]
+}
+
+// luminance returns the Rec. 601 luma of img
+func luminance(img image.Image) *plane {
+	b := img.Bounds()
+	p := newPlane(b.Dx(), b.Dy())
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			p.pix[y*p.w+x] = (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)) / 0xffff
+		}
+	}
+	return p
+}
+
+// blur applies a separable gaussian with clamped edges
+func (p *plane) blur(sigma float64) *plane {
+	out := newPlane(p.w, p.h)
+	if sigma <= 0 {
+		copy(out.pix, p.pix)
+		return out
+	}
+	radius := int(math.Ceil(sigma * 3))
+	kernel := make([]float64, 2*radius+1)
+	sum := 0.0
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	tmp := newPlane(p.w, p.h)
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			acc := 0.0
+			for i, k := range kernel {
+				acc += k * p.at(x+i-radius, y)
+			}
+			tmp.pix[y*p.w+x] = acc
+		}
+	}
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			acc := 0.0
+			for i, k := range kernel {
+				acc += k * tmp.at(x, y+i-radius)
+			}
+			out.pix[y*p.w+x] = acc
+		}
+	}
+	return out
+}
+
+// sobel returns the gradient magnitude and direction (radians)
+func (p *plane) sobel() (mag, dir *plane) {
+	mag, dir = newPlane(p.w, p.h), newPlane(p.w, p.h)
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			gx := p.at(x+1, y-1) + 2*p.at(x+1, y) + p.at(x+1, y+1) -
+				p.at(x-1, y-1) - 2*p.at(x-1, y) - p.at(x-1, y+1)
+			gy := p.at(x-1, y+1) + 2*p.at(x, y+1) + p.at(x+1, y+1) -
+				p.at(x-1, y-1) - 2*p.at(x, y-1) - p.at(x+1, y-1)
+			mag.pix[y*p.w+x] = math.Hypot(gx, gy)
+			dir.pix[y*p.w+x] = math.Atan2(gy, gx)
+		}
+	}
+	return mag, dir
+}
+
+// normalize stretches the values to [0, 1]; a flat plane becomes all zero
+func (p *plane) normalize() *plane {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range p.pix {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	out := newPlane(p.w, p.h)
+	if hi-lo < 1e-12 {
+		return out
+	}
+	for i, v := range p.pix {
+		out.pix[i] = (v - lo) / (hi - lo)
+	}
+	return out
+}
+
+// gray converts the plane to an 8-bit image, clamping to [0, 1]
+func (p *plane) gray() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, p.w, p.h))
+	for i, v := range p.pix {
+		img.Pix[i] = unit8(v)
+	}
+	return img
+}
+
+// unit8 converts a [0, 1] value to a byte
+func unit8(v float64) uint8 {
+	return uint8(math.Round(min(max(v, 0), 1) * 255))
+}
@@ -0,0 +1,140 @@
+package preprocess
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// square returns a w x h black image with a centred white square of side s
+func square(w, h, s int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	r := image.Rect((w-s)/2, (h-s)/2, (w+s)/2, (h+s)/2)
+	draw.Draw(img, r, image.NewUniform(color.White), image.Point{}, draw.Src)
+	return img
+}
+
+func grayAt(img image.Image, x, y int) uint8 {
+	return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+}
+
+func TestEdgeDetectors(t *testing.T) {
+	img := square(64, 64, 32)
+	before := append([]uint8(nil), img.Pix...)
+
+	for name, out := range map[string]image.Image{
+		"sobel":     Sobel(img),
+		"laplacian": Laplacian(img),
+		"softedge":  SoftEdge(img, SoftEdgeOptions{}),
+		"scribble":  Scribble(img, ScribbleOptions{}),
+	} {
+		if out.Bounds() != img.Bounds() {
+			t.Errorf("%s: bounds %v", name, out.Bounds())
+		}
+		// The square's edge at x=16 responds, flat regions do not
+		if grayAt(out, 16, 32) < 100 {
+			t.Errorf("%s: edge response %d too weak", name, grayAt(out, 16, 32))
+		}
+		if grayAt(out, 32, 32) > 30 || grayAt(out, 1, 1) > 30 {
+			t.Errorf("%s: flat regions should be dark, got %d and %d", name, grayAt(out, 32, 32), grayAt(out, 1, 1))
+		}
+	}
+
+	for i := range before {
+		if img.Pix[i] != before[i] {
+			t.Fatal("input image was modified")
+		}
+	}
+}
+
+func TestLineArt(t *testing.T) {
+	// A thin dark line on white becomes a white line on black
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(15, 0, 17, 32), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	out := LineArt(img, LineArtOptions{})
+	if grayAt(out, 15, 16) < 200 {
+		t.Errorf("line response = %d, want bright", grayAt(out, 15, 16))
+	}
+	if grayAt(out, 4, 16) != 0 {
+		t.Errorf("background = %d, want black", grayAt(out, 4, 16))
+	}
+}
+
+func TestDepth(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{128}), image.Point{}, draw.Src)
+
+	out := Depth(img, DepthOptions{})
+	// With uniform luminance only the ground-plane prior remains: bottom is near
+	if grayAt(out, 8, 15) <= grayAt(out, 8, 0) {
+		t.Errorf("bottom %d should be nearer than top %d", grayAt(out, 8, 15), grayAt(out, 8, 0))
+	}
+
+	bright := square(16, 16, 8)
+	a := Depth(bright, DepthOptions{Vertical: 0.01})
+	b := Depth(bright, DepthOptions{Vertical: 0.01, Invert: true})
+	if grayAt(a, 8, 8) <= grayAt(a, 0, 8) || grayAt(b, 8, 8) >= grayAt(b, 0, 8) {
+		t.Error("luminance and Invert should decide which region is near")
+	}
+}
+
+func TestTileAndBlur(t *testing.T) {
+	img := square(64, 64, 32)
+	tile := Tile(img, TileOptions{Factor: 8})
+	if tile.Bounds() != img.Bounds() {
+		t.Fatalf("tile bounds %v", tile.Bounds())
+	}
+	if v := grayAt(tile, 16, 32); v == 0 || v == 255 {
+		t.Errorf("tile should soften the edge, got %d", v)
+	}
+
+	blurred := Blur(img, 2)
+	if v := grayAt(blurred, 16, 32); v == 0 || v == 255 {
+		t.Errorf("blur should soften the edge, got %d", v)
+	}
+	if grayAt(blurred, 32, 32) != 255 || grayAt(blurred, 2, 2) != 0 {
+		t.Error("blur should keep flat regions")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	for _, name := range Names() {
+		fn, err := Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := fn(square(16, 16, 8))
+		if err != nil || out.Bounds().Dx() != 16 {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := Lookup("missing"); err == nil {
+		t.Error("expected error for unknown preprocessor")
+	}
+
+	sd := ToSDImage(Sobel(square(8, 8, 4)))
+	if sd.Channel != 3 || sd.Width != 8 {
+		t.Errorf("ToSDImage = %dx%d/%d", sd.Width, sd.Height, sd.Channel)
+	}
+}
+
+func TestLookupForControlNet(t *testing.T) {
+	var cn stablediffusion.ControlNet
+	var err error
+	if cn.Preprocess, err = Lookup("sobel"); err != nil {
+		t.Fatal(err)
+	}
+	control, err := cn.ControlImage(image.NewRGBA(image.Rect(0, 0, 16, 16)), 32, 32)
+	if err != nil {
+		t.Fatalf("ControlImage failed: %v", err)
+	}
+	if control.Bounds().Dx() != 32 || control.Bounds().Dy() != 32 {
+		t.Errorf("unexpected control size %v", control.Bounds())
+	}
+}
@@ -0,0 +1,106 @@
+package preprocess
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// ADE20K is the 150-class ADE20K palette used by segmentation ControlNets
+var ADE20K = []color.RGBA{
+	{120, 120, 120, 255}, {180, 120, 120, 255}, {6, 230, 230, 255}, {80, 50, 50, 255}, {4, 200, 3, 255}, {120, 120, 80, 255},
+	{140, 140, 140, 255}, {204, 5, 255, 255}, {230, 230, 230, 255}, {4, 250, 7, 255}, {224, 5, 255, 255}, {235, 255, 7, 255},
+	{150, 5, 61, 255}, {120, 120, 70, 255}, {8, 255, 51, 255}, {255, 6, 82, 255}, {143, 255, 140, 255}, {204, 255, 4, 255},
+	{255, 51, 7, 255}, {204, 70, 3, 255}, {0, 102, 200, 255}, {61, 230, 250, 255}, {255, 6, 51, 255}, {11, 102, 255, 255},
+	{255, 7, 71, 255}, {255, 9, 224, 255}, {9, 7, 230, 255}, {220, 220, 220, 255}, {255, 9, 92, 255}, {112, 9, 255, 255},
+	{8, 255, 214, 255}, {7, 255, 224, 255}, {255, 184, 6, 255}, {10, 255, 71, 255}, {255, 41, 10, 255}, {7, 255, 255, 255},
+	{224, 255, 8, 255}, {102, 8, 255, 255}, {255, 61, 6, 255}, {255, 194, 7, 255}, {255, 122, 8, 255}, {0, 255, 20, 255},
+	{255, 8, 41, 255}, {255, 5, 153, 255}, {6, 51, 255, 255}, {235, 12, 255, 255}, {160, 150, 20, 255}, {0, 163, 255, 255},
+	{140, 140, 140, 255}, {250, 10, 15, 255}, {20, 255, 0, 255}, {31, 255, 0, 255}, {255, 31, 0, 255}, {255, 224, 0, 255},
+	{153, 255, 0, 255}, {0, 0, 255, 255}, {255, 71, 0, 255}, {0, 235, 255, 255}, {0, 173, 255, 255}, {31, 0, 255, 255},
+	{11, 200, 200, 255}, {255, 82, 0, 255}, {0, 255, 245, 255}, {0, 61, 255, 255}, {0, 255, 112, 255}, {0, 255, 133, 255},
+	{255, 0, 0, 255}, {255, 163, 0, 255}, {255, 102, 0, 255}, {194, 255, 0, 255}, {0, 143, 255, 255}, {51, 255, 0, 255},
+	{0, 82, 255, 255}, {0, 255, 41, 255}, {0, 255, 173, 255}, {10, 0, 255, 255}, {173, 255, 0, 255}, {0, 255, 153, 255},
+	{255, 92, 0, 255}, {255, 0, 255, 255}, {255, 0, 245, 255}, {255, 0, 102, 255}, {255, 173, 0, 255}, {255, 0, 20, 255},
+	{255, 184, 184, 255}, {0, 31, 255, 255}, {0, 255, 61, 255}, {0, 71, 255, 255}, {255, 0, 204, 255}, {0, 255, 194, 255},
+	{0, 255, 82, 255}, {0, 10, 255, 255}, {0, 112, 255, 255}, {51, 0, 255, 255}, {0, 194, 255, 255}, {0, 122, 255, 255},
+	{0, 255, 163, 255}, {255, 153, 0, 255}, {0, 255, 10, 255}, {255, 112, 0, 255}, {143, 255, 0, 255}, {82, 0, 255, 255},
+	{163, 255, 0, 255}, {255, 235, 0, 255}, {8, 184, 170, 255}, {133, 0, 255, 255}, {0, 255, 92, 255}, {184, 0, 255, 255},
+	{255, 0, 31, 255}, {0, 184, 255, 255}, {0, 214, 255, 255}, {255, 0, 112, 255}, {92, 255, 0, 255}, {0, 224, 255, 255},
+	{112, 224, 255, 255}, {70, 184, 160, 255}, {163, 0, 255, 255}, {153, 0, 255, 255}, {71, 255, 0, 255}, {255, 0, 163, 255},
+	{255, 204, 0, 255}, {255, 0, 143, 255}, {0, 255, 235, 255}, {133, 255, 0, 255}, {255, 0, 235, 255}, {245, 0, 255, 255},
+	{255, 0, 122, 255}, {255, 245, 0, 255}, {10, 190, 212, 255}, {214, 255, 0, 255}, {0, 204, 255, 255}, {20, 0, 255, 255},
+	{255, 255, 0, 255}, {0, 153, 255, 255}, {0, 41, 255, 255}, {0, 255, 204, 255}, {41, 0, 255, 255}, {41, 255, 0, 255},
+	{173, 0, 255, 255}, {0, 245, 255, 255}, {71, 0, 255, 255}, {122, 0, 255, 255}, {0, 255, 184, 255}, {0, 92, 255, 255},
+	{184, 255, 0, 255}, {0, 133, 255, 255}, {255, 214, 0, 255}, {25, 194, 194, 255}, {102, 255, 0, 255}, {92, 0, 255, 255},
+}
+
+// RecolorLabels paints a class-index map with palette colours. Each gray
+// value of labels is a class index; indices beyond the palette are an error.
+func RecolorLabels(labels *image.Gray, palette []color.RGBA) (*image.RGBA, error) {
+	b := labels.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			idx := int(labels.GrayAt(b.Min.X+x, b.Min.Y+y).Y)
+			if idx >= len(palette) {
+				return nil, fmt.Errorf("class %d at (%d,%d) is outside the %d-colour palette", idx, x, y, len(palette))
+			}
+			out.SetRGBA(x, y, palette[idx])
+		}
+	}
+	return out, nil
+}
+
+// SnapToPalette replaces every pixel of img with the nearest palette colour,
+// cleaning up hand-painted or resampled segmentation maps
+func SnapToPalette(img image.Image, palette []color.RGBA) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	cache := make(map[color.RGBA]color.RGBA)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			c := color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8), 255}
+			snapped, ok := cache[c]
+			if !ok {
+				snapped = nearest(c, palette)
+				cache[c] = snapped
+			}
+			out.SetRGBA(x, y, snapped)
+		}
+	}
+	return out
+}
+
+// RemapColors recolors a segmentation map from one labelling scheme to another,
+// e.g. from an editor's class colours to ADE20K. Colours without a mapping are kept.
+func RemapColors(img image.Image, mapping map[color.RGBA]color.RGBA) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			c := color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8), 255}
+			if m, ok := mapping[c]; ok {
+				c = m
+			}
+			out.SetRGBA(x, y, c)
+		}
+	}
+	return out
+}
+
+func nearest(c color.RGBA, palette []color.RGBA) color.RGBA {
+	if len(palette) == 0 {
+		return c
+	}
+	best, bestDist := palette[0], -1
+	for _, p := range palette {
+		dr, dg, db := int(c.R)-int(p.R), int(c.G)-int(p.G), int(c.B)-int(p.B)
+		if d := dr*dr + dg*dg + db*db; bestDist < 0 || d < bestDist {
+			best, bestDist = p, d
+		}
+	}
+	return best
+}
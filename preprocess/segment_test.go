@@ -0,0 +1,45 @@
+package preprocess
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRecolorLabels(t *testing.T) {
+	if len(ADE20K) != 150 {
+		t.Fatalf("ADE20K has %d colours, want 150", len(ADE20K))
+	}
+	labels := image.NewGray(image.Rect(0, 0, 2, 1))
+	labels.Pix[0], labels.Pix[1] = 0, 2
+
+	out, err := RecolorLabels(labels, ADE20K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.RGBAAt(0, 0) != ADE20K[0] || out.RGBAAt(1, 0) != ADE20K[2] {
+		t.Errorf("colours = %v %v", out.RGBAAt(0, 0), out.RGBAAt(1, 0))
+	}
+
+	labels.Pix[1] = 200
+	if _, err := RecolorLabels(labels, ADE20K); err == nil {
+		t.Error("expected error for a class beyond the palette")
+	}
+}
+
+func TestSnapAndRemap(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.SetRGBA(0, 0, color.RGBA{250, 5, 5, 255})
+	img.SetRGBA(1, 0, color.RGBA{10, 10, 240, 255})
+	palette := []color.RGBA{{255, 0, 0, 255}, {0, 0, 255, 255}}
+
+	snapped := SnapToPalette(img, palette)
+	if snapped.RGBAAt(0, 0) != palette[0] || snapped.RGBAAt(1, 0) != palette[1] {
+		t.Errorf("snapped = %v %v", snapped.RGBAAt(0, 0), snapped.RGBAAt(1, 0))
+	}
+
+	remapped := RemapColors(snapped, map[color.RGBA]color.RGBA{palette[0]: ADE20K[4]})
+	if remapped.RGBAAt(0, 0) != ADE20K[4] || remapped.RGBAAt(1, 0) != palette[1] {
+		t.Errorf("remapped = %v %v", remapped.RGBAAt(0, 0), remapped.RGBAAt(1, 0))
+	}
+}
@@ -0,0 +1,136 @@
+package presets
+
+import "github.com/kawai-network/stablediffusion"
+
+// builtin are the presets every Set starts with
+var builtin = map[string]Preset{
+	"sd15-fast": {
+		Arch:         "sd1",
+		Description:  "SD 1.x drafts",
+		SampleMethod: method(stablediffusion.EulerASampleMethod),
+		Scheduler:    scheduler(stablediffusion.DiscreteScheduler),
+		Steps:        20,
+		CFG:          value(7),
+	},
+	"sd15-quality": {
+		Arch:         "sd1",
+		Description:  "SD 1.x final renders",
+		SampleMethod: method(stablediffusion.DPMPP2MSampleMethod),
+		Scheduler:    scheduler(stablediffusion.KarrasScheduler),
+		Steps:        30,
+		CFG:          value(7),
+	},
+	"sd2-v": {
+		Arch:         "sd2",
+		Description:  "SD 2.x 768-v models",
+		SampleMethod: method(stablediffusion.DPMPP2MSampleMethod),
+		Scheduler:    scheduler(stablediffusion.KarrasScheduler),
+		Steps:        30,
+		CFG:          value(7),
+		Prediction:   prediction(stablediffusion.VPred),
+	},
+	"sdxl-fast": {
+		Arch:         "sdxl",
+		Description:  "SDXL drafts",
+		SampleMethod: method(stablediffusion.EulerASampleMethod),
+		Steps:        20,
+		CFG:          value(6),
+	},
+	"sdxl-quality": {
+		Arch:         "sdxl",
+		Description:  "SDXL final renders",
+		SampleMethod: method(stablediffusion.DPMPP2MSampleMethod),
+		Scheduler:    scheduler(stablediffusion.KarrasScheduler),
+		Steps:        30,
+		CFG:          value(7),
+	},
+	"sdxl-lightning": {
+		Arch:         "sdxl",
+		Description:  "SDXL Lightning 4-step checkpoints and LoRAs",
+		SampleMethod: method(stablediffusion.EulerSampleMethod),
+		Scheduler:    scheduler(stablediffusion.SGMUniformScheduler),
+		Steps:        4,
+		CFG:          value(1),
+	},
+	"sdxl-turbo": {
+		Arch:         "sdxl",
+		Description:  "SDXL Turbo",
+		SampleMethod: method(stablediffusion.EulerASampleMethod),
+		Scheduler:    scheduler(stablediffusion.SGMUniformScheduler),
+		Steps:        4,
+		CFG:          value(1),
+	},
+	"lcm": {
+		Arch:         "sdxl",
+		Description:  "LCM checkpoints and LCM LoRAs",
+		SampleMethod: method(stablediffusion.LCMSampleMethod),
+		Scheduler:    scheduler(stablediffusion.LCMScheduler),
+		Steps:        6,
+		CFG:          value(1),
+	},
+	"sd3-quality": {
+		Arch:         "sd3",
+		Description:  "SD 3.5 Large and Medium",
+		SampleMethod: method(stablediffusion.EulerSampleMethod),
+		Steps:        28,
+		CFG:          value(4.5),
+	},
+	"sd35-medium-slg": {
+		Arch:         "sd3",
+		Description:  "SD 3.5 Medium with skip layer guidance",
+		SampleMethod: method(stablediffusion.EulerSampleMethod),
+		Steps:        40,
+		CFG:          value(4.5),
+		SLGLayers:    []int32{7, 8, 9},
+		SLGStart:     0.01,
+		SLGEnd:       0.2,
+		SLGScale:     2.5,
+	},
+	"flux-dev": {
+		Arch:              "flux",
+		Description:       "FLUX.1 dev",
+		SampleMethod:      method(stablediffusion.EulerSampleMethod),
+		Steps:             28,
+		CFG:               value(1),
+		DistilledGuidance: value(3.5),
+	},
+	"flux-schnell": {
+		Arch:         "flux",
+		Description:  "FLUX.1 schnell",
+		SampleMethod: method(stablediffusion.EulerSampleMethod),
+		Steps:        4,
+		CFG:          value(1),
+	},
+	"qwen-image": {
+		Arch:         "qwen-image",
+		Description:  "Qwen-Image and Qwen-Image-Edit",
+		SampleMethod: method(stablediffusion.EulerSampleMethod),
+		Steps:        20,
+		CFG:          value(2.5),
+		FlowShift:    value(3),
+	},
+	"wan": {
+		Arch:         "wan",
+		Description:  "Wan2.1/2.2 video at 480p",
+		SampleMethod: method(stablediffusion.EulerSampleMethod),
+		Steps:        30,
+		CFG:          value(6),
+		FlowShift:    value(3),
+	},
+}
+
+func method(m stablediffusion.SampleMethod) *stablediffusion.SampleMethod {
+	return &m
+}
+
+func scheduler(s stablediffusion.Scheduler) *stablediffusion.Scheduler {
+	return &s
+}
+
+func prediction(p stablediffusion.Prediction) *stablediffusion.Prediction {
+	return &p
+}
+
+func value(v float32) *float32 {
+	return &v
+}
@@ -0,0 +1,280 @@
+// Package presets provides named sampling presets per model family.
+//
+// A preset bundles sample method, scheduler, steps and guidance for
+// SDSampleParams together with the FlowShift and Prediction context settings.
+// Built-in presets can be overridden and extended from YAML:
+//
+//	sdxl-quality:
+//	  steps: 40
+//	my-flux:
+//	  arch: flux
+//	  sample_method: euler
+//	  steps: 12
+//	  distilled_guidance: 3.0
+//
+// Fields left out of a preset keep their current value. A preset without a
+// sample method or scheduler uses the defaults of the loaded model.
+package presets
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/kawai-network/stablediffusion"
+	"gopkg.in/yaml.v3"
+)
+
+// Preset is a named set of sampling settings for one architecture
+type Preset struct {
+	// Arch is the model family, e.g. "sdxl" or "flux"
+	Arch        string `yaml:"arch"`
+	Description string `yaml:"description,omitempty"`
+	// SampleMethod and Scheduler fall back to the model defaults when nil
+	SampleMethod *stablediffusion.SampleMethod `yaml:"sample_method,omitempty"`
+	Scheduler    *stablediffusion.Scheduler    `yaml:"scheduler,omitempty"`
+	Steps        int32                         `yaml:"steps,omitempty"`
+	// CFG is the text classifier-free guidance scale. The guidance, Eta,
+	// ShiftedTimestep and FlowShift fields are pointers so that 0 can be set.
+	CFG               *float32 `yaml:"cfg,omitempty"`
+	ImgCFG            *float32 `yaml:"img_cfg,omitempty"`
+	DistilledGuidance *float32 `yaml:"distilled_guidance,omitempty"`
+	Eta               *float32 `yaml:"eta,omitempty"`
+	ShiftedTimestep   *int32   `yaml:"shifted_timestep,omitempty"`
+	// SLGLayers enables skip layer guidance on the listed layers
+	SLGLayers []int32 `yaml:"slg_layers,omitempty"`
+	SLGStart  float32 `yaml:"slg_start,omitempty"`
+	SLGEnd    float32 `yaml:"slg_end,omitempty"`
+	SLGScale  float32 `yaml:"slg_scale,omitempty"`
+	// FlowShift and Prediction are context settings, see ApplyContext
+	FlowShift  *float32                    `yaml:"flow_shift,omitempty"`
+	Prediction *stablediffusion.Prediction `yaml:"prediction,omitempty"`
+}
+
+// Defaults reports the sampler defaults of a loaded model.
+// *stablediffusion.SDContext implements it.
+type Defaults interface {
+	DefaultSampleMethod() stablediffusion.SampleMethod
+	DefaultScheduler(sampleMethod stablediffusion.SampleMethod) stablediffusion.Scheduler
+}
+
+var _ Defaults = (*stablediffusion.SDContext)(nil)
+
+// Apply writes the preset into params. Without a sample method or scheduler
+// in the preset, the defaults of the loaded model are used when defaults is
+// not nil and reports them, and params is left unchanged otherwise.
+func (p *Preset) Apply(params *stablediffusion.SDSampleParams, defaults Defaults) {
+	if p.SampleMethod != nil {
+		params.SampleMethod = *p.SampleMethod
+	} else if defaults != nil {
+		// A nil or freed context reports SampleMethodCount
+		if m := defaults.DefaultSampleMethod(); m < stablediffusion.SampleMethodCount {
+			params.SampleMethod = m
+		}
+	}
+	if p.Scheduler != nil {
+		params.Scheduler = *p.Scheduler
+	} else if defaults != nil {
+		if s := defaults.DefaultScheduler(params.SampleMethod); s < stablediffusion.SchedulerCount {
+			params.Scheduler = s
+		}
+	}
+
+	if p.Steps > 0 {
+		params.SampleSteps = p.Steps
+	}
+	if p.CFG != nil {
+		params.Guidance.TxtCfg = *p.CFG
+	}
+	if p.ImgCFG != nil {
+		params.Guidance.ImgCfg = *p.ImgCFG
+	}
+	if p.DistilledGuidance != nil {
+		params.Guidance.DistilledGuidance = *p.DistilledGuidance
+	}
+	if p.Eta != nil {
+		params.Eta = *p.Eta
+	}
+	if p.ShiftedTimestep != nil {
+		params.ShiftedTimestep = *p.ShiftedTimestep
+	}
+	if len(p.SLGLayers) > 0 {
+		// params keeps a pointer into the copy, so later edits to the preset do not leak in
+		layers := slices.Clone(p.SLGLayers)
+		params.Guidance.SLG = stablediffusion.SDSLGParams{
+			Layers:     &layers[0],
+			LayerCount: uintptr(len(layers)),
+			LayerStart: p.SLGStart,
+			LayerEnd:   p.SLGEnd,
+			Scale:      p.SLGScale,
+		}
+	}
+}
+
+// clone returns a copy that shares no pointers or slices with p
+func (p Preset) clone() Preset {
+	p.SampleMethod = clonePtr(p.SampleMethod)
+	p.Scheduler = clonePtr(p.Scheduler)
+	p.CFG = clonePtr(p.CFG)
+	p.ImgCFG = clonePtr(p.ImgCFG)
+	p.DistilledGuidance = clonePtr(p.DistilledGuidance)
+	p.Eta = clonePtr(p.Eta)
+	p.ShiftedTimestep = clonePtr(p.ShiftedTimestep)
+	p.FlowShift = clonePtr(p.FlowShift)
+	p.Prediction = clonePtr(p.Prediction)
+	p.SLGLayers = slices.Clone(p.SLGLayers)
+	return p
+}
+
+func clonePtr[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+// ApplyContext writes FlowShift and Prediction into context params before NewContext
+func (p *Preset) ApplyContext(params *stablediffusion.SDContextParams) {
+	if p.FlowShift != nil {
+		params.FlowShift = *p.FlowShift
+	}
+	if p.Prediction != nil {
+		params.Prediction = *p.Prediction
+	}
+}
+
+// Set is a collection of named presets. It is safe for concurrent use.
+type Set struct {
+	mu      sync.RWMutex
+	presets map[string]Preset
+}
+
+// Default holds the built-in presets and is used by the package-level functions.
+// LoadFile updates it in place, so readers see either the old or the new presets.
+var Default = Builtin()
+
+// Builtin returns a new set with the built-in presets
+func Builtin() *Set {
+	s := &Set{presets: make(map[string]Preset, len(builtin))}
+	for name, p := range builtin {
+		s.presets[name] = p.clone()
+	}
+	return s
+}
+
+// Get returns the preset called name
+func (s *Set) Get(name string) (Preset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.presets[name]
+	if !ok {
+		return Preset{}, fmt.Errorf("unknown preset %q", name)
+	}
+	return p.clone(), nil
+}
+
+// Set adds or replaces the preset called name
+func (s *Set) Set(name string, p Preset) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.presets[name] = p.clone()
+}
+
+// Names returns the preset names in sorted order
+func (s *Set) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.presets))
+	for name := range s.presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ForArch returns the names of the presets for arch in sorted order
+func (s *Set) ForArch(arch string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var names []string
+	for name, p := range s.presets {
+		if p.Arch == arch {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Parse reads presets from YAML. Fields of an existing preset that are
+// present in the YAML replace the current values; new names are added.
+func (s *Set) Parse(data []byte) error {
+	var nodes map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &nodes); err != nil {
+		return fmt.Errorf("failed to parse presets: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updated := make(map[string]Preset, len(nodes))
+	for name, node := range nodes {
+		// yaml decodes through existing pointers, so work on a deep copy
+		p := s.presets[name].clone()
+		if err := node.Decode(&p); err != nil {
+			return fmt.Errorf("failed to parse preset %q: %w", name, err)
+		}
+		updated[name] = p
+	}
+	for name, p := range updated {
+		s.presets[name] = p
+	}
+	return nil
+}
+
+// LoadFile reads presets from a YAML file, see Parse
+func (s *Set) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read presets: %w", err)
+	}
+	return s.Parse(data)
+}
+
+// Apply applies the preset called name to params, see Preset.Apply
+func (s *Set) Apply(params *stablediffusion.SDSampleParams, name string, defaults Defaults) error {
+	p, err := s.Get(name)
+	if err != nil {
+		return err
+	}
+	p.Apply(params, defaults)
+	return nil
+}
+
+// ApplyPreset applies the preset called name from Default to params.
+// Pass the loaded *stablediffusion.SDContext as defaults so presets without
+// a sampler or scheduler use the model defaults.
+func ApplyPreset(params *stablediffusion.SDSampleParams, name string, defaults Defaults) error {
+	return Default.Apply(params, name, defaults)
+}
+
+// ApplyContextPreset applies FlowShift and Prediction of the preset called name from Default
+func ApplyContextPreset(params *stablediffusion.SDContextParams, name string) error {
+	p, err := Default.Get(name)
+	if err != nil {
+		return err
+	}
+	p.ApplyContext(params)
+	return nil
+}
+
+// LoadFile reads user presets into Default. A missing file is not an error.
+func LoadFile(path string) error {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return Default.LoadFile(path)
+}
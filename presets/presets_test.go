@@ -0,0 +1,231 @@
+package presets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// fakeDefaults mimics a loaded model whose default scheduler depends on the sampler
+type fakeDefaults struct{}
+
+func (fakeDefaults) DefaultSampleMethod() stablediffusion.SampleMethod {
+	return stablediffusion.EulerASampleMethod
+}
+
+func (fakeDefaults) DefaultScheduler(m stablediffusion.SampleMethod) stablediffusion.Scheduler {
+	if m == stablediffusion.LCMSampleMethod {
+		return stablediffusion.LCMScheduler
+	}
+	return stablediffusion.DiscreteScheduler
+}
+
+func TestApplyPreset(t *testing.T) {
+	params := stablediffusion.SDSampleParams{Eta: 0.5, Scheduler: stablediffusion.AYSScheduler}
+	if err := ApplyPreset(&params, "sdxl-quality", nil); err != nil {
+		t.Fatalf("ApplyPreset failed: %v", err)
+	}
+	if params.SampleMethod != stablediffusion.DPMPP2MSampleMethod || params.Scheduler != stablediffusion.KarrasScheduler {
+		t.Errorf("unexpected sampler %v/%v", params.SampleMethod, params.Scheduler)
+	}
+	if params.SampleSteps != 30 || params.Guidance.TxtCfg != 7 || params.Eta != 0.5 {
+		t.Errorf("unexpected params %+v", params)
+	}
+
+	if err := ApplyPreset(&params, "no-such-preset", nil); err == nil {
+		t.Error("expected error for unknown preset")
+	}
+}
+
+func TestApplyPresetDefaults(t *testing.T) {
+	// flux-dev pins the sampler but not the scheduler
+	params := stablediffusion.SDSampleParams{Scheduler: stablediffusion.KarrasScheduler}
+	if err := ApplyPreset(&params, "flux-dev", fakeDefaults{}); err != nil {
+		t.Fatal(err)
+	}
+	if params.SampleMethod != stablediffusion.EulerSampleMethod || params.Scheduler != stablediffusion.DiscreteScheduler {
+		t.Errorf("expected euler with the model default scheduler, got %v/%v", params.SampleMethod, params.Scheduler)
+	}
+	if params.Guidance.DistilledGuidance != 3.5 {
+		t.Errorf("expected distilled guidance 3.5, got %v", params.Guidance.DistilledGuidance)
+	}
+
+	// Without defaults the scheduler is left alone
+	params = stablediffusion.SDSampleParams{Scheduler: stablediffusion.KarrasScheduler}
+	if err := ApplyPreset(&params, "flux-dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	if params.Scheduler != stablediffusion.KarrasScheduler {
+		t.Errorf("scheduler should be unchanged without defaults, got %v", params.Scheduler)
+	}
+
+	// A user preset without a sampler takes both from the model
+	s := Builtin()
+	s.Set("bare", Preset{Steps: 12})
+	params = stablediffusion.SDSampleParams{}
+	if err := s.Apply(&params, "bare", fakeDefaults{}); err != nil {
+		t.Fatal(err)
+	}
+	if params.SampleMethod != stablediffusion.EulerASampleMethod || params.SampleSteps != 12 {
+		t.Errorf("expected model default sampler, got %v with %d steps", params.SampleMethod, params.SampleSteps)
+	}
+}
+
+func TestPresetSLGAndContext(t *testing.T) {
+	var params stablediffusion.SDSampleParams
+	if err := ApplyPreset(&params, "sd35-medium-slg", nil); err != nil {
+		t.Fatal(err)
+	}
+	slg := params.Guidance.SLG
+	layers := unsafe.Slice(slg.Layers, slg.LayerCount)
+	if len(layers) != 3 || layers[0] != 7 || slg.Scale != 2.5 {
+		t.Errorf("unexpected SLG %+v %v", slg, layers)
+	}
+
+	var ctxParams stablediffusion.SDContextParams
+	if err := ApplyContextPreset(&ctxParams, "wan"); err != nil {
+		t.Fatal(err)
+	}
+	if ctxParams.FlowShift != 3 {
+		t.Errorf("expected flow shift 3, got %v", ctxParams.FlowShift)
+	}
+	p, _ := Default.Get("sd2-v")
+	p.ApplyContext(&ctxParams)
+	if ctxParams.Prediction != stablediffusion.VPred {
+		t.Errorf("expected v prediction, got %v", ctxParams.Prediction)
+	}
+}
+
+func TestParseOverrides(t *testing.T) {
+	s := Builtin()
+	err := s.Parse([]byte(`
+sdxl-quality:
+  steps: 40
+  scheduler: exponential
+my-flux:
+  arch: flux
+  sample_method: euler
+  steps: 12
+  distilled_guidance: 3.0
+  prediction: flux_flow
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	p, err := s.Get("sdxl-quality")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Steps != 40 || *p.Scheduler != stablediffusion.ExponentialScheduler {
+		t.Errorf("override not applied: %d steps, %v", p.Steps, *p.Scheduler)
+	}
+	if *p.CFG != 7 || *p.SampleMethod != stablediffusion.DPMPP2MSampleMethod {
+		t.Errorf("fields missing from the YAML should be kept, got cfg %v %v", *p.CFG, *p.SampleMethod)
+	}
+	if got := s.ForArch("flux"); len(got) != 3 || got[2] != "my-flux" {
+		t.Errorf("unexpected flux presets %v", got)
+	}
+
+	// Overrides stay local to the set
+	orig, _ := Builtin().Get("sdxl-quality")
+	if orig.Steps != 30 || *orig.Scheduler != stablediffusion.KarrasScheduler {
+		t.Error("Parse modified the built-in presets")
+	}
+
+	if err := s.Parse([]byte("bad:\n  sample_method: nope\n")); err == nil {
+		t.Error("expected error for unknown sample method")
+	}
+	if err := s.Parse([]byte("[1, 2")); err == nil {
+		t.Error("expected error for invalid YAML")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	if err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml")); err != nil {
+		t.Errorf("missing preset file should not be an error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "presets.yaml")
+	if err := os.WriteFile(path, []byte("lcm:\n  steps: 8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := Builtin()
+	if err := s.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if p, _ := s.Get("lcm"); p.Steps != 8 || *p.Scheduler != stablediffusion.LCMScheduler {
+		t.Errorf("unexpected lcm preset %+v", p)
+	}
+}
+
+func TestBuiltinPresets(t *testing.T) {
+	for _, name := range Default.Names() {
+		p, _ := Default.Get(name)
+		if p.Arch == "" || p.Steps <= 0 || p.CFG == nil || *p.CFG <= 0 {
+			t.Errorf("%s: incomplete preset %+v", name, p)
+		}
+	}
+}
+
+func TestParseZeroValues(t *testing.T) {
+	s := Builtin()
+	if err := s.Parse([]byte("sdxl-quality:\n  cfg: 0\n  eta: 0\nwan:\n  flow_shift: 0\n")); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	params := stablediffusion.SDSampleParams{Eta: 0.5, Guidance: stablediffusion.SDGuidanceParams{TxtCfg: 5}}
+	if err := s.Apply(&params, "sdxl-quality", nil); err != nil {
+		t.Fatal(err)
+	}
+	if params.Guidance.TxtCfg != 0 || params.Eta != 0 {
+		t.Errorf("explicit zeros not applied: cfg %v, eta %v", params.Guidance.TxtCfg, params.Eta)
+	}
+
+	ctxParams := stablediffusion.SDContextParams{FlowShift: 5}
+	p, _ := s.Get("wan")
+	p.ApplyContext(&ctxParams)
+	if ctxParams.FlowShift != 0 {
+		t.Errorf("explicit zero flow shift not applied, got %v", ctxParams.FlowShift)
+	}
+}
+
+func TestApplyNilContextDefaults(t *testing.T) {
+	var ctx *stablediffusion.SDContext
+	params := stablediffusion.SDSampleParams{SampleMethod: stablediffusion.LCMSampleMethod, Scheduler: stablediffusion.KarrasScheduler}
+	if err := ApplyPreset(&params, "flux-dev", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if params.Scheduler != stablediffusion.KarrasScheduler {
+		t.Errorf("a nil context should leave the scheduler unchanged, got %v", params.Scheduler)
+	}
+}
+
+func TestSetConcurrentUse(t *testing.T) {
+	s := Builtin()
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := s.Parse([]byte("lcm:\n  steps: 8\n")); err != nil {
+				t.Error(err)
+			}
+			s.Set(fmt.Sprintf("user-%d", i), Preset{Arch: "sdxl"})
+		}()
+		go func() {
+			defer wg.Done()
+			var params stablediffusion.SDSampleParams
+			if err := s.Apply(&params, "lcm", nil); err != nil {
+				t.Error(err)
+			}
+			s.ForArch("sdxl")
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,319 @@
+// Package dynamic expands prompt templates into concrete prompts.
+//
+// Template syntax:
+//
+//	{red|blue|green}      one of the alternatives
+//	{3::red|1::blue}      weighted alternatives (weights only affect Random mode)
+//	__animals__           one line of animals.txt in the wildcard directory
+//	{1..5} {0.5..1:0.25}  a number from an inclusive range with optional step
+//	${color={red|blue}}   bind a variable, expanding to nothing
+//	${color}              the bound value
+//
+// \{ \} \| \$ and \_ escape the special characters. Anything else, including
+// weighting syntax such as (word:1.2), passes through unchanged.
+package dynamic
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kawai-network/stablediffusion"
+	"github.com/kawai-network/stablediffusion/prompt"
+)
+
+// Mode selects how a template is expanded
+type Mode int
+
+const (
+	// Random samples each choice independently using the seed
+	Random Mode = iota
+	// Combinatorial enumerates every combination in order
+	Combinatorial
+)
+
+// DefaultLimit caps combinatorial expansion when no count is given
+const DefaultLimit = 1000
+
+// maxDepth bounds wildcard and variable nesting so cyclic wildcards fail cleanly
+const maxDepth = 32
+
+// errStop ends a combinatorial walk once enough prompts were produced
+var errStop = errors.New("stop")
+
+// Engine expands templates. The zero value uses Random mode with seed 0.
+type Engine struct {
+	// WildcardDir holds the name.txt files referenced as __name__
+	WildcardDir string
+	Mode        Mode
+	Seed        int64
+	// Vars are predefined variables, used verbatim
+	Vars map[string]string
+
+	wildcards map[string][]seq
+}
+
+// Expand returns n prompts from template. In Combinatorial mode n <= 0 means
+// every combination up to DefaultLimit; in Random mode it means 1.
+func (e *Engine) Expand(template string, n int) ([]string, error) {
+	tmpl, err := parse(template, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	if e.Mode == Combinatorial {
+		if n <= 0 {
+			n = DefaultLimit
+		}
+		err := e.each(tmpl, e.initialVars(), 0, func(s string, _ map[string]string) error {
+			out = append(out, clean(s))
+			if len(out) >= n {
+				return errStop
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStop) {
+			return nil, err
+		}
+		return out, nil
+	}
+
+	if n <= 0 {
+		n = 1
+	}
+	rng := rand.New(rand.NewPCG(uint64(e.Seed), 0x5eed))
+	for range n {
+		s, err := e.sample(tmpl, e.initialVars(), rng, 0)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, clean(s))
+	}
+	return out, nil
+}
+
+func (e *Engine) initialVars() map[string]string {
+	vars := make(map[string]string, len(e.Vars))
+	for k, v := range e.Vars {
+		vars[k] = v
+	}
+	return vars
+}
+
+// clean collapses the whitespace left behind by empty alternatives and definitions
+func clean(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// sample expands nodes once, choosing with rng. Variable definitions update vars in place.
+func (e *Engine) sample(nodes seq, vars map[string]string, rng *rand.Rand, depth int) (string, error) {
+	if depth > maxDepth {
+		return "", fmt.Errorf("template nesting deeper than %d, is a wildcard recursive?", maxDepth)
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case text:
+			b.WriteString(string(n))
+		case choice:
+			s, err := e.sample(pickWeighted(n.options, rng), vars, rng, depth+1)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(s)
+		case numRange:
+			b.WriteString(n.value(rng.IntN(n.count())))
+		case wildcard:
+			lines, err := e.wildcard(n)
+			if err != nil {
+				return "", err
+			}
+			s, err := e.sample(lines[rng.IntN(len(lines))], vars, rng, depth+1)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(s)
+		case varDef:
+			s, err := e.sample(n.value, vars, rng, depth+1)
+			if err != nil {
+				return "", err
+			}
+			vars[n.name] = s
+		case varRef:
+			v, ok := vars[n.name]
+			if !ok {
+				return "", &prompt.SyntaxError{Offset: n.offset, Msg: fmt.Sprintf("undefined variable %q", n.name)}
+			}
+			b.WriteString(v)
+		}
+	}
+	return b.String(), nil
+}
+
+func pickWeighted(options []option, rng *rand.Rand) seq {
+	total := 0.0
+	for _, o := range options {
+		total += o.weight
+	}
+	r := rng.Float64() * total
+	for _, o := range options {
+		if r < o.weight {
+			return o.body
+		}
+		r -= o.weight
+	}
+	return options[len(options)-1].body
+}
+
+// each calls fn with every expansion of nodes in order
+func (e *Engine) each(nodes seq, vars map[string]string, depth int, fn func(string, map[string]string) error) error {
+	if depth > maxDepth {
+		return fmt.Errorf("template nesting deeper than %d, is a wildcard recursive?", maxDepth)
+	}
+	var walk func(i int, prefix string, vars map[string]string) error
+	walk = func(i int, prefix string, vars map[string]string) error {
+		if i == len(nodes) {
+			return fn(prefix, vars)
+		}
+		return e.values(nodes[i], vars, depth, func(v string, vars map[string]string) error {
+			return walk(i+1, prefix+v, vars)
+		})
+	}
+	return walk(0, "", vars)
+}
+
+// values calls fn with every expansion of a single node
+func (e *Engine) values(n node, vars map[string]string, depth int, fn func(string, map[string]string) error) error {
+	switch n := n.(type) {
+	case text:
+		return fn(string(n), vars)
+	case choice:
+		for _, o := range n.options {
+			if err := e.each(o.body, vars, depth+1, fn); err != nil {
+				return err
+			}
+		}
+	case numRange:
+		for i := range n.count() {
+			if err := fn(n.value(i), vars); err != nil {
+				return err
+			}
+		}
+	case wildcard:
+		lines, err := e.wildcard(n)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if err := e.each(line, vars, depth+1, fn); err != nil {
+				return err
+			}
+		}
+	case varDef:
+		return e.each(n.value, vars, depth+1, func(v string, vars map[string]string) error {
+			bound := make(map[string]string, len(vars)+1)
+			for k, val := range vars {
+				bound[k] = val
+			}
+			bound[n.name] = v
+			return fn("", bound)
+		})
+	case varRef:
+		v, ok := vars[n.name]
+		if !ok {
+			return &prompt.SyntaxError{Offset: n.offset, Msg: fmt.Sprintf("undefined variable %q", n.name)}
+		}
+		return fn(v, vars)
+	}
+	return nil
+}
+
+// wildcard loads and parses name.txt, skipping blank lines and # comments
+func (e *Engine) wildcard(w wildcard) ([]seq, error) {
+	if lines, ok := e.wildcards[w.name]; ok {
+		return lines, nil
+	}
+	if e.WildcardDir == "" {
+		return nil, fmt.Errorf("wildcard __%s__ used but no wildcard directory is set", w.name)
+	}
+
+	if !filepath.IsLocal(filepath.FromSlash(w.name)) {
+		return nil, fmt.Errorf("wildcard __%s__ must be a relative path inside the wildcard directory", w.name)
+	}
+	path := filepath.Join(e.WildcardDir, filepath.FromSlash(w.name)+".txt")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wildcard __%s__: %w", w.name, err)
+	}
+
+	var lines []seq
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parsed, err := parse(line, 0)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, i+1, err)
+		}
+		lines = append(lines, parsed)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("wildcard __%s__ has no entries", w.name)
+	}
+
+	if e.wildcards == nil {
+		e.wildcards = make(map[string][]seq)
+	}
+	e.wildcards[w.name] = lines
+	return lines, nil
+}
+
+// Job is one expanded prompt with the parameters to generate it
+type Job struct {
+	Prompt string
+	Params stablediffusion.SDImgGenParams
+}
+
+// Jobs expands template into n jobs copied from base, each with its own prompt,
+// BatchCount 1 and seed base.Seed+i. A negative base seed uses the engine seed
+// instead, so a rerun with the same engine reproduces every image.
+func (e *Engine) Jobs(template string, n int, base *stablediffusion.SDImgGenParams) ([]Job, error) {
+	prompts, err := e.Expand(template, n)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := base.Seed
+	if seed < 0 {
+		seed = e.Seed
+	}
+	jobs := make([]Job, len(prompts))
+	for i, p := range prompts {
+		jobs[i] = Job{Prompt: p, Params: *base}
+		jobs[i].Params.Prompt = stablediffusion.CString(p)
+		jobs[i].Params.Seed = seed + int64(i)
+		jobs[i].Params.BatchCount = 1
+	}
+	return jobs, nil
+}
+
+// Run generates each job in order and passes the image to fn. It stops at the
+// first error from generation or fn.
+func Run(ctx *stablediffusion.SDContext, jobs []Job, fn func(i int, job *Job, img image.Image) error) error {
+	for i := range jobs {
+		img, err := ctx.Generate(&jobs[i].Params)
+		if err != nil {
+			return fmt.Errorf("job %d (%q): %w", i, jobs[i].Prompt, err)
+		}
+		if err := fn(i, &jobs[i], img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package dynamic
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kawai-network/stablediffusion"
+	"github.com/kawai-network/stablediffusion/prompt"
+)
+
+func wildcardDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name+".txt")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCombinatorial(t *testing.T) {
+	dir := wildcardDir(t, map[string]string{
+		"animals":     "# comment\ncat\n\n{small|big} dog\n",
+		"nested/size": "tiny",
+	})
+	e := &Engine{WildcardDir: dir, Mode: Combinatorial}
+
+	tests := []struct {
+		template string
+		want     []string
+	}{
+		{"a {red|blue} __animals__", []string{"a red cat", "a red small dog", "a red big dog", "a blue cat", "a blue small dog", "a blue big dog"}},
+		{"step {1..3}", []string{"step 1", "step 2", "step 3"}},
+		{"cfg {0.5..1:0.25}", []string{"cfg 0.50", "cfg 0.75", "cfg 1.00"}},
+		{"${c={red|blue}}${c} hat, ${c} shoes", []string{"red hat, red shoes", "blue hat, blue shoes"}},
+		{"{a|} b", []string{"a b", "b"}},
+		{`\{literal\} (word:1.2) __nested/size__`, []string{"{literal} (word:1.2) tiny"}},
+		{"{2::x|1::y}", []string{"x", "y"}},
+	}
+	for _, tt := range tests {
+		got, err := e.Expand(tt.template, 0)
+		if err != nil {
+			t.Errorf("Expand(%q) failed: %v", tt.template, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Expand(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+
+	got, err := e.Expand("{a|b|c} {1..10}", 4)
+	if err != nil || len(got) != 4 || got[3] != "a 4" {
+		t.Errorf("limited expansion = %q, %v", got, err)
+	}
+}
+
+func TestRandomDeterministic(t *testing.T) {
+	e1 := &Engine{Seed: 42}
+	e2 := &Engine{Seed: 42}
+	template := "{red|green|blue} {cat|dog} {1..100}"
+
+	a, err := e1.Expand(template, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := e2.Expand(template, 20)
+	if !slices.Equal(a, b) {
+		t.Error("same seed produced different prompts")
+	}
+	c, _ := (&Engine{Seed: 43}).Expand(template, 20)
+	if slices.Equal(a, c) {
+		t.Error("different seeds produced identical prompts")
+	}
+}
+
+func TestRandomWeightsAndVars(t *testing.T) {
+	e := &Engine{Seed: 1}
+	got, err := e.Expand("{9::common|1::rare}", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rare := 0
+	for _, s := range got {
+		if s == "rare" {
+			rare++
+		}
+	}
+	if rare < 50 || rare > 150 {
+		t.Errorf("rare chosen %d of 1000 times, expected about 100", rare)
+	}
+
+	e.Vars = map[string]string{"y": "sunny"}
+	got, err = e.Expand("${x={a|b}}${x}=${x} ${y}", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range got {
+		if s != "a=a sunny" && s != "b=b sunny" {
+			t.Errorf("variable not reused consistently: %q", s)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	dir := wildcardDir(t, map[string]string{"loop": "again __loop__", "empty": "# nothing\n"})
+	e := &Engine{WildcardDir: dir, Mode: Combinatorial}
+
+	syntax := map[string]int{
+		"a {b|c":        2,
+		"a }":           2,
+		"${1bad}":       0,
+		"{3..1}":        1,
+		"x ${missing}":  2,
+		"{0::a|0::b}":   1,
+		"{a|{b|c}} {d":  10,
+		"${v={a|b}":     0,
+		"{1..2:0}":      1,
+		"a ${ok} ${ok}": 2,
+	}
+	for template, offset := range syntax {
+		_, err := e.Expand(template, 0)
+		var se *prompt.SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("Expand(%q) = %v, want SyntaxError", template, err)
+			continue
+		}
+		if se.Offset != offset {
+			t.Errorf("Expand(%q) offset = %d, want %d", template, se.Offset, offset)
+		}
+	}
+
+	for _, template := range []string{"__loop__", "__empty__", "__missing__"} {
+		if _, err := e.Expand(template, 0); err == nil {
+			t.Errorf("Expand(%q) should fail", template)
+		}
+	}
+	if _, err := (&Engine{}).Expand("__animals__", 1); err == nil {
+		t.Error("wildcard without a directory should fail")
+	}
+}
+
+func TestWildcardOutsideDir(t *testing.T) {
+	root := wildcardDir(t, map[string]string{"secret": "password", "wildcards/colors": "red"})
+	e := &Engine{WildcardDir: filepath.Join(root, "wildcards"), Mode: Combinatorial}
+	if got, err := e.Expand("__colors__", 0); err != nil || !slices.Equal(got, []string{"red"}) {
+		t.Fatalf("Expand(__colors__) = %v, %v", got, err)
+	}
+	for _, template := range []string{"__../secret__", "__sub/../../secret__"} {
+		if got, err := e.Expand(template, 0); err == nil {
+			t.Errorf("Expand(%q) = %v, want error", template, got)
+		}
+	}
+}
+
+func TestJobs(t *testing.T) {
+	e := &Engine{Mode: Combinatorial, Seed: 100}
+	base := stablediffusion.SDImgGenParams{Width: 512, Height: 512, Seed: -1, BatchCount: 4}
+
+	jobs, err := e.Jobs("a {cat|dog}", 0, &base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(jobs))
+	}
+	for i, job := range jobs {
+		if stablediffusion.CGoString(job.Params.Prompt) != job.Prompt {
+			t.Errorf("job %d prompt %q does not match params", i, job.Prompt)
+		}
+		if job.Params.Seed != 100+int64(i) || job.Params.BatchCount != 1 || job.Params.Width != 512 {
+			t.Errorf("job %d params = seed %d batch %d", i, job.Params.Seed, job.Params.BatchCount)
+		}
+	}
+
+	base.Seed = 7
+	jobs, _ = e.Jobs("a {cat|dog}", 0, &base)
+	if jobs[1].Params.Seed != 8 {
+		t.Errorf("explicit base seed not used: %d", jobs[1].Params.Seed)
+	}
+}
@@ -0,0 +1,242 @@
+package dynamic
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kawai-network/stablediffusion/prompt"
+)
+
+// node is one element of a parsed template
+type node interface{}
+
+// seq is a run of nodes concatenated together
+type seq []node
+
+type text string
+
+type option struct {
+	weight float64
+	body   seq
+}
+
+// choice is {a|b|c}, optionally weighted as {2::a|1::b}
+type choice struct {
+	options []option
+}
+
+// wildcard is __name__, one option per line of name.txt
+type wildcard struct {
+	name   string
+	offset int
+}
+
+// numRange is {lo..hi} or {lo..hi:step}
+type numRange struct {
+	lo, hi, step float64
+	decimals     int
+}
+
+func (r numRange) count() int {
+	return int((r.hi-r.lo)/r.step+1e-9) + 1
+}
+
+func (r numRange) value(i int) string {
+	return strconv.FormatFloat(r.lo+float64(i)*r.step, 'f', r.decimals, 64)
+}
+
+// varDef is ${name=value}; it binds name and expands to nothing
+type varDef struct {
+	name  string
+	value seq
+}
+
+// varRef is ${name}
+type varRef struct {
+	name   string
+	offset int
+}
+
+var (
+	rangeRe    = regexp.MustCompile(`^\s*(-?\d+(?:\.\d+)?)\s*\.\.\s*(-?\d+(?:\.\d+)?)\s*(?::\s*(\d+(?:\.\d+)?)\s*)?$`)
+	wildcardRe = regexp.MustCompile(`^__([\w./-]+?)__`)
+	varNameRe  = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+	weightRe   = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)::`)
+)
+
+// parse parses s; off is the offset of s within the full template for error positions
+func parse(s string, off int) (seq, error) {
+	var (
+		out seq
+		buf strings.Builder
+	)
+	flush := func() {
+		if buf.Len() > 0 {
+			out = append(out, text(buf.String()))
+			buf.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(`{}|$_`, s[i+1]) >= 0:
+			i++
+			buf.WriteByte(s[i])
+		case c == '{':
+			end, err := matchBrace(s, i, off+i)
+			if err != nil {
+				return nil, err
+			}
+			n, err := parseBraces(s[i+1:end], off+i+1)
+			if err != nil {
+				return nil, err
+			}
+			flush()
+			out = append(out, n)
+			i = end
+		case c == '$' && i+1 < len(s) && s[i+1] == '{':
+			end, err := matchBrace(s, i+1, off+i)
+			if err != nil {
+				return nil, err
+			}
+			n, err := parseVar(s[i+2:end], off+i)
+			if err != nil {
+				return nil, err
+			}
+			flush()
+			out = append(out, n)
+			i = end
+		case c == '}':
+			return nil, &prompt.SyntaxError{Offset: off + i, Msg: "unmatched '}'"}
+		case c == '_' && strings.HasPrefix(s[i:], "__"):
+			m := wildcardRe.FindStringSubmatch(s[i:])
+			if m == nil {
+				buf.WriteByte(c)
+				continue
+			}
+			flush()
+			out = append(out, wildcard{name: m[1], offset: off + i})
+			i += len(m[0]) - 1
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	flush()
+	return out, nil
+}
+
+// matchBrace returns the index of the '}' closing the '{' at s[i]. errOffset
+// is reported when the brace is unclosed.
+func matchBrace(s string, i, errOffset int) (int, error) {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return j, nil
+			}
+		}
+	}
+	return 0, &prompt.SyntaxError{Offset: errOffset, Msg: "unclosed '{'"}
+}
+
+// splitTop splits s at '|' characters outside nested braces
+func splitTop(s string) []int {
+	var cuts []int
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '|':
+			if depth == 0 {
+				cuts = append(cuts, i)
+			}
+		}
+	}
+	return cuts
+}
+
+func parseBraces(body string, off int) (node, error) {
+	if m := rangeRe.FindStringSubmatch(body); m != nil {
+		return parseRange(m, off)
+	}
+
+	var c choice
+	start := 0
+	for _, end := range append(splitTop(body), len(body)) {
+		part, partOff := body[start:end], off+start
+		opt := option{weight: 1}
+		if m := weightRe.FindStringSubmatch(part); m != nil {
+			w, _ := strconv.ParseFloat(m[1], 64)
+			opt.weight = w
+			part, partOff = part[len(m[0]):], partOff+len(m[0])
+		}
+		body, err := parse(part, partOff)
+		if err != nil {
+			return nil, err
+		}
+		opt.body = body
+		c.options = append(c.options, opt)
+		start = end + 1
+	}
+
+	total := 0.0
+	for _, o := range c.options {
+		total += o.weight
+	}
+	if total <= 0 {
+		return nil, &prompt.SyntaxError{Offset: off, Msg: "alternatives have zero total weight"}
+	}
+	return c, nil
+}
+
+func parseRange(m []string, off int) (node, error) {
+	decimals := 0
+	for _, s := range m[1:] {
+		if _, frac, ok := strings.Cut(s, "."); ok {
+			decimals = max(decimals, len(frac))
+		}
+	}
+	r := numRange{step: 1, decimals: decimals}
+	r.lo, _ = strconv.ParseFloat(m[1], 64)
+	r.hi, _ = strconv.ParseFloat(m[2], 64)
+	if m[3] != "" {
+		r.step, _ = strconv.ParseFloat(m[3], 64)
+	}
+	if r.step <= 0 {
+		return nil, &prompt.SyntaxError{Offset: off, Msg: "range step must be positive"}
+	}
+	if r.hi < r.lo {
+		return nil, &prompt.SyntaxError{Offset: off, Msg: fmt.Sprintf("range %s..%s is empty", m[1], m[2])}
+	}
+	return r, nil
+}
+
+func parseVar(body string, off int) (node, error) {
+	name, value, isDef := strings.Cut(body, "=")
+	name = strings.TrimSpace(name)
+	if !varNameRe.MatchString(name) {
+		return nil, &prompt.SyntaxError{Offset: off, Msg: fmt.Sprintf("invalid variable name %q", name)}
+	}
+	if !isDef {
+		return varRef{name: name, offset: off}, nil
+	}
+	v, err := parse(value, off+2+len(body)-len(value))
+	if err != nil {
+		return nil, err
+	}
+	return varDef{name: name, value: v}, nil
+}
@@ -0,0 +1,142 @@
+package prompt
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Warning is a prompt lint finding
+type Warning struct {
+	Offset int
+	Msg    string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("offset %d: %s", w.Offset, w.Msg)
+}
+
+var (
+	// embeddingRefRe matches ComfyUI style embedding:name references
+	embeddingRefRe = regexp.MustCompile(`embedding:([^\s,()\[\]<>:]+)`)
+	// tokenRe matches the bare words that stable-diffusion.cpp compares against embedding names
+	tokenRe = regexp.MustCompile(`[^\s,()\[\]<>:|{}]+`)
+	// tagRe matches <lora:...> style tags, whose contents are not prompt words
+	tagRe = regexp.MustCompile(`<[^>]*>`)
+	// versionRe matches the version suffix most embedding files carry, e.g. _v2 or -v1.5
+	versionRe = regexp.MustCompile(`[_-]v\d+(\.\d+)?$`)
+)
+
+// LintEmbeddings reports embedding references in text that loaded cannot
+// satisfy. stable-diffusion.cpp matches embeddings by bare name, so an
+// embedding:name reference is flagged even when name is loaded. Bare words
+// that nearly match a loaded name, or that look like an embedding file name
+// but match none, are flagged too.
+func LintEmbeddings(text string, loaded []string) []Warning {
+	var warnings []Warning
+	var skip [][]int
+	for _, m := range embeddingRefRe.FindAllStringSubmatchIndex(text, -1) {
+		skip = append(skip, m[:2])
+		ref := text[m[2]:m[3]]
+		name := trimEmbeddingExt(path.Base(ref))
+		if slices.Contains(loaded, name) {
+			warnings = append(warnings, Warning{
+				Offset: m[0],
+				Msg:    fmt.Sprintf("write embedding %q as %q; the embedding: prefix is not recognized", ref, name),
+			})
+			continue
+		}
+		warnings = append(warnings, Warning{
+			Offset: m[0],
+			Msg:    fmt.Sprintf("embedding %q is not loaded", name),
+		})
+	}
+
+	skip = append(skip, tagRe.FindAllStringIndex(text, -1)...)
+	for _, m := range tokenRe.FindAllStringIndex(text, -1) {
+		if inSpans(m[0], skip) {
+			continue
+		}
+		if w, ok := lintToken(text[m[0]:m[1]], loaded); ok {
+			w.Offset = m[0]
+			warnings = append(warnings, w)
+		}
+	}
+	slices.SortStableFunc(warnings, func(a, b Warning) int { return a.Offset - b.Offset })
+	return warnings
+}
+
+// lintToken checks one bare prompt word against the loaded embedding names
+func lintToken(token string, loaded []string) (Warning, bool) {
+	if slices.Contains(loaded, token) {
+		return Warning{}, false
+	}
+	name := trimEmbeddingExt(token)
+	if name != token {
+		if slices.Contains(loaded, name) {
+			return Warning{Msg: fmt.Sprintf("write embedding %q as %q; file extensions are not recognized", token, name)}, true
+		}
+		return Warning{Msg: fmt.Sprintf("embedding %q is not loaded", name)}, true
+	}
+	for _, l := range loaded {
+		if nearMiss(token, l) {
+			return Warning{Msg: fmt.Sprintf("%q is not a loaded embedding; did you mean %q?", token, l)}, true
+		}
+	}
+	if versionRe.MatchString(token) {
+		return Warning{Msg: fmt.Sprintf("%q looks like an embedding but is not loaded", token)}, true
+	}
+	return Warning{}, false
+}
+
+// nearMiss reports whether token differs from name only in case or separators,
+// or, for longer names, by a single edit
+func nearMiss(token, name string) bool {
+	if normalizeName(token) == normalizeName(name) {
+		return true
+	}
+	return len(name) >= 6 && editDistance(strings.ToLower(token), strings.ToLower(name)) == 1
+}
+
+func normalizeName(s string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "", " ", "").Replace(s))
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func trimEmbeddingExt(name string) string {
+	if ext := strings.ToLower(path.Ext(name)); ext == ".pt" || ext == ".safetensors" || ext == ".bin" {
+		return strings.TrimSuffix(name, path.Ext(name))
+	}
+	return name
+}
+
+func inSpans(offset int, spans [][]int) bool {
+	for _, s := range spans {
+		if offset >= s[0] && offset < s[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLintEmbeddings(t *testing.T) {
+	loaded := []string{"easynegative", "v1.5style"}
+
+	if w := LintEmbeddings("easynegative, blurry, v1.5style", loaded); len(w) != 0 {
+		t.Errorf("bare loaded names should not warn: %v", w)
+	}
+
+	w := LintEmbeddings("embedding:easynegative.pt, (embedding:badhands:1.2), embedding:v1.5style", loaded)
+	if len(w) != 3 {
+		t.Fatalf("expected 3 warnings, got %v", w)
+	}
+	if w[0].Offset != 0 || !strings.Contains(w[0].Msg, "prefix") {
+		t.Errorf("loaded prefixed reference: %v", w[0])
+	}
+	if !strings.Contains(w[1].Msg, `"badhands" is not loaded`) {
+		t.Errorf("missing embedding: %v", w[1])
+	}
+	if !strings.Contains(w[2].Msg, "prefix") {
+		t.Errorf("dotted name should match loaded embedding: %v", w[2])
+	}
+}
+
+func TestLintEmbeddingsBareTokens(t *testing.T) {
+	loaded := []string{"easynegative", "bad-hands-5"}
+
+	if w := LintEmbeddings("a cat, <lora:easynegatve:0.8>, (easynegative:1.2), detailed", loaded); len(w) != 0 {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+
+	text := "EasyNegative, bad_hands_5, easynegatve, easynegative.pt, ng_deepnegative_v1, cat"
+	w := LintEmbeddings(text, loaded)
+	if len(w) != 5 {
+		t.Fatalf("expected 5 warnings, got %v", w)
+	}
+	for i, want := range []string{
+		`did you mean "easynegative"`,
+		`did you mean "bad-hands-5"`,
+		`did you mean "easynegative"`,
+		"extensions are not recognized",
+		"looks like an embedding",
+	} {
+		if !strings.Contains(w[i].Msg, want) {
+			t.Errorf("warning %d: got %q, want %q", i, w[i].Msg, want)
+		}
+	}
+	if w[1].Offset != strings.Index(text, "bad_hands_5") {
+		t.Errorf("unexpected offset %d", w[1].Offset)
+	}
+}
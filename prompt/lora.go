@@ -0,0 +1,75 @@
+package prompt
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LoraTag is an inline <lora:name:weight> or <lora:name:weight:high> tag
+type LoraTag struct {
+	Name       string
+	Multiplier float64
+	// HighNoise selects the Wan2.2 high-noise expert
+	HighNoise bool
+	// Offset is the byte offset of the tag in the original prompt
+	Offset int
+}
+
+// highNoisePrefix is the stable-diffusion.cpp CLI spelling for high-noise LoRAs
+const highNoisePrefix = "|high_noise|"
+
+var loraTagRe = regexp.MustCompile(`<lora:([^<>]*)>`)
+
+// ExtractLoras removes LoRA tags from s and returns the remaining prompt with
+// whitespace collapsed. A missing weight means 1. Both <lora:name:0.8:high>
+// and <lora:|high_noise|name:0.8> mark a high-noise LoRA.
+func ExtractLoras(s string) (string, []LoraTag, error) {
+	var tags []LoraTag
+	for _, m := range loraTagRe.FindAllStringSubmatchIndex(s, -1) {
+		tag, err := parseLoraTag(s[m[2]:m[3]], m[0])
+		if err != nil {
+			return "", nil, err
+		}
+		tags = append(tags, tag)
+	}
+	if len(tags) == 0 {
+		return s, nil, nil
+	}
+	stripped := loraTagRe.ReplaceAllString(s, " ")
+	return strings.Join(strings.Fields(stripped), " "), tags, nil
+}
+
+func parseLoraTag(body string, offset int) (LoraTag, error) {
+	parts := strings.Split(body, ":")
+	tag := LoraTag{Name: strings.TrimSpace(parts[0]), Multiplier: 1, Offset: offset}
+
+	if rest, ok := strings.CutPrefix(tag.Name, highNoisePrefix); ok {
+		tag.Name, tag.HighNoise = rest, true
+	}
+	if tag.Name == "" {
+		return LoraTag{}, &SyntaxError{Offset: offset, Msg: "lora tag without a name"}
+	}
+	if len(parts) > 3 {
+		return LoraTag{}, &SyntaxError{Offset: offset, Msg: fmt.Sprintf("too many fields in lora tag %q", body)}
+	}
+
+	if len(parts) > 1 {
+		w, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return LoraTag{}, &SyntaxError{Offset: offset, Msg: fmt.Sprintf("invalid lora weight %q", parts[1])}
+		}
+		tag.Multiplier = w
+	}
+	if len(parts) > 2 {
+		switch strings.ToLower(strings.TrimSpace(parts[2])) {
+		case "high", "high_noise":
+			tag.HighNoise = true
+		case "low", "":
+		default:
+			return LoraTag{}, &SyntaxError{Offset: offset, Msg: fmt.Sprintf("unknown lora expert %q, want high or low", parts[2])}
+		}
+	}
+	return tag, nil
+}
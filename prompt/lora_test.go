@@ -0,0 +1,46 @@
+package prompt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExtractLoras(t *testing.T) {
+	text, tags, err := ExtractLoras("a cat <lora:detail:0.8>, <lora:style> <lora:motion:0.5:high> <lora:|high_noise|wan:1.2> sitting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "a cat , sitting" {
+		t.Errorf("stripped prompt = %q", text)
+	}
+	want := []LoraTag{
+		{Name: "detail", Multiplier: 0.8, Offset: 6},
+		{Name: "style", Multiplier: 1},
+		{Name: "motion", Multiplier: 0.5, HighNoise: true},
+		{Name: "wan", Multiplier: 1.2, HighNoise: true},
+	}
+	if len(tags) != len(want) {
+		t.Fatalf("got %d tags, want %d", len(tags), len(want))
+	}
+	for i, tag := range tags {
+		if tag.Name != want[i].Name || tag.Multiplier != want[i].Multiplier || tag.HighNoise != want[i].HighNoise {
+			t.Errorf("tag %d = %+v, want %+v", i, tag, want[i])
+		}
+	}
+	if tags[0].Offset != 6 {
+		t.Errorf("offset = %d, want 6", tags[0].Offset)
+	}
+}
+
+func TestExtractLorasErrors(t *testing.T) {
+	for _, s := range []string{"<lora::1>", "a <lora:x:heavy>", "<lora:x:1:mid>", "<lora:x:1:high:2>"} {
+		_, _, err := ExtractLoras(s)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("ExtractLoras(%q) = %v, want SyntaxError", s, err)
+		}
+	}
+	if text, tags, err := ExtractLoras("no tags here"); err != nil || tags != nil || text != "no tags here" {
+		t.Errorf("untagged prompt changed: %q %v %v", text, tags, err)
+	}
+}
@@ -0,0 +1,351 @@
+// Package prompt parses A1111/ComfyUI style prompt weighting.
+//
+// Supported syntax: (text) multiplies attention by 1.1, [text] divides it by
+// 1.1, (text:1.3) sets an explicit multiplier, \( \) \[ \] and \\ escape
+// literal characters, and BREAK starts a new conditioning chunk. <...> tags
+// such as <lora:name:0.8> are kept verbatim. Nested groups multiply.
+package prompt
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Emphasis is the multiplier applied by each level of () and removed by each level of []
+const Emphasis = 1.1
+
+// Segment is a run of prompt text sharing one attention weight
+type Segment struct {
+	Text   string
+	Weight float64
+	// Break marks a BREAK keyword; Text is empty and Weight is 1
+	Break bool
+}
+
+// Prompt is a parsed prompt
+type Prompt struct {
+	Segments []Segment
+}
+
+// SyntaxError describes a malformed prompt. Offset is the byte offset of the problem.
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("prompt: %s at offset %d", e.Msg, e.Offset)
+}
+
+// frame is an open ( or [ group
+type frame struct {
+	open   byte
+	offset int
+	// start is the index of the first segment inside the group
+	start int
+}
+
+// Parse parses s, returning a *SyntaxError for unbalanced groups or malformed weights
+func Parse(s string) (*Prompt, error) {
+	var (
+		segs  []Segment
+		stack []frame
+		text  strings.Builder
+	)
+	flush := func() {
+		if text.Len() > 0 {
+			segs = append(segs, Segment{Text: text.String(), Weight: 1})
+			text.Reset()
+		}
+	}
+	closeGroup := func(w float64) {
+		flush()
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for i := top.start; i < len(segs); i++ {
+			segs[i].Weight *= w
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\':
+			if i+1 < len(s) && strings.IndexByte(`()[]\`, s[i+1]) >= 0 {
+				i++
+				c = s[i]
+			}
+			text.WriteByte(c)
+		case '<':
+			end := tagEnd(s, i)
+			if end < 0 {
+				text.WriteByte(c)
+				continue
+			}
+			text.WriteString(s[i : end+1])
+			i = end
+		case '(', '[':
+			flush()
+			stack = append(stack, frame{open: c, offset: i, start: len(segs)})
+		case ')', ']':
+			open, w := byte('('), Emphasis
+			if c == ']' {
+				open, w = '[', 1/Emphasis
+			}
+			if len(stack) == 0 {
+				return nil, &SyntaxError{Offset: i, Msg: fmt.Sprintf("unmatched %q", c)}
+			}
+			if top := stack[len(stack)-1]; top.open != open {
+				return nil, &SyntaxError{Offset: i, Msg: fmt.Sprintf("%q closes %q opened at offset %d", c, top.open, top.offset)}
+			}
+			closeGroup(w)
+		case ':':
+			if len(stack) == 0 || stack[len(stack)-1].open != '(' {
+				text.WriteByte(c)
+				continue
+			}
+			w, end, err := parseWeight(s, i)
+			if err != nil {
+				return nil, err
+			}
+			if end < 0 {
+				// Not a weight, e.g. "(style: anime)"
+				text.WriteByte(c)
+				continue
+			}
+			closeGroup(w)
+			i = end
+		default:
+			text.WriteByte(c)
+		}
+	}
+	if len(stack) > 0 {
+		top := stack[len(stack)-1]
+		return nil, &SyntaxError{Offset: top.offset, Msg: fmt.Sprintf("unclosed %q", top.open)}
+	}
+	flush()
+
+	return &Prompt{Segments: normalize(splitBreaks(segs))}, nil
+}
+
+// tagEnd returns the index of the '>' closing a tag opened at i, or -1 when
+// the '<' is a literal character
+func tagEnd(s string, i int) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '>':
+			return j
+		case '<', '(', ')', '[', ']':
+			return -1
+		}
+	}
+	return -1
+}
+
+// parseWeight parses ":1.3)" at s[i]. It returns end = -1 when the colon does
+// not start a weight, and an error when it starts one that is malformed.
+func parseWeight(s string, i int) (float64, int, error) {
+	j := skipSpaces(s, i+1)
+	if j >= len(s) || !strings.ContainsRune("+-.0123456789", rune(s[j])) {
+		return 0, -1, nil
+	}
+	start := j
+	for j < len(s) && strings.ContainsRune("+-.0123456789", rune(s[j])) {
+		j++
+	}
+	num := s[start:j]
+	j = skipSpaces(s, j)
+	if j >= len(s) || s[j] != ')' {
+		return 0, -1, nil
+	}
+	w, err := strconv.ParseFloat(num, 64)
+	if err != nil || math.IsInf(w, 0) {
+		return 0, 0, &SyntaxError{Offset: start, Msg: fmt.Sprintf("invalid weight %q", num)}
+	}
+	return w, j, nil
+}
+
+func skipSpaces(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+var breakRe = regexp.MustCompile(`\bBREAK\b`)
+
+// splitBreaks turns BREAK keywords into Break segments
+func splitBreaks(segs []Segment) []Segment {
+	var out []Segment
+	for _, seg := range segs {
+		parts := breakRe.Split(seg.Text, -1)
+		for i, part := range parts {
+			if i > 0 {
+				out = append(out, Segment{Weight: 1, Break: true})
+			}
+			out = append(out, Segment{Text: part, Weight: seg.Weight})
+		}
+	}
+	return out
+}
+
+// normalize collapses whitespace, trims around breaks and merges runs of equal weight
+func normalize(segs []Segment) []Segment {
+	var out []Segment
+	for _, seg := range segs {
+		if seg.Break {
+			out = append(out, seg)
+			continue
+		}
+		text := collapseSpace(seg.Text)
+		if n := len(out); n > 0 && !out[n-1].Break {
+			prev := &out[n-1]
+			if strings.HasSuffix(prev.Text, " ") {
+				text = strings.TrimPrefix(text, " ")
+			}
+			if text == " " {
+				// Whitespace alone still separates its neighbours
+				prev.Text += text
+				continue
+			}
+			if text != "" && sameWeight(prev.Weight, seg.Weight) {
+				prev.Text += text
+				continue
+			}
+		}
+		if text != "" {
+			out = append(out, Segment{Text: text, Weight: seg.Weight})
+		}
+	}
+
+	for i := range out {
+		if out[i].Break {
+			continue
+		}
+		if i == 0 || out[i-1].Break {
+			out[i].Text = strings.TrimLeft(out[i].Text, " ")
+		}
+		if i == len(out)-1 || out[i+1].Break {
+			out[i].Text = strings.TrimRight(out[i].Text, " ")
+		}
+	}
+
+	clean := out[:0]
+	for _, seg := range out {
+		if !seg.Break && seg.Text == "" {
+			continue
+		}
+		if n := len(clean); n > 0 && !seg.Break && !clean[n-1].Break && sameWeight(clean[n-1].Weight, seg.Weight) {
+			clean[n-1].Text += seg.Text
+			continue
+		}
+		clean = append(clean, seg)
+	}
+	return clean
+}
+
+// collapseSpace replaces each run of whitespace with a single space
+func collapseSpace(s string) string {
+	text := strings.Join(strings.FieldsFunc(s, unicode.IsSpace), " ")
+	if s == "" {
+		return ""
+	}
+	if unicode.IsSpace(rune(s[0])) {
+		text = " " + text
+	}
+	if unicode.IsSpace(rune(s[len(s)-1])) && text != " " {
+		text += " "
+	}
+	return text
+}
+
+func sameWeight(a, b float64) bool {
+	return roundWeight(a) == roundWeight(b)
+}
+
+// roundWeight rounds to the precision used in the canonical form
+func roundWeight(w float64) float64 {
+	return math.Round(w*1e4) / 1e4
+}
+
+// FormatWeight formats w as it appears in the canonical form
+func FormatWeight(w float64) string {
+	return strconv.FormatFloat(roundWeight(w), 'f', -1, 64)
+}
+
+// Escape escapes the characters that have meaning in prompt syntax
+func Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(`()[]\`, s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// String returns the canonical form: every weighted run is written once as
+// (text:weight) with no nesting, which stable-diffusion.cpp parses directly
+func (p *Prompt) String() string {
+	var b strings.Builder
+	afterBreak := false
+	for _, seg := range p.Segments {
+		if seg.Break {
+			if b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString("BREAK")
+			afterBreak = true
+			continue
+		}
+		text := seg.Text
+		if afterBreak {
+			b.WriteByte(' ')
+			afterBreak = false
+		}
+		if roundWeight(seg.Weight) == 1 {
+			b.WriteString(Escape(text))
+			continue
+		}
+		trimmed := strings.TrimSpace(text)
+		if strings.HasPrefix(text, " ") {
+			b.WriteByte(' ')
+		}
+		b.WriteString("(" + Escape(trimmed) + ":" + FormatWeight(seg.Weight) + ")")
+		if strings.HasSuffix(text, " ") {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+// Text returns the prompt without weights or breaks
+func (p *Prompt) Text() string {
+	var parts []string
+	for _, seg := range p.Segments {
+		if !seg.Break {
+			parts = append(parts, seg.Text)
+		}
+	}
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// Normalize parses s and returns its canonical form
+func Normalize(s string) (string, error) {
+	p, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return p.String(), nil
+}
+
+// Validate reports whether s is a well-formed prompt
+func Validate(s string) error {
+	_, err := Parse(s)
+	return err
+}
@@ -0,0 +1,101 @@
+package prompt
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"a cat", "a cat"},
+		{"  a   cat\n\tsitting  ", "a cat sitting"},
+		{"a (cat) sitting", "a (cat:1.1) sitting"},
+		{"a ((cat))", "a (cat:1.21)"},
+		{"a [cat]", "a (cat:0.9091)"},
+		{"a (cat:1.3), dog", "a (cat:1.3), dog"},
+		{"a ( cat : 1.5 )", "a (cat:1.5)"},
+		{"((a (b:1.5)))", "(a:1.21) (b:1.815)"},
+		{"[(cat)]", "cat"},
+		{`a \(literal\) [b]`, `a \(literal\) (b:0.9091)`},
+		{`back\\slash`, `back\\slash`},
+		{"(style: anime)", "(style: anime:1.1)"},
+		{"time 10:30", "time 10:30"},
+		{"a cat BREAK a dog", "a cat BREAK a dog"},
+		{"a cat,BREAK  (dog:1.2)", "a cat, BREAK (dog:1.2)"},
+		{"BREAKFAST", "BREAKFAST"},
+		{"<lora:detail:0.8> a cat", "<lora:detail:0.8> a cat"},
+		{"(a <lora:x:1> b:1.2)", "(a <lora:x:1> b:1.2)"},
+		{"a < b", "a < b"},
+		{"(a:1) b", "a b"},
+		{"()", ""},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.in)
+		if err != nil {
+			t.Errorf("Normalize(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		// The canonical form is stable
+		again, err := Normalize(got)
+		if err != nil || again != got {
+			t.Errorf("Normalize(%q) = %q, %v; not idempotent", got, again, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		in     string
+		offset int
+	}{
+		{"a cat)", 5},
+		{"a (cat", 2},
+		{"a [cat", 2},
+		{"(a]", 2},
+		{"[a)", 2},
+		{"((a)", 0},
+		{"(cat:1.2.3)", 5},
+		{"(cat:--1)", 5},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.in)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("Validate(%q) = %v, want SyntaxError", tt.in, err)
+			continue
+		}
+		if se.Offset != tt.offset {
+			t.Errorf("Validate(%q) offset = %d, want %d (%v)", tt.in, se.Offset, tt.offset, err)
+		}
+	}
+}
+
+func TestSegments(t *testing.T) {
+	p, err := Parse("a (b:1.5) BREAK [c]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Segment{
+		{Text: "a ", Weight: 1},
+		{Text: "b", Weight: 1.5},
+		{Weight: 1, Break: true},
+		{Text: "c", Weight: 1 / Emphasis},
+	}
+	if len(p.Segments) != len(want) {
+		t.Fatalf("got %d segments %+v, want %d", len(p.Segments), p.Segments, len(want))
+	}
+	for i, seg := range p.Segments {
+		if seg.Text != want[i].Text || seg.Break != want[i].Break || math.Abs(seg.Weight-want[i].Weight) > 1e-9 {
+			t.Errorf("segment %d = %+v, want %+v", i, seg, want[i])
+		}
+	}
+	if p.Text() != "a b c" {
+		t.Errorf("Text() = %q", p.Text())
+	}
+}
@@ -0,0 +1,240 @@
+package prompt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Stage is a run of sampling steps [Start, End) that share one prompt
+type Stage struct {
+	Prompt string
+	Start  int
+	End    int
+}
+
+// snode is an element of a scheduled prompt
+type snode interface{}
+
+// edit is [from:to:when]; [to:when] adds to and [from::when] removes from
+type edit struct {
+	from, to []snode
+	when     float64
+}
+
+// alternate is [a|b|c], cycling one option per step
+type alternate struct {
+	options [][]snode
+}
+
+// emphasis is a plain [text] group, kept as written
+type emphasis struct {
+	inner []snode
+}
+
+// StepPrompts resolves prompt editing and alternation for each of steps
+// sampling steps. A when below 1 is a fraction of steps, otherwise a step
+// number; the new text applies from that step on. Other syntax, including
+// [text] de-emphasis, passes through unchanged.
+func StepPrompts(s string, steps int) ([]string, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("steps must be positive, got %d", steps)
+	}
+	nodes, err := parseSchedule(s, 0)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, steps)
+	for step := range out {
+		out[step] = strings.Join(strings.Fields(resolve(nodes, step, steps)), " ")
+	}
+	return out, nil
+}
+
+// Schedule groups the prompts from StepPrompts into stages of consecutive
+// steps with the same text
+func Schedule(s string, steps int) ([]Stage, error) {
+	prompts, err := StepPrompts(s, steps)
+	if err != nil {
+		return nil, err
+	}
+	var stages []Stage
+	for step, p := range prompts {
+		if n := len(stages); n > 0 && stages[n-1].Prompt == p {
+			stages[n-1].End = step + 1
+			continue
+		}
+		stages = append(stages, Stage{Prompt: p, Start: step, End: step + 1})
+	}
+	return stages, nil
+}
+
+// SwitchStep returns the first step that uses the "to" side of an edit
+func SwitchStep(when float64, steps int) int {
+	if when < 1 {
+		return int(when * float64(steps))
+	}
+	return int(when)
+}
+
+func resolve(nodes []snode, step, steps int) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case string:
+			b.WriteString(n)
+		case edit:
+			if step < SwitchStep(n.when, steps) {
+				b.WriteString(resolve(n.from, step, steps))
+			} else {
+				b.WriteString(resolve(n.to, step, steps))
+			}
+		case alternate:
+			b.WriteString(resolve(n.options[step%len(n.options)], step, steps))
+		case emphasis:
+			b.WriteString("[" + resolve(n.inner, step, steps) + "]")
+		}
+	}
+	return b.String()
+}
+
+func parseSchedule(s string, off int) ([]snode, error) {
+	var (
+		nodes []snode
+		text  strings.Builder
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			text.WriteByte(c)
+			if i+1 < len(s) {
+				i++
+				text.WriteByte(s[i])
+			}
+		case '<':
+			end := tagEnd(s, i)
+			if end < 0 {
+				text.WriteByte(c)
+				continue
+			}
+			text.WriteString(s[i : end+1])
+			i = end
+		case '[':
+			end := matchBracket(s, i)
+			if end < 0 {
+				return nil, &SyntaxError{Offset: off + i, Msg: "unclosed '['"}
+			}
+			n, err := parseBracket(s[i+1:end], off+i+1)
+			if err != nil {
+				return nil, err
+			}
+			if text.Len() > 0 {
+				nodes = append(nodes, text.String())
+				text.Reset()
+			}
+			nodes = append(nodes, n)
+			i = end
+		case ']':
+			return nil, &SyntaxError{Offset: off + i, Msg: "unmatched ']'"}
+		default:
+			text.WriteByte(c)
+		}
+	}
+	if text.Len() > 0 {
+		nodes = append(nodes, text.String())
+	}
+	return nodes, nil
+}
+
+// matchBracket returns the index of the ']' closing the '[' at s[i], or -1
+func matchBracket(s string, i int) int {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '<':
+			if end := tagEnd(s, j); end >= 0 {
+				j = end
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// topLevel returns the positions of sep outside nested (), [] and <> groups
+func topLevel(s string, sep byte) []int {
+	var cuts []int
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '<':
+			if end := tagEnd(s, i); end >= 0 {
+				i = end
+			}
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case sep:
+			if depth == 0 {
+				cuts = append(cuts, i)
+			}
+		}
+	}
+	return cuts
+}
+
+func parseBracket(body string, off int) (snode, error) {
+	if pipes := topLevel(body, '|'); len(pipes) > 0 {
+		var alt alternate
+		start := 0
+		for _, end := range append(pipes, len(body)) {
+			opt, err := parseSchedule(body[start:end], off+start)
+			if err != nil {
+				return nil, err
+			}
+			alt.options = append(alt.options, opt)
+			start = end + 1
+		}
+		return alt, nil
+	}
+
+	if colons := topLevel(body, ':'); len(colons) == 1 || len(colons) == 2 {
+		last := colons[len(colons)-1]
+		when, err := strconv.ParseFloat(strings.TrimSpace(body[last+1:]), 64)
+		if err == nil {
+			if when < 0 {
+				return nil, &SyntaxError{Offset: off + last + 1, Msg: fmt.Sprintf("negative edit step %v", when)}
+			}
+			var e edit
+			e.when = when
+			toStart := 0
+			if len(colons) == 2 {
+				if e.from, err = parseSchedule(body[:colons[0]], off); err != nil {
+					return nil, err
+				}
+				toStart = colons[0] + 1
+			}
+			if e.to, err = parseSchedule(body[toStart:last], off+toStart); err != nil {
+				return nil, err
+			}
+			return e, nil
+		}
+	}
+
+	inner, err := parseSchedule(body, off)
+	if err != nil {
+		return nil, err
+	}
+	return emphasis{inner: inner}, nil
+}
@@ -0,0 +1,49 @@
+package prompt
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSchedule(t *testing.T) {
+	tests := []struct {
+		in    string
+		steps int
+		want  []Stage
+	}{
+		{"a cat", 10, []Stage{{"a cat", 0, 10}}},
+		{"a [cat:dog:0.5]", 10, []Stage{{"a cat", 0, 5}, {"a dog", 5, 10}}},
+		{"a [cat:dog:3]", 10, []Stage{{"a cat", 0, 3}, {"a dog", 3, 10}}},
+		{"a [hat:0.2] cat", 10, []Stage{{"a cat", 0, 2}, {"a hat cat", 2, 10}}},
+		{"a [hat::0.8] cat", 10, []Stage{{"a hat cat", 0, 8}, {"a cat", 8, 10}}},
+		{"[cat|dog]", 4, []Stage{{"cat", 0, 1}, {"dog", 1, 2}, {"cat", 2, 3}, {"dog", 3, 4}}},
+		{"[[a|b]:c:2]", 4, []Stage{{"a", 0, 1}, {"b", 1, 2}, {"c", 2, 4}}},
+		{"[(red:1.2):blue:0.5] <lora:x:0.5> [soft]", 2, []Stage{{"(red:1.2) <lora:x:0.5> [soft]", 0, 1}, {"blue <lora:x:0.5> [soft]", 1, 2}}},
+		{`a \[cat:dog:0.5\]`, 2, []Stage{{`a \[cat:dog:0.5\]`, 0, 2}}},
+		{"[a:b:c]", 2, []Stage{{"[a:b:c]", 0, 2}}},
+	}
+	for _, tt := range tests {
+		got, err := Schedule(tt.in, tt.steps)
+		if err != nil {
+			t.Errorf("Schedule(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Schedule(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleErrors(t *testing.T) {
+	for in, offset := range map[string]int{"a [cat:dog:0.5": 2, "a ]": 2, "[a:-1]": 3} {
+		_, err := Schedule(in, 10)
+		var se *SyntaxError
+		if !errors.As(err, &se) || se.Offset != offset {
+			t.Errorf("Schedule(%q) = %v, want SyntaxError at %d", in, err, offset)
+		}
+	}
+	if _, err := StepPrompts("a", 0); err == nil {
+		t.Error("expected error for zero steps")
+	}
+}
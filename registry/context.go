@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"fmt"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// ContextParams fills the model paths, prediction and flow shift of the named
+// model into params. Call ContextParamsInit on params first.
+func (r *Registry) ContextParams(name string, params *stablediffusion.SDContextParams) error {
+	m, err := r.Get(name)
+	if err != nil {
+		return err
+	}
+	if m.Kind != "" && m.Kind != KindDiffusion {
+		return fmt.Errorf("model %q is a %s, not a diffusion model", name, m.Kind)
+	}
+
+	c, err := r.Resolve(name)
+	if err != nil {
+		return err
+	}
+	c.Apply(params)
+
+	if m.Prediction != nil {
+		params.Prediction = *m.Prediction
+	}
+	if m.FlowShift != 0 {
+		params.FlowShift = m.FlowShift
+	}
+	return nil
+}
+
+// SampleParams applies the model's default sampler, scheduler, steps and CFG to params.
+// Fields without a recorded default are left untouched.
+func (r *Registry) SampleParams(name string, params *stablediffusion.SDSampleParams) error {
+	m, err := r.Get(name)
+	if err != nil {
+		return err
+	}
+
+	d := m.Defaults
+	if d.SampleMethod != nil {
+		params.SampleMethod = *d.SampleMethod
+	}
+	if d.Scheduler != nil {
+		params.Scheduler = *d.Scheduler
+	}
+	if d.Steps > 0 {
+		params.SampleSteps = d.Steps
+	}
+	if d.CFG > 0 {
+		params.Guidance.TxtCfg = d.CFG
+	}
+	return nil
+}
+
+// NewContext creates an SD context for the named model using sd
+func (r *Registry) NewContext(sd *stablediffusion.StableDiffusion, name string) (*stablediffusion.SDContext, error) {
+	var params stablediffusion.SDContextParams
+	sd.ContextParamsInit(&params)
+	if err := r.ContextParams(name, &params); err != nil {
+		return nil, err
+	}
+	return sd.NewContext(&params)
+}
+
+// Convenience for package-level access, mirroring SetDefaultInstance in the bindings
+
+var defaultRegistry *Registry
+
+// SetDefault sets the registry used by NewContextFromRegistry
+func SetDefault(r *Registry) {
+	defaultRegistry = r
+}
+
+// NewContextFromRegistry creates a context for the named model using the default
+// registry and the default StableDiffusion instance
+func NewContextFromRegistry(name string) (*stablediffusion.SDContext, error) {
+	if defaultRegistry == nil {
+		return nil, fmt.Errorf("no default registry set")
+	}
+
+	var params stablediffusion.SDContextParams
+	stablediffusion.ContextParamsInit(&params)
+	if err := defaultRegistry.ContextParams(name, &params); err != nil {
+		return nil, err
+	}
+	return stablediffusion.NewContext(&params)
+}
@@ -0,0 +1,167 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hashCacheName is the cache file kept next to the manifest
+const hashCacheName = ".sdhashes.json"
+
+// AutoV2 returns the A1111 short hash for a SHA-256 hex digest
+func AutoV2(sha256Hex string) string {
+	if len(sha256Hex) < 10 {
+		return strings.ToUpper(sha256Hex)
+	}
+	return strings.ToUpper(sha256Hex[:10])
+}
+
+// FileSHA256 hashes the file at path
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer closeLogged(file)
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// closeLogged closes f and logs failures, matching how the bindings treat deferred closes
+func closeLogged(f *os.File) {
+	if err := f.Close(); err != nil {
+		log.Printf("failed to close file: %v", err)
+	}
+}
+
+type hashEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256"`
+}
+
+// hashCache remembers digests keyed by absolute path, invalidated by size and mtime
+type hashCache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]hashEntry
+}
+
+func loadHashCache(path string) *hashCache {
+	c := &hashCache{path: path, entries: make(map[string]hashEntry)}
+	if data, err := os.ReadFile(path); err == nil {
+		// A corrupt cache is simply rebuilt
+		_ = json.Unmarshal(data, &c.entries)
+	}
+	return c
+}
+
+func (c *hashCache) lookup(path string, info os.FileInfo) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[path]
+	if !ok || e.Size != info.Size() || !e.ModTime.Equal(info.ModTime()) {
+		return "", false
+	}
+	return e.SHA256, true
+}
+
+func (c *hashCache) store(path string, info os.FileInfo, sum string) error {
+	c.mu.Lock()
+	c.entries[path] = hashEntry{Size: info.Size(), ModTime: info.ModTime(), SHA256: sum}
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0644)
+}
+
+// Hash returns the SHA-256 of a file, using the cache when the file is unchanged.
+// Relative paths are resolved against the manifest directory.
+func (r *Registry) Hash(path string) (string, error) {
+	abs, err := filepath.Abs(r.Abs(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	if sum, ok := r.hashes.lookup(abs, info); ok {
+		return sum, nil
+	}
+
+	sum, err := FileSHA256(abs)
+	if err != nil {
+		return "", err
+	}
+	if err := r.hashes.store(abs, info, sum); err != nil {
+		return "", fmt.Errorf("failed to update hash cache: %w", err)
+	}
+	return sum, nil
+}
+
+// ShortHash returns the AutoV2 hash of a file
+func (r *Registry) ShortHash(path string) (string, error) {
+	sum, err := r.Hash(path)
+	if err != nil {
+		return "", err
+	}
+	return AutoV2(sum), nil
+}
+
+// Verify checks every file with a recorded SHA-256 for the named model
+func (r *Registry) Verify(name string) error {
+	m, err := r.Get(name)
+	if err != nil {
+		return err
+	}
+	for _, f := range m.Files {
+		if f.SHA256 == "" {
+			continue
+		}
+		sum, err := r.Hash(f.Path)
+		if err != nil {
+			return fmt.Errorf("model %q: %w", name, err)
+		}
+		if !strings.EqualFold(sum, f.SHA256) {
+			return fmt.Errorf("model %q: checksum mismatch for %s: got %s, want %s", name, f.Path, sum, f.SHA256)
+		}
+	}
+	return nil
+}
+
+// Record hashes the files of the named model and stores the digests and sizes in the manifest
+func (r *Registry) Record(name string) error {
+	m, err := r.Get(name)
+	if err != nil {
+		return err
+	}
+	for i := range m.Files {
+		sum, err := r.Hash(m.Files[i].Path)
+		if err != nil {
+			return fmt.Errorf("model %q: %w", name, err)
+		}
+		info, err := os.Stat(r.Abs(m.Files[i].Path))
+		if err != nil {
+			return fmt.Errorf("model %q: %w", name, err)
+		}
+		m.Files[i].SHA256 = sum
+		m.Files[i].Size = info.Size()
+	}
+	return nil
+}
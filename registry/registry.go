@@ -0,0 +1,222 @@
+// Package registry keeps a manifest of local models so contexts can be created by name.
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/kawai-network/stablediffusion"
+	"github.com/kawai-network/stablediffusion/modelinfo"
+)
+
+// Kind describes what a registry entry is used for
+type Kind string
+
+const (
+	KindDiffusion  Kind = "diffusion"
+	KindLoRA       Kind = "lora"
+	KindEmbedding  Kind = "embedding"
+	KindControlNet Kind = "controlnet"
+	KindUpscaler   Kind = "upscaler"
+	KindVAE        Kind = "vae"
+)
+
+// File records a model file with its checksum and optional download source
+type File struct {
+	// Path is relative to the manifest directory unless absolute
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// Defaults holds the recommended sampling settings for a model
+type Defaults struct {
+	SampleMethod *stablediffusion.SampleMethod `json:"sample_method,omitempty"`
+	Scheduler    *stablediffusion.Scheduler    `json:"scheduler,omitempty"`
+	Steps        int32                         `json:"steps,omitempty"`
+	CFG          float32                       `json:"cfg,omitempty"`
+}
+
+// Model is one manifest entry
+type Model struct {
+	Name         string              `json:"name"`
+	Kind         Kind                `json:"kind"`
+	Architecture modelinfo.ModelType `json:"architecture,omitempty"`
+	// Paths assigns files to context slots, relative to the manifest directory
+	Paths modelinfo.Components `json:"paths,omitempty"`
+	// Path is the file for single-file kinds such as LoRA, embedding or upscaler
+	Path       string                      `json:"path,omitempty"`
+	Files      []File                      `json:"files,omitempty"`
+	Defaults   Defaults                    `json:"defaults,omitempty"`
+	Prediction *stablediffusion.Prediction `json:"prediction,omitempty"`
+	FlowShift  float32                     `json:"flow_shift,omitempty"`
+}
+
+// Manifest is the on-disk registry format
+type Manifest struct {
+	Models []Model `json:"models"`
+}
+
+// Registry is a loaded manifest
+type Registry struct {
+	path     string
+	dir      string
+	manifest Manifest
+	hashes   *hashCache
+}
+
+// Load reads the manifest at path. A missing file yields an empty registry
+// that Save will create.
+func Load(path string) (*Registry, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	r := &Registry{path: absPath, dir: filepath.Dir(absPath)}
+	r.hashes = loadHashCache(filepath.Join(r.dir, hashCacheName))
+
+	data, err := os.ReadFile(absPath)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range r.manifest.Models {
+		if m.Name == "" {
+			return nil, fmt.Errorf("manifest entry without a name")
+		}
+		if seen[m.Name] {
+			return nil, fmt.Errorf("duplicate model name %q", m.Name)
+		}
+		seen[m.Name] = true
+	}
+	return r, nil
+}
+
+// Save writes the manifest back to disk
+func (r *Registry) Save() error {
+	data, err := json.MarshalIndent(r.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// Dir returns the directory relative paths are resolved against
+func (r *Registry) Dir() string {
+	return r.dir
+}
+
+// Names returns the model names in sorted order
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.manifest.Models))
+	for i, m := range r.manifest.Models {
+		names[i] = m.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the model with the given name
+func (r *Registry) Get(name string) (*Model, error) {
+	for i := range r.manifest.Models {
+		if r.manifest.Models[i].Name == name {
+			return &r.manifest.Models[i], nil
+		}
+	}
+	return nil, fmt.Errorf("model %q not found in registry", name)
+}
+
+// Put adds m or replaces the entry with the same name
+func (r *Registry) Put(m Model) error {
+	if m.Name == "" {
+		return fmt.Errorf("model name is required")
+	}
+	for i := range r.manifest.Models {
+		if r.manifest.Models[i].Name == m.Name {
+			r.manifest.Models[i] = m
+			return nil
+		}
+	}
+	r.manifest.Models = append(r.manifest.Models, m)
+	return nil
+}
+
+// Remove deletes the named model from the manifest
+func (r *Registry) Remove(name string) error {
+	for i := range r.manifest.Models {
+		if r.manifest.Models[i].Name == name {
+			r.manifest.Models = append(r.manifest.Models[:i], r.manifest.Models[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("model %q not found in registry", name)
+}
+
+// Abs resolves a manifest path against the manifest directory
+func (r *Registry) Abs(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(r.dir, path)
+}
+
+// Resolve returns the model's component paths made absolute, checking that each file exists
+func (r *Registry) Resolve(name string) (modelinfo.Components, error) {
+	m, err := r.Get(name)
+	if err != nil {
+		return modelinfo.Components{}, err
+	}
+
+	c := m.Paths
+	for _, p := range []*string{
+		&c.Model, &c.DiffusionModel, &c.HighNoiseDiffusionModel, &c.VAE, &c.TAESD,
+		&c.ClipL, &c.ClipG, &c.ClipVision, &c.T5XXL, &c.LLM, &c.ControlNet,
+	} {
+		if *p == "" {
+			continue
+		}
+		*p = r.Abs(*p)
+		if _, err := os.Stat(*p); err != nil {
+			return modelinfo.Components{}, fmt.Errorf("model %q: %w", name, err)
+		}
+	}
+	if c.Model == "" && c.DiffusionModel == "" {
+		return modelinfo.Components{}, fmt.Errorf("model %q has no model or diffusion_model path", name)
+	}
+	return c, nil
+}
+
+// ResolvePath returns the absolute path of a single-file model such as a LoRA
+func (r *Registry) ResolvePath(name string) (string, error) {
+	m, err := r.Get(name)
+	if err != nil {
+		return "", err
+	}
+	if m.Path == "" {
+		return "", fmt.Errorf("model %q has no path", name)
+	}
+	path := r.Abs(m.Path)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("model %q: %w", name, err)
+	}
+	return path, nil
+}
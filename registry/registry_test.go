@@ -0,0 +1,201 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kawai-network/stablediffusion"
+	"github.com/kawai-network/stablediffusion/modelinfo"
+)
+
+const testManifest = `{
+  "models": [
+    {
+      "name": "flux-schnell-q4",
+      "kind": "diffusion",
+      "architecture": "flux",
+      "paths": {
+        "diffusion_model": "flux1-schnell-q4_0.gguf",
+        "vae": "ae.safetensors",
+        "clip_l": "clip_l.safetensors",
+        "t5xxl": "t5xxl_q8_0.gguf"
+      },
+      "files": [
+        {"path": "flux1-schnell-q4_0.gguf", "sha256": "5d41402abc4b2a76b9719d911017c592a5ba1f5f7b3e1a3d4b9f0d9c0e9b9d9a"}
+      ],
+      "defaults": {"sample_method": "euler", "scheduler": "simple", "steps": 4, "cfg": 1},
+      "prediction": "flux_flow",
+      "flow_shift": 3
+    },
+    {"name": "detail-lora", "kind": "lora", "path": "loras/detail.safetensors"}
+  ]
+}`
+
+func setupRegistry(t *testing.T) (*Registry, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"flux1-schnell-q4_0.gguf", "ae.safetensors", "clip_l.safetensors", "t5xxl_q8_0.gguf"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := filepath.Join(dir, "models.json")
+	if err := os.WriteFile(manifest, []byte(testManifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Load(manifest)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return r, dir
+}
+
+func TestLoadAndResolve(t *testing.T) {
+	r, dir := setupRegistry(t)
+
+	if names := r.Names(); len(names) != 2 || names[0] != "detail-lora" {
+		t.Errorf("unexpected names %v", names)
+	}
+
+	c, err := r.Resolve("flux-schnell-q4")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	want := modelinfo.Components{
+		DiffusionModel: filepath.Join(dir, "flux1-schnell-q4_0.gguf"),
+		VAE:            filepath.Join(dir, "ae.safetensors"),
+		ClipL:          filepath.Join(dir, "clip_l.safetensors"),
+		T5XXL:          filepath.Join(dir, "t5xxl_q8_0.gguf"),
+	}
+	if c != want {
+		t.Errorf("Resolve returned %+v, want %+v", c, want)
+	}
+
+	if _, err := r.Get("missing"); err == nil {
+		t.Error("expected error for unknown model")
+	}
+	if _, err := r.ResolvePath("detail-lora"); err == nil {
+		t.Error("expected error for missing LoRA file")
+	}
+}
+
+func TestContextAndSampleParams(t *testing.T) {
+	r, dir := setupRegistry(t)
+
+	var params stablediffusion.SDContextParams
+	if err := r.ContextParams("flux-schnell-q4", &params); err != nil {
+		t.Fatalf("ContextParams failed: %v", err)
+	}
+	if stablediffusion.CGoString(params.DiffusionModelPath) != filepath.Join(dir, "flux1-schnell-q4_0.gguf") {
+		t.Error("diffusion model path not set")
+	}
+	if params.Prediction != stablediffusion.FluxFlowPred || params.FlowShift != 3 {
+		t.Errorf("prediction %v, flow shift %v", params.Prediction, params.FlowShift)
+	}
+
+	sample := stablediffusion.SDSampleParams{SampleMethod: stablediffusion.EulerASampleMethod}
+	if err := r.SampleParams("flux-schnell-q4", &sample); err != nil {
+		t.Fatalf("SampleParams failed: %v", err)
+	}
+	if sample.SampleMethod != stablediffusion.EulerSampleMethod || sample.Scheduler != stablediffusion.SimpleScheduler ||
+		sample.SampleSteps != 4 || sample.Guidance.TxtCfg != 1 {
+		t.Errorf("unexpected sample params %+v", sample)
+	}
+
+	if err := r.ContextParams("detail-lora", &params); err == nil {
+		t.Error("expected error when creating a context from a LoRA entry")
+	}
+}
+
+func TestResolveMissingFile(t *testing.T) {
+	r, dir := setupRegistry(t)
+	os.Remove(filepath.Join(dir, "ae.safetensors"))
+	if _, err := r.Resolve("flux-schnell-q4"); err == nil {
+		t.Error("expected error for missing component file")
+	}
+}
+
+func TestHashCacheAndVerify(t *testing.T) {
+	r, dir := setupRegistry(t)
+	path := filepath.Join(dir, "ae.safetensors")
+
+	sum, err := r.Hash("ae.safetensors")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	direct, _ := FileSHA256(path)
+	if sum != direct || len(sum) != 64 {
+		t.Errorf("unexpected digest %s", sum)
+	}
+	if short, _ := r.ShortHash(path); short != strings.ToUpper(sum[:10]) {
+		t.Errorf("unexpected short hash %s", short)
+	}
+	if _, err := os.Stat(filepath.Join(dir, hashCacheName)); err != nil {
+		t.Error("hash cache was not written")
+	}
+
+	// A fresh registry reads the cached digest instead of rehashing
+	r2, _ := Load(filepath.Join(dir, "models.json"))
+	info, _ := os.Stat(path)
+	if cached, ok := r2.hashes.lookup(path, info); !ok || cached != sum {
+		t.Error("cached digest not found after reload")
+	}
+
+	if err := r.Verify("flux-schnell-q4"); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected checksum mismatch, got %v", err)
+	}
+	if err := r.Record("flux-schnell-q4"); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if err := r.Verify("flux-schnell-q4"); err != nil {
+		t.Errorf("Verify after Record failed: %v", err)
+	}
+}
+
+func TestPutRemoveSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	r, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load of missing manifest failed: %v", err)
+	}
+
+	method := stablediffusion.DPMPP2MSampleMethod
+	if err := r.Put(Model{Name: "sdxl", Kind: KindDiffusion, Paths: modelinfo.Components{Model: "sdxl.safetensors"},
+		Defaults: Defaults{SampleMethod: &method, Steps: 30, CFG: 7}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Put(Model{Name: "other", Kind: KindVAE}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Remove("other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	m, err := reloaded.Get("sdxl")
+	if err != nil || *m.Defaults.SampleMethod != method || len(reloaded.Names()) != 1 {
+		t.Errorf("unexpected reloaded registry: %+v, %v", m, err)
+	}
+}
+
+func TestLoadRejectsDuplicates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	os.WriteFile(path, []byte(`{"models":[{"name":"a"},{"name":"a"}]}`), 0644)
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for duplicate names")
+	}
+}
+
+func TestAutoV2(t *testing.T) {
+	if AutoV2("6ce0161689b3853acaa03779ec93eafe75a02f4ced659bee03f50797806fa2fa") != "6CE0161689" {
+		t.Error("unexpected AutoV2 hash")
+	}
+}
@@ -0,0 +1,173 @@
+package stablediffusion
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// lanczosA is the Lanczos window size (Lanczos3)
+const lanczosA = 3
+
+// ResizeLanczos resizes img to width x height with a Lanczos3 filter
+func ResizeLanczos(img image.Image, width, height int) *image.RGBA {
+	src := toRGBA(img)
+	if width <= 0 || height <= 0 {
+		return image.NewRGBA(image.Rect(0, 0, max(width, 0), max(height, 0)))
+	}
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	if sw == width && sh == height {
+		dst := image.NewRGBA(src.Rect)
+		copy(dst.Pix, src.Pix)
+		return dst
+	}
+
+	// Resize horizontally into a float buffer, then vertically into the output
+	tmp := make([]float64, width*sh*4)
+	xWeights := lanczosWeights(sw, width)
+	for y := 0; y < sh; y++ {
+		row := src.Pix[y*src.Stride:]
+		for x := 0; x < width; x++ {
+			w := xWeights[x]
+			var acc [4]float64
+			for i, k := range w.coeffs {
+				p := (w.start + i) * 4
+				acc[0] += k * float64(row[p])
+				acc[1] += k * float64(row[p+1])
+				acc[2] += k * float64(row[p+2])
+				acc[3] += k * float64(row[p+3])
+			}
+			copy(tmp[(y*width+x)*4:], acc[:])
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	yWeights := lanczosWeights(sh, height)
+	for y := 0; y < height; y++ {
+		w := yWeights[y]
+		for x := 0; x < width; x++ {
+			var acc [4]float64
+			for i, k := range w.coeffs {
+				p := ((w.start+i)*width + x) * 4
+				acc[0] += k * tmp[p]
+				acc[1] += k * tmp[p+1]
+				acc[2] += k * tmp[p+2]
+				acc[3] += k * tmp[p+3]
+			}
+			o := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[o+c] = clampUint8(acc[c])
+			}
+		}
+	}
+	return dst
+}
+
+type filterWeights struct {
+	start  int
+	coeffs []float64
+}
+
+// ImageFit selects how FitImage maps an image onto a different aspect ratio
+type ImageFit int
+
+const (
+	// FitLetterbox scales to fit inside the target and pads with black
+	FitLetterbox ImageFit = iota
+	// FitStretch scales each axis independently
+	FitStretch
+	// FitCrop scales to cover the target and crops the overflow evenly
+	FitCrop
+)
+
+// FitImage resizes img to exactly width x height using fit
+func FitImage(img image.Image, width, height int, fit ImageFit) *image.RGBA {
+	b := img.Bounds()
+	if fit == FitStretch || b.Dx()*height == b.Dy()*width {
+		return ResizeLanczos(img, width, height)
+	}
+
+	sx, sy := float64(width)/float64(b.Dx()), float64(height)/float64(b.Dy())
+	scale := math.Min(sx, sy)
+	if fit == FitCrop {
+		scale = math.Max(sx, sy)
+	}
+	w := max(int(math.Round(float64(b.Dx())*scale)), 1)
+	h := max(int(math.Round(float64(b.Dy())*scale)), 1)
+	scaled := ResizeLanczos(img, w, h)
+
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(out, out.Bounds(), image.Black, image.Point{}, draw.Src)
+	offset := image.Pt((width-w)/2, (height-h)/2)
+	draw.Draw(out, scaled.Bounds().Add(offset), scaled, image.Point{}, draw.Src)
+	return out
+}
+
+// lanczosWeights precomputes normalized filter taps for each output pixel
+func lanczosWeights(srcSize, dstSize int) []filterWeights {
+	scale := float64(srcSize) / float64(dstSize)
+	support := float64(lanczosA)
+	if scale > 1 {
+		// Widen the filter when downscaling to avoid aliasing
+		support *= scale
+	}
+	filterScale := math.Max(scale, 1)
+
+	weights := make([]filterWeights, dstSize)
+	for i := range weights {
+		center := (float64(i)+0.5)*scale - 0.5
+		start := max(int(math.Ceil(center-support)), 0)
+		end := min(int(math.Floor(center+support)), srcSize-1)
+
+		coeffs := make([]float64, 0, end-start+1)
+		sum := 0.0
+		for j := start; j <= end; j++ {
+			k := lanczos((float64(j) - center) / filterScale)
+			coeffs = append(coeffs, k)
+			sum += k
+		}
+		if sum != 0 {
+			for j := range coeffs {
+				coeffs[j] /= sum
+			}
+		}
+		weights[i] = filterWeights{start: start, coeffs: coeffs}
+	}
+	return weights
+}
+
+func lanczos(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	if x <= -lanczosA || x >= lanczosA {
+		return 0
+	}
+	px := math.Pi * x
+	return lanczosA * math.Sin(px) * math.Sin(px/lanczosA) / (px * px)
+}
+
+// toRGBA returns img as a zero-origin *image.RGBA, copying only when needed
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			rgba.Set(x-bounds.Min.X, y-bounds.Min.Y, img.At(x, y))
+		}
+	}
+	return rgba
+}
+
+func clampUint8(v float64) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(math.Round(v))
+}
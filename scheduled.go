@@ -0,0 +1,149 @@
+package stablediffusion
+
+import (
+	"fmt"
+	"image"
+	"unsafe"
+
+	"github.com/kawai-network/stablediffusion/internal/karras"
+	"github.com/kawai-network/stablediffusion/prompt"
+)
+
+// GenerateScheduledPrompt generates an image from a prompt that uses
+// [from:to:when] prompt editing or [a|b] alternation, in the prompt or the
+// negative prompt.
+//
+// generate_image only accepts one prompt, so the sampling schedule is split
+// into segments where the prompt text is constant. The first segment runs
+// normally; each following one runs img2img with Strength 1 on the decoded
+// result of the previous one, using the matching slice of the sigma schedule
+// as CustomSigmas. params.SampleParams.CustomSigmas supplies the full schedule;
+// without it a Karras schedule for SD1/SD2/SDXL is used, so flow models such
+// as Flux, SD3, Wan or Qwen-Image must pass CustomSigmas.
+//
+// This is an approximation of in-sampler prompt switching: every segment
+// boundary decodes and re-encodes through the VAE and re-noises the latent
+// instead of continuing it, which softens fine detail and can shift colours.
+// Keep the number of segments low; alternation switches every step and is
+// best used with few steps. Prompts without scheduling run as a single pass.
+func (ctx *SDContext) GenerateScheduledPrompt(params *SDImgGenParams) (image.Image, error) {
+	if ctx == nil || ctx.ptr == nil {
+		return nil, fmt.Errorf("invalid context")
+	}
+	if params.BatchCount > 1 {
+		return nil, fmt.Errorf("scheduled prompts support BatchCount 1, got %d", params.BatchCount)
+	}
+
+	steps, err := scheduleSteps(params)
+	if err != nil {
+		return nil, err
+	}
+
+	positive, err := prompt.StepPrompts(CGoString(params.Prompt), steps)
+	if err != nil {
+		return nil, fmt.Errorf("prompt: %w", err)
+	}
+	negative, err := prompt.StepPrompts(CGoString(params.NegativePrompt), steps)
+	if err != nil {
+		return nil, fmt.Errorf("negative prompt: %w", err)
+	}
+	segments := promptSegments(positive, negative)
+
+	// A single segment runs with the original parameters and needs no sigmas
+	var sigmas []float32
+	if len(segments) > 1 {
+		if sigmas, err = scheduleSigmas(params, ctx.flow); err != nil {
+			return nil, err
+		}
+	}
+
+	var prev *image.RGBA
+	for i, seg := range segments {
+		p := *params
+		p.Prompt = CString(positive[seg.start])
+		p.NegativePrompt = CString(negative[seg.start])
+		p.BatchCount = 1
+		if len(segments) > 1 {
+			segSigmas := sigmas[seg.start : seg.end+1]
+			p.SampleParams.CustomSigmas = &segSigmas[0]
+			p.SampleParams.CustomSigmasCount = int32(len(segSigmas))
+			p.SampleParams.SampleSteps = int32(len(segSigmas) - 1)
+			if params.Seed >= 0 {
+				p.Seed = params.Seed + int64(i)
+			}
+			// The segment sigmas already encode the img2img start point
+			if i > 0 || params.InitImage.Data != nil {
+				p.Strength = 1
+			}
+			if i > 0 {
+				p.InitImage = ImageToSDImage(prev)
+			}
+		}
+
+		img, err := ctx.Generate(&p)
+		if err != nil {
+			return nil, fmt.Errorf("segment %d/%d (steps %d-%d): %w", i+1, len(segments), seg.start, seg.end, err)
+		}
+		prev = img
+	}
+	return prev, nil
+}
+
+// promptSegment is a run of steps [start, end) with the same prompts
+type promptSegment struct {
+	start, end int
+}
+
+func promptSegments(positive, negative []string) []promptSegment {
+	var segs []promptSegment
+	for step := range positive {
+		if n := len(segs); n > 0 && positive[step] == positive[step-1] && negative[step] == negative[step-1] {
+			segs[n-1].end = step + 1
+			continue
+		}
+		segs = append(segs, promptSegment{start: step, end: step + 1})
+	}
+	return segs
+}
+
+// scheduleSteps returns the number of steps the sampler will actually run,
+// after the img2img start point when params has an init image
+func scheduleSteps(params *SDImgGenParams) (int, error) {
+	sp := params.SampleParams
+	steps := int(sp.SampleSteps)
+	if sp.CustomSigmasCount > 0 {
+		if sp.CustomSigmas == nil || sp.CustomSigmasCount < 2 {
+			return 0, fmt.Errorf("CustomSigmas needs at least 2 values, got %d", sp.CustomSigmasCount)
+		}
+		steps = int(sp.CustomSigmasCount) - 1
+	} else if steps <= 0 {
+		return 0, fmt.Errorf("SampleSteps must be positive, got %d", sp.SampleSteps)
+	}
+
+	if params.InitImage.Data != nil {
+		steps = min(max(int(float32(steps)*params.Strength), 1), steps)
+	}
+	return steps, nil
+}
+
+// scheduleSigmas returns the sigmas the sampler will actually visit, trimmed to
+// the img2img start point when params has an init image. The Karras fallback
+// only fits eps and v models, so flow models must carry CustomSigmas.
+func scheduleSigmas(params *SDImgGenParams, flow bool) ([]float32, error) {
+	steps, err := scheduleSteps(params)
+	if err != nil {
+		return nil, err
+	}
+
+	sp := params.SampleParams
+	var sigmas []float32
+	switch {
+	case sp.CustomSigmasCount > 0:
+		sigmas = unsafe.Slice(sp.CustomSigmas, sp.CustomSigmasCount)
+	case flow:
+		return nil, fmt.Errorf("flow models need CustomSigmas to schedule prompts")
+	default:
+		sigmas = karras.Sigmas(int(sp.SampleSteps), karras.SigmaMin, karras.SigmaMax, karras.Rho)
+	}
+	return append([]float32(nil), sigmas[len(sigmas)-1-steps:]...), nil
+}
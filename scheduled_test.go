@@ -0,0 +1,199 @@
+package stablediffusion
+
+import (
+	"math"
+	"testing"
+	"unsafe"
+)
+
+func TestScheduleSigmasKarras(t *testing.T) {
+	s, err := scheduleSigmas(&SDImgGenParams{SampleParams: SDSampleParams{SampleSteps: 10}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 11 || s[10] != 0 {
+		t.Fatalf("expected 11 sigmas ending in 0, got %v", s)
+	}
+	if math.Abs(float64(s[0])-14.6146) > 1e-3 || math.Abs(float64(s[9])-0.0292) > 1e-4 {
+		t.Errorf("range = %v..%v", s[0], s[9])
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] >= s[i-1] {
+			t.Fatalf("sigmas not decreasing at %d: %v", i, s)
+		}
+	}
+}
+
+func TestGenerateScheduledPrompt(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx := newFakeContext(sd)
+
+	params := SDImgGenParams{
+		Prompt:         CString("a [cat:dog:0.5]"),
+		NegativePrompt: CString("blurry"),
+		Width:          64,
+		Height:         64,
+		Seed:           10,
+		SampleParams:   SDSampleParams{SampleSteps: 10},
+	}
+	img, err := ctx.GenerateScheduledPrompt(&params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 64 {
+		t.Errorf("unexpected size %v", img.Bounds())
+	}
+	if len(fake.imgCalls) != 2 {
+		t.Fatalf("expected 2 segments, got %d", len(fake.imgCalls))
+	}
+
+	first, second := fake.imgCalls[0], fake.imgCalls[1]
+	if CGoString(first.Prompt) != "a cat" || CGoString(second.Prompt) != "a dog" {
+		t.Errorf("prompts = %q, %q", CGoString(first.Prompt), CGoString(second.Prompt))
+	}
+	if CGoString(second.NegativePrompt) != "blurry" {
+		t.Errorf("negative prompt = %q", CGoString(second.NegativePrompt))
+	}
+	if first.SampleParams.CustomSigmasCount != 6 || second.SampleParams.CustomSigmasCount != 6 {
+		t.Errorf("sigma counts = %d, %d", first.SampleParams.CustomSigmasCount, second.SampleParams.CustomSigmasCount)
+	}
+	a := unsafe.Slice(first.SampleParams.CustomSigmas, 6)
+	b := unsafe.Slice(second.SampleParams.CustomSigmas, 6)
+	if a[5] != b[0] || b[5] != 0 {
+		t.Errorf("segments do not share the boundary sigma: %v %v", a, b)
+	}
+	if first.InitImage.Data != nil || second.InitImage.Data == nil || second.Strength != 1 {
+		t.Error("second segment should run img2img from the first result at strength 1")
+	}
+	if first.Seed != 10 || second.Seed != 11 {
+		t.Errorf("seeds = %d, %d", first.Seed, second.Seed)
+	}
+}
+
+func TestGenerateScheduledPromptSinglePass(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx := newFakeContext(sd)
+
+	params := SDImgGenParams{Prompt: CString("a  cat"), Width: 8, Height: 8, SampleParams: SDSampleParams{SampleSteps: 20}, Strength: 0.6}
+	if _, err := ctx.GenerateScheduledPrompt(&params); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.imgCalls) != 1 {
+		t.Fatalf("expected a single pass, got %d", len(fake.imgCalls))
+	}
+	call := fake.imgCalls[0]
+	if call.SampleParams.CustomSigmas != nil || call.SampleParams.SampleSteps != 20 || call.Strength != 0.6 {
+		t.Error("unscheduled prompt should run with the original parameters")
+	}
+}
+
+func TestGenerateScheduledPromptImg2Img(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx := newFakeContext(sd)
+
+	sigmas := []float32{8, 7, 6, 5, 4, 3, 2, 1, 0.5, 0.25, 0}
+	init := solidImage(8, 8, 10)
+	params := SDImgGenParams{
+		Prompt:       CString("[cat|dog]"),
+		InitImage:    init,
+		Strength:     0.3,
+		Width:        8,
+		Height:       8,
+		Seed:         -1,
+		SampleParams: SDSampleParams{CustomSigmas: &sigmas[0], CustomSigmasCount: int32(len(sigmas))},
+	}
+	if _, err := ctx.GenerateScheduledPrompt(&params); err != nil {
+		t.Fatal(err)
+	}
+	// Strength 0.3 of 10 steps leaves 3 steps, one segment each
+	if len(fake.imgCalls) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(fake.imgCalls))
+	}
+	first := fake.imgCalls[0]
+	if *first.SampleParams.CustomSigmas != 1 || first.InitImage.Data != init.Data || first.Strength != 1 {
+		t.Errorf("first segment should start from the init image at sigma 1, got %v", *first.SampleParams.CustomSigmas)
+	}
+	if CGoString(fake.imgCalls[1].Prompt) != "dog" || fake.imgCalls[2].Seed != -1 {
+		t.Error("alternation or random seed not kept")
+	}
+}
+
+func TestGenerateScheduledPromptErrors(t *testing.T) {
+	sd, _ := newFakeSD()
+	ctx := newFakeContext(sd)
+
+	for _, params := range []SDImgGenParams{
+		{Prompt: CString("a [cat"), SampleParams: SDSampleParams{SampleSteps: 4}},
+		{Prompt: CString("a cat")},
+		{Prompt: CString("a cat"), BatchCount: 2, SampleParams: SDSampleParams{SampleSteps: 4}},
+	} {
+		if _, err := ctx.GenerateScheduledPrompt(&params); err == nil {
+			t.Errorf("expected error for %q", CGoString(params.Prompt))
+		}
+	}
+}
+
+func TestGenerateScheduledPromptFlow(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx := newFakeContext(sd)
+	ctx.flow = true
+
+	params := SDImgGenParams{Prompt: CString("a [cat:dog:0.5]"), Width: 8, Height: 8, SampleParams: SDSampleParams{SampleSteps: 4}}
+	if _, err := ctx.GenerateScheduledPrompt(&params); err == nil {
+		t.Error("expected error for a flow model without CustomSigmas")
+	}
+	if len(fake.imgCalls) != 0 {
+		t.Error("nothing should be generated without a usable schedule")
+	}
+
+	sigmas := []float32{1, 0.75, 0.5, 0.25, 0}
+	params.SampleParams = SDSampleParams{CustomSigmas: &sigmas[0], CustomSigmasCount: int32(len(sigmas))}
+	if _, err := ctx.GenerateScheduledPrompt(&params); err != nil {
+		t.Fatalf("flow model with CustomSigmas failed: %v", err)
+	}
+
+	params.Prompt = CString("a cat")
+	params.SampleParams = SDSampleParams{SampleSteps: 4}
+	if _, err := ctx.GenerateScheduledPrompt(&params); err != nil {
+		t.Errorf("unscheduled prompt on a flow model failed: %v", err)
+	}
+}
+
+func TestGenerateScheduledPromptSDXLDiffusionModel(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx, err := sd.NewContext(&SDContextParams{
+		DiffusionModelPath: CString("sdxl-unet.gguf"),
+		ClipLPath:          CString("clip_l.safetensors"),
+		ClipGPath:          CString("clip_g.safetensors"),
+		VAEPath:            CString("sdxl_vae.safetensors"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := SDImgGenParams{Prompt: CString("a [cat:dog:0.5]"), Width: 8, Height: 8, SampleParams: SDSampleParams{SampleSteps: 4}}
+	if _, err := ctx.GenerateScheduledPrompt(&params); err != nil {
+		t.Fatalf("UNet-only SDXL should use the Karras fallback: %v", err)
+	}
+	if len(fake.imgCalls) != 2 {
+		t.Errorf("expected 2 segments, got %d", len(fake.imgCalls))
+	}
+}
+
+func TestIsFlowModel(t *testing.T) {
+	for _, tc := range []struct {
+		params SDContextParams
+		want   bool
+	}{
+		{SDContextParams{ModelPath: CString("sd15.safetensors")}, false},
+		{SDContextParams{ModelPath: CString("sdxl.safetensors"), FlowShift: float32(math.Inf(1))}, false},
+		{SDContextParams{DiffusionModelPath: CString("flux.gguf"), T5XXLPath: CString("t5.gguf")}, true},
+		{SDContextParams{DiffusionModelPath: CString("sdxl-unet.gguf"), ClipLPath: CString("clip_l.safetensors"), ClipGPath: CString("clip_g.safetensors")}, false},
+		{SDContextParams{ModelPath: CString("sd3.safetensors"), Prediction: FlowPred}, true},
+		{SDContextParams{ModelPath: CString("wan.gguf"), FlowShift: 3}, true},
+	} {
+		if got := isFlowModel(&tc.params); got != tc.want {
+			t.Errorf("isFlowModel(%s%s) = %v, want %v", CGoString(tc.params.ModelPath), CGoString(tc.params.DiffusionModelPath), got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package stablediffusion
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sdTypeNames mirrors the ggml type names used by sd_type_name
+var sdTypeNames = map[SDType]string{
+	SDTypeF32:     "f32",
+	SDTypeF16:     "f16",
+	SDTypeQ4_0:    "q4_0",
+	SDTypeQ4_1:    "q4_1",
+	SDTypeQ5_0:    "q5_0",
+	SDTypeQ5_1:    "q5_1",
+	SDTypeQ8_0:    "q8_0",
+	SDTypeQ8_1:    "q8_1",
+	SDTypeQ2_K:    "q2_K",
+	SDTypeQ3_K:    "q3_K",
+	SDTypeQ4_K:    "q4_K",
+	SDTypeQ5_K:    "q5_K",
+	SDTypeQ6_K:    "q6_K",
+	SDTypeQ8_K:    "q8_K",
+	SDTypeIQ2_XXS: "iq2_xxs",
+	SDTypeIQ2_XS:  "iq2_xs",
+	SDTypeIQ3_XXS: "iq3_xxs",
+	SDTypeIQ1_S:   "iq1_s",
+	SDTypeIQ4_NL:  "iq4_nl",
+	SDTypeIQ3_S:   "iq3_s",
+	SDTypeIQ2_S:   "iq2_s",
+	SDTypeIQ4_XS:  "iq4_xs",
+	SDTypeI8:      "i8",
+	SDTypeI16:     "i16",
+	SDTypeI32:     "i32",
+	SDTypeI64:     "i64",
+	SDTypeF64:     "f64",
+	SDTypeIQ1_M:   "iq1_m",
+	SDTypeBF16:    "bf16",
+	SDTypeTQ1_0:   "tq1_0",
+	SDTypeTQ2_0:   "tq2_0",
+	SDTypeMXFP4:   "mxfp4",
+}
+
+// String returns the ggml name of the type, e.g. "q4_K"
+func (t SDType) String() string {
+	if name, ok := sdTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("SDType(%d)", int32(t))
+}
+
+// Valid reports whether t is a type the library knows
+func (t SDType) Valid() bool {
+	_, ok := sdTypeNames[t]
+	return ok
+}
+
+// ParseSDType parses a ggml type name case-insensitively
+func ParseSDType(name string) (SDType, error) {
+	for t, n := range sdTypeNames {
+		if strings.EqualFold(n, name) {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown type %q", name)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (t SDType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (t *SDType) UnmarshalText(text []byte) error {
+	v, err := ParseSDType(string(text))
+	*t = v
+	return err
+}
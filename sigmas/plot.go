@@ -0,0 +1,153 @@
+package sigmas
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/kawai-network/stablediffusion/internal/glyph"
+)
+
+var (
+	plotBackground = color.RGBA{255, 255, 255, 255}
+	plotAxis       = color.RGBA{0, 0, 0, 255}
+	plotGrid       = color.RGBA{225, 225, 225, 255}
+	// plotColors are assigned to series in order
+	plotColors = []color.RGBA{
+		{31, 119, 180, 255},
+		{255, 127, 14, 255},
+		{44, 160, 44, 255},
+		{214, 39, 40, 255},
+		{148, 103, 189, 255},
+		{140, 86, 75, 255},
+	}
+)
+
+// Series is a labeled schedule in a plot
+type Series struct {
+	Label  string
+	Sigmas Schedule
+}
+
+// PlotOptions configures Plot
+type PlotOptions struct {
+	// Width and Height default to 640x400
+	Width  int
+	Height int
+	// Log plots log(1+sigma) so the low-noise tail stays visible
+	Log bool
+}
+
+// Plot draws sigma against step for each series with a legend
+func Plot(opts PlotOptions, series ...Series) *image.RGBA {
+	width, height := opts.Width, opts.Height
+	if width <= 0 || height <= 0 {
+		width, height = 640, 400
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(plotBackground), image.Point{}, draw.Src)
+
+	steps, top := 1, 0.0
+	for _, s := range series {
+		steps = max(steps, s.Sigmas.Steps())
+		for _, v := range s.Sigmas {
+			top = max(top, float64(v))
+		}
+	}
+	if top <= 0 {
+		top = 1
+	}
+	yValue := func(v float64) float64 {
+		if opts.Log {
+			return math.Log1p(v) / math.Log1p(top)
+		}
+		return v / top
+	}
+
+	topLabel := strconv.FormatFloat(top, 'g', 4, 64)
+	area := image.Rect(len(topLabel)*glyph.Width+8, 8, width-8, height-glyph.Height-8)
+	if area.Dx() <= 0 || area.Dy() <= 0 {
+		return img
+	}
+	toPoint := func(step int, v float64) image.Point {
+		x := area.Min.X + int(math.Round(float64(step)/float64(steps)*float64(area.Dx()-1)))
+		y := area.Max.Y - 1 - int(math.Round(yValue(v)*float64(area.Dy()-1)))
+		return image.Pt(x, y)
+	}
+
+	// Grid lines at every step when they are at least 8px apart, then the axes
+	if area.Dx()/steps >= 8 {
+		for i := 1; i <= steps; i++ {
+			x := toPoint(i, 0).X
+			drawLine(img, image.Pt(x, area.Min.Y), image.Pt(x, area.Max.Y-1), plotGrid)
+		}
+	}
+	drawLine(img, image.Pt(area.Min.X, area.Min.Y), image.Pt(area.Min.X, area.Max.Y-1), plotAxis)
+	drawLine(img, image.Pt(area.Min.X, area.Max.Y-1), image.Pt(area.Max.X-1, area.Max.Y-1), plotAxis)
+	glyph.Draw(img, image.Pt(4, area.Min.Y), topLabel, plotAxis)
+	glyph.Draw(img, image.Pt(area.Min.X-glyph.Width-4, area.Max.Y-glyph.Height), "0", plotAxis)
+	stepsLabel := strconv.Itoa(steps)
+	glyph.Draw(img, image.Pt(area.Max.X-len(stepsLabel)*glyph.Width, area.Max.Y+4), stepsLabel, plotAxis)
+
+	for i, s := range series {
+		c := plotColors[i%len(plotColors)]
+		for j, v := range s.Sigmas {
+			p := toPoint(j, float64(v))
+			if j > 0 {
+				drawLine(img, toPoint(j-1, float64(s.Sigmas[j-1])), p, c)
+			}
+			draw.Draw(img, image.Rect(p.X-1, p.Y-1, p.X+2, p.Y+2), image.NewUniform(c), image.Point{}, draw.Src)
+		}
+
+		// Legend in the top right corner
+		y := area.Min.Y + 4 + i*(glyph.Height+4)
+		x := area.Max.X - 4 - len(s.Label)*glyph.Width
+		draw.Draw(img, image.Rect(x-16, y+3, x-4, y+glyph.Height-3), image.NewUniform(c), image.Point{}, draw.Src)
+		glyph.Draw(img, image.Pt(x, y), s.Label, plotAxis)
+	}
+	return img
+}
+
+// SavePlot renders the plot and writes it as PNG
+func SavePlot(path string, opts PlotOptions, series ...Series) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close file: %v", err)
+		}
+	}()
+
+	if err := png.Encode(file, Plot(opts, series...)); err != nil {
+		return fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return nil
+}
+
+// drawLine draws a one pixel line from a to b
+func drawLine(img *image.RGBA, a, b image.Point, c color.RGBA) {
+	n := max(abs(b.X-a.X), abs(b.Y-a.Y))
+	for i := 0; i <= n; i++ {
+		t := 0.0
+		if n > 0 {
+			t = float64(i) / float64(n)
+		}
+		x := a.X + int(math.Round(t*float64(b.X-a.X)))
+		y := a.Y + int(math.Round(t*float64(b.Y-a.Y)))
+		if image.Pt(x, y).In(img.Bounds()) {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func abs(v int) int {
+	return max(v, -v)
+}
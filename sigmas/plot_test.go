@@ -0,0 +1,50 @@
+package sigmas
+
+import (
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlot(t *testing.T) {
+	img := Plot(PlotOptions{Width: 320, Height: 200},
+		Series{Label: "karras", Sigmas: must(Karras(10, DefaultSigmaMin, DefaultSigmaMax, 7))},
+		Series{Label: "exponential", Sigmas: must(Exponential(10, DefaultSigmaMin, DefaultSigmaMax))},
+	)
+	if img.Bounds().Dx() != 320 || img.Bounds().Dy() != 200 {
+		t.Fatalf("unexpected size %v", img.Bounds())
+	}
+
+	// Every series colour appears in the plot
+	for i := range 2 {
+		found := false
+		for y := 0; y < 200 && !found; y++ {
+			for x := 0; x < 320 && !found; x++ {
+				found = img.RGBAAt(x, y) == plotColors[i]
+			}
+		}
+		if !found {
+			t.Errorf("series %d was not drawn", i)
+		}
+	}
+
+	if empty := Plot(PlotOptions{}); empty.Bounds().Dx() != 640 {
+		t.Errorf("expected default size, got %v", empty.Bounds())
+	}
+}
+
+func TestSavePlot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sigmas.png")
+	if err := SavePlot(path, PlotOptions{Log: true}, Series{Label: "beta", Sigmas: must(Beta(20, 0.6, 0.6))}); err != nil {
+		t.Fatalf("SavePlot failed: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := png.Decode(f); err != nil {
+		t.Errorf("invalid PNG: %v", err)
+	}
+}
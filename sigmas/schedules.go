@@ -0,0 +1,273 @@
+package sigmas
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/kawai-network/stablediffusion/internal/karras"
+)
+
+// Karras returns the schedule of Karras et al. (2022) with the given rho, usually 7
+func Karras(steps int, sigmaMin, sigmaMax, rho float64) (Schedule, error) {
+	if err := checkRange(steps, sigmaMin, sigmaMax); err != nil {
+		return nil, err
+	}
+	if rho <= 0 {
+		return nil, fmt.Errorf("rho must be positive, got %v", rho)
+	}
+	return karras.Sigmas(steps, sigmaMin, sigmaMax, rho), nil
+}
+
+// Exponential returns sigmas evenly spaced in log space
+func Exponential(steps int, sigmaMin, sigmaMax float64) (Schedule, error) {
+	if err := checkRange(steps, sigmaMin, sigmaMax); err != nil {
+		return nil, err
+	}
+	s := make(Schedule, steps+1)
+	logMin, logMax := math.Log(sigmaMin), math.Log(sigmaMax)
+	for i := range steps {
+		s[i] = float32(math.Exp(logMax + ramp(i, steps)*(logMin-logMax)))
+	}
+	return s, nil
+}
+
+// PolyExponential returns sigmas spaced in log space by a polynomial ramp.
+// rho 1 matches Exponential; larger values spend more steps at low noise.
+func PolyExponential(steps int, sigmaMin, sigmaMax, rho float64) (Schedule, error) {
+	if err := checkRange(steps, sigmaMin, sigmaMax); err != nil {
+		return nil, err
+	}
+	if rho <= 0 {
+		return nil, fmt.Errorf("rho must be positive, got %v", rho)
+	}
+	s := make(Schedule, steps+1)
+	logMin, logMax := math.Log(sigmaMin), math.Log(sigmaMax)
+	for i := range steps {
+		r := math.Pow(1-ramp(i, steps), rho)
+		s[i] = float32(math.Exp(r*(logMax-logMin) + logMin))
+	}
+	return s, nil
+}
+
+// AYSModel selects an Align Your Steps table
+type AYSModel int
+
+const (
+	AYSSD1 AYSModel = iota
+	AYSSDXL
+	AYSSVD
+)
+
+// aysTables are the 10-step Align Your Steps noise levels published by NVIDIA
+var aysTables = map[AYSModel][]float64{
+	AYSSD1:  {14.615, 6.475, 3.861, 2.697, 1.886, 1.396, 0.963, 0.652, 0.399, 0.152, 0.029},
+	AYSSDXL: {14.615, 6.315, 3.771, 2.181, 1.342, 0.862, 0.555, 0.380, 0.234, 0.113, 0.029},
+	AYSSVD:  {700.00, 54.5, 15.886, 7.977, 4.248, 1.789, 0.981, 0.403, 0.173, 0.034, 0.002},
+}
+
+// AYS returns the Align Your Steps schedule for model, interpolated
+// log-linearly when steps is not 10
+func AYS(model AYSModel, steps int) (Schedule, error) {
+	table, ok := aysTables[model]
+	if !ok {
+		return nil, fmt.Errorf("unknown AYS model %d", model)
+	}
+	if steps <= 0 {
+		return nil, fmt.Errorf("steps must be positive, got %d", steps)
+	}
+
+	s := make(Schedule, steps+1)
+	last := float64(len(table) - 1)
+	for i := range s {
+		// Interpolate log sigma at the same relative position in the table
+		x := ramp(i, steps+1) * last
+		lo := int(x)
+		hi := min(lo+1, len(table)-1)
+		f := x - float64(lo)
+		s[i] = float32(math.Exp(math.Log(table[lo])*(1-f) + math.Log(table[hi])*f))
+	}
+	s[steps] = 0
+	return s, nil
+}
+
+// Beta returns the beta schedule of Lee et al. (2024): timesteps of the SD
+// discrete schedule placed at quantiles of a Beta(alpha, beta) distribution,
+// usually alpha = beta = 0.6. Repeated timesteps are dropped, so the
+// schedule can have fewer than steps steps.
+func Beta(steps int, alpha, beta float64) (Schedule, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("steps must be positive, got %d", steps)
+	}
+	if alpha <= 0 || beta <= 0 {
+		return nil, fmt.Errorf("alpha and beta must be positive, got %v and %v", alpha, beta)
+	}
+	table := discreteSigmas()
+	total := float64(len(table) - 1)
+
+	s := make(Schedule, 0, steps+1)
+	last := -1
+	for i := range steps {
+		q := 1 - float64(i)/float64(steps)
+		t := int(math.Round(betaPPF(q, alpha, beta) * total))
+		if t != last {
+			s = append(s, float32(table[t]))
+		}
+		last = t
+	}
+	return append(s, 0), nil
+}
+
+// LinearQuadratic returns the linear-quadratic schedule used by Mochi and
+// LTX-Video: linear for linearSteps steps down to thresholdNoise, then
+// quadratic. linearSteps defaults to steps/2 and thresholdNoise is usually 0.025.
+func LinearQuadratic(steps int, sigmaMax, thresholdNoise float64, linearSteps int) (Schedule, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("steps must be positive, got %d", steps)
+	}
+	if sigmaMax <= 0 {
+		return nil, fmt.Errorf("sigma max must be positive, got %v", sigmaMax)
+	}
+	if steps == 1 {
+		return Schedule{float32(sigmaMax), 0}, nil
+	}
+	if linearSteps <= 0 || linearSteps >= steps {
+		linearSteps = steps / 2
+	}
+
+	sched := make([]float64, 0, steps+1)
+	for i := range linearSteps {
+		sched = append(sched, float64(i)*thresholdNoise/float64(linearSteps))
+	}
+	stepDiff := float64(linearSteps) - thresholdNoise*float64(steps)
+	quadSteps := float64(steps - linearSteps)
+	quadCoef := stepDiff / (float64(linearSteps) * quadSteps * quadSteps)
+	linCoef := thresholdNoise/float64(linearSteps) - 2*stepDiff/(quadSteps*quadSteps)
+	constant := quadCoef * float64(linearSteps*linearSteps)
+	for i := linearSteps; i < steps; i++ {
+		fi := float64(i)
+		sched = append(sched, quadCoef*fi*fi+linCoef*fi+constant)
+	}
+	sched = append(sched, 1)
+
+	s := make(Schedule, len(sched))
+	for i, v := range sched {
+		s[i] = float32((1 - v) * sigmaMax)
+	}
+	return s, nil
+}
+
+// checkRange validates the steps and sigma range of the range-based schedules
+func checkRange(steps int, sigmaMin, sigmaMax float64) error {
+	if steps <= 0 {
+		return fmt.Errorf("steps must be positive, got %d", steps)
+	}
+	if sigmaMin <= 0 || sigmaMax < sigmaMin {
+		return fmt.Errorf("invalid sigma range %v..%v", sigmaMin, sigmaMax)
+	}
+	return nil
+}
+
+// ramp returns i/(n-1), or 0 when n is 1
+func ramp(i, n int) float64 {
+	if n <= 1 {
+		return 0
+	}
+	return float64(i) / float64(n-1)
+}
+
+// discreteSigmas returns the 1000 training sigmas of SD 1.x/SDXL eps models
+// (scaled linear betas from 0.00085 to 0.012), lowest first
+func discreteSigmas() []float64 {
+	const n = 1000
+	start, end := math.Sqrt(0.00085), math.Sqrt(0.012)
+	sigmas := make([]float64, n)
+	alphaCumprod := 1.0
+	for i := range sigmas {
+		b := start + (end-start)*float64(i)/(n-1)
+		alphaCumprod *= 1 - b*b
+		sigmas[i] = math.Sqrt((1 - alphaCumprod) / alphaCumprod)
+	}
+	return sigmas
+}
+
+// betaPPF inverts the regularized incomplete beta function by bisection
+func betaPPF(q, a, b float64) float64 {
+	if q <= 0 {
+		return 0
+	}
+	if q >= 1 {
+		return 1
+	}
+	lo, hi := 0.0, 1.0
+	for range 100 {
+		mid := (lo + hi) / 2
+		if betaCDF(mid, a, b) < q {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// betaCDF is the regularized incomplete beta function I_x(a, b)
+func betaCDF(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	// The continued fraction converges quickly on this side of the mean
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(x, a, b) / a
+	}
+	return 1 - front*betaCF(1-x, b, a)/b
+}
+
+// betaCF evaluates the continued fraction for the incomplete beta function (modified Lentz)
+func betaCF(x, a, b float64) float64 {
+	const tiny = 1e-300
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= 300; m++ {
+		fm := float64(m)
+		// Even step
+		num := fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+		// Odd step
+		num = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < 1e-14 {
+			break
+		}
+	}
+	return h
+}
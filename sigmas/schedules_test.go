@@ -0,0 +1,155 @@
+package sigmas
+
+import (
+	"math"
+	"testing"
+)
+
+func TestKarras(t *testing.T) {
+	s := must(Karras(10, DefaultSigmaMin, DefaultSigmaMax, 7))
+	if len(s) != 11 || s[10] != 0 {
+		t.Fatalf("expected 11 sigmas ending in 0, got %v", s)
+	}
+	if !near(s[0], DefaultSigmaMax) || !near(s[9], DefaultSigmaMin) {
+		t.Errorf("unexpected range %v..%v", s[0], s[9])
+	}
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExponential(t *testing.T) {
+	s := must(Exponential(2, 1, 100))
+	want := Schedule{100, 1, 0}
+	if len(s) != 3 || !near(s[0], 100) || !near(s[1], 1) || s[2] != 0 {
+		t.Errorf("got %v, want %v", s, want)
+	}
+	s = must(Exponential(3, 1, 100))
+	if !near(s[1], 10) {
+		t.Errorf("expected geometric midpoint 10, got %v", s[1])
+	}
+
+	// rho 1 is the exponential schedule
+	poly := must(PolyExponential(3, 1, 100, 1))
+	for i := range s {
+		if !near(poly[i], float64(s[i])) {
+			t.Errorf("PolyExponential rho 1 differs at %d: %v vs %v", i, poly[i], s[i])
+		}
+	}
+	if p := must(PolyExponential(3, 1, 100, 2)); p[1] >= s[1] {
+		t.Errorf("rho 2 should drop faster, got %v", p[1])
+	}
+}
+
+func TestAYS(t *testing.T) {
+	s, err := AYS(AYSSDXL, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 11 || !near(s[1], 6.315) || s[10] != 0 {
+		t.Errorf("unexpected 10-step table %v", s)
+	}
+
+	s, err = AYS(AYSSD1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 21 || !near(s[0], 14.615) || !near(s[2], 6.475) || s[20] != 0 {
+		t.Errorf("unexpected interpolated table %v", s)
+	}
+	// Odd points sit at the geometric mean of their neighbours in the table
+	if !near(s[1], math.Sqrt(14.615*6.475)) {
+		t.Errorf("expected log-linear interpolation, got %v", s[1])
+	}
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := AYS(AYSModel(9), 10); err == nil {
+		t.Error("expected error for unknown model")
+	}
+	if _, err := AYS(AYSSD1, 0); err == nil {
+		t.Error("expected error for zero steps")
+	}
+}
+
+func TestDiscreteSigmas(t *testing.T) {
+	table := discreteSigmas()
+	if !near(float32(table[0]), DefaultSigmaMin) || !near(float32(table[999]), DefaultSigmaMax) {
+		t.Errorf("unexpected discrete range %v..%v", table[0], table[999])
+	}
+}
+
+func TestBeta(t *testing.T) {
+	s := must(Beta(10, 0.6, 0.6))
+	if len(s) != 11 || !near(s[0], DefaultSigmaMax) || s[10] != 0 {
+		t.Fatalf("unexpected beta schedule %v", s)
+	}
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	// Beta(1, 1) is uniform in timesteps
+	if got := betaPPF(0.3, 1, 1); math.Abs(got-0.3) > 1e-9 {
+		t.Errorf("betaPPF(0.3, 1, 1) = %v", got)
+	}
+	// Symmetric distributions have their median at 0.5
+	if got := betaCDF(0.5, 0.6, 0.6); math.Abs(got-0.5) > 1e-9 {
+		t.Errorf("betaCDF(0.5, 0.6, 0.6) = %v", got)
+	}
+	// Beta(2, 1) has CDF x^2
+	if got := betaCDF(0.3, 2, 1); math.Abs(got-0.09) > 1e-9 {
+		t.Errorf("betaCDF(0.3, 2, 1) = %v", got)
+	}
+}
+
+func TestLinearQuadratic(t *testing.T) {
+	s := must(LinearQuadratic(10, 1, 0.025, 0))
+	if len(s) != 11 || s[0] != 1 || s[10] != 0 {
+		t.Fatalf("unexpected schedule %v", s)
+	}
+	// The linear part drops by threshold/linearSteps per step
+	if !near(s[1], 1-0.025/5) || !near(s[5], 1-0.025) {
+		t.Errorf("unexpected linear part %v", s[:6])
+	}
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+	}
+	if s := must(LinearQuadratic(1, 2, 0.025, 0)); len(s) != 2 || s[0] != 2 {
+		t.Errorf("unexpected single step schedule %v", s)
+	}
+}
+
+func TestSchedulesValidateSteps(t *testing.T) {
+	for name, build := range map[string]func(steps int) (Schedule, error){
+		"karras":           func(n int) (Schedule, error) { return Karras(n, DefaultSigmaMin, DefaultSigmaMax, 7) },
+		"exponential":      func(n int) (Schedule, error) { return Exponential(n, DefaultSigmaMin, DefaultSigmaMax) },
+		"poly exponential": func(n int) (Schedule, error) { return PolyExponential(n, DefaultSigmaMin, DefaultSigmaMax, 2) },
+		"beta":             func(n int) (Schedule, error) { return Beta(n, 0.6, 0.6) },
+		"linear quadratic": func(n int) (Schedule, error) { return LinearQuadratic(n, 1, 0.025, 0) },
+	} {
+		for _, steps := range []int{0, -1, -5} {
+			if _, err := build(steps); err == nil {
+				t.Errorf("%s: expected error for %d steps", name, steps)
+			}
+		}
+	}
+	if _, err := Exponential(4, 10, 1); err == nil {
+		t.Error("expected error for an inverted sigma range")
+	}
+	if _, err := Beta(4, 0, 0.6); err == nil {
+		t.Error("expected error for alpha 0")
+	}
+}
+
+// must unwraps a schedule built from constant, valid arguments
+func must(s Schedule, err error) Schedule {
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func near(v float32, want float64) bool {
+	return math.Abs(float64(v)-want) <= 1e-3*math.Max(1, math.Abs(want))
+}
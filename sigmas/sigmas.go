@@ -0,0 +1,117 @@
+// Package sigmas builds custom noise schedules for SDSampleParams.CustomSigmas.
+//
+// A Schedule lists the sigmas the sampler visits from the first step to the
+// last, ending in 0, so a schedule for n steps has n+1 values. Attach copies a
+// schedule into memory referenced by the params, bypassing the built-in
+// Scheduler.
+package sigmas
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"unsafe"
+
+	"github.com/kawai-network/stablediffusion"
+	"github.com/kawai-network/stablediffusion/internal/karras"
+)
+
+// Default sigma range of SD 1.x and SDXL eps models
+const (
+	DefaultSigmaMin = karras.SigmaMin
+	DefaultSigmaMax = karras.SigmaMax
+)
+
+// Schedule is a non-increasing list of sigmas, one more than the number of steps
+type Schedule []float32
+
+// Steps returns the number of sampling steps the schedule covers
+func (s Schedule) Steps() int {
+	return max(len(s)-1, 0)
+}
+
+// Validate checks that s has at least two finite, non-negative, non-increasing values
+func (s Schedule) Validate() error {
+	if len(s) < 2 {
+		return fmt.Errorf("schedule needs at least 2 sigmas, got %d", len(s))
+	}
+	for i, v := range s {
+		if v < 0 || math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return fmt.Errorf("invalid sigma %v at index %d", v, i)
+		}
+		if i > 0 && v > s[i-1] {
+			return fmt.Errorf("sigma increases from %v to %v at index %d", s[i-1], v, i)
+		}
+	}
+	return nil
+}
+
+// Split cuts s at step into a high-noise and a low-noise part that share the sigma at step
+func (s Schedule) Split(step int) (Schedule, Schedule, error) {
+	if step <= 0 || step >= len(s)-1 {
+		return nil, nil, fmt.Errorf("split step %d is outside 1..%d", step, len(s)-2)
+	}
+	return slices.Clone(s[:step+1]), slices.Clone(s[step:]), nil
+}
+
+// SplitSigma splits s at the first step whose sigma is at or below sigma,
+// e.g. a Wan2.2 MoE boundary expressed as a sigma
+func (s Schedule) SplitSigma(sigma float32) (Schedule, Schedule, error) {
+	for i, v := range s {
+		if v <= sigma {
+			return s.Split(i)
+		}
+	}
+	return nil, nil, fmt.Errorf("schedule never reaches sigma %v", sigma)
+}
+
+// Concat joins schedules, dropping the first sigma of a part when it repeats
+// the last sigma of the previous part
+func Concat(parts ...Schedule) Schedule {
+	var out Schedule
+	for _, p := range parts {
+		if len(out) > 0 && len(p) > 0 && out[len(out)-1] == p[0] {
+			p = p[1:]
+		}
+		out = append(out, p...)
+	}
+	return out
+}
+
+// Scale multiplies every sigma by f, e.g. to move a schedule to a model with a different sigma range
+func (s Schedule) Scale(f float32) Schedule {
+	out := make(Schedule, len(s))
+	for i, v := range s {
+		out[i] = v * f
+	}
+	return out
+}
+
+// Attach validates s and points params.CustomSigmas at a private copy of it.
+// SampleSteps is set to the number of steps. The copy is ordinary Go memory
+// that is only referenced through params, so keep params reachable until the
+// generate call that reads it returns, as the bindings do with runtime.KeepAlive.
+func Attach(params *stablediffusion.SDSampleParams, s Schedule) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	sigmas := slices.Clone(s)
+	params.CustomSigmas = &sigmas[0]
+	params.CustomSigmasCount = int32(len(sigmas))
+	params.SampleSteps = int32(sigmas.Steps())
+	return nil
+}
+
+// FromParams returns a copy of the custom sigmas attached to params, or nil when none are set
+func FromParams(params *stablediffusion.SDSampleParams) Schedule {
+	if params.CustomSigmas == nil || params.CustomSigmasCount <= 0 {
+		return nil
+	}
+	return slices.Clone(Schedule(unsafe.Slice(params.CustomSigmas, params.CustomSigmasCount)))
+}
+
+// Detach clears the custom sigmas so the built-in Scheduler is used again
+func Detach(params *stablediffusion.SDSampleParams) {
+	params.CustomSigmas = nil
+	params.CustomSigmasCount = 0
+}
@@ -0,0 +1,91 @@
+package sigmas
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"unsafe"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+func TestValidate(t *testing.T) {
+	for name, s := range map[string]Schedule{
+		"short":      {1},
+		"increasing": {1, 2, 0},
+		"negative":   {1, -1},
+		"nan":        {float32(math.NaN()), 0},
+		"infinite":   {float32(math.Inf(1)), 1, 0},
+	} {
+		if err := s.Validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if err := (Schedule{2, 2, 0}).Validate(); err != nil {
+		t.Errorf("flat schedule should be valid: %v", err)
+	}
+}
+
+func TestSplitConcat(t *testing.T) {
+	s := Schedule{8, 4, 2, 1, 0}
+	high, low, err := s.Split(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(high, Schedule{8, 4, 2}) || !slices.Equal(low, Schedule{2, 1, 0}) {
+		t.Errorf("unexpected split %v %v", high, low)
+	}
+	high[0] = 100
+	if s[0] != 8 {
+		t.Error("Split should copy")
+	}
+	if got := Concat(Schedule{8, 4, 2}, low); !slices.Equal(got, s) {
+		t.Errorf("Concat = %v, want %v", got, s)
+	}
+	if got := Concat(Schedule{8, 4}, Schedule{3, 0}); !slices.Equal(got, Schedule{8, 4, 3, 0}) {
+		t.Errorf("Concat without shared sigma = %v", got)
+	}
+
+	high, low, err = s.SplitSigma(1.5)
+	if err != nil || high.Steps() != 3 || low[0] != 1 {
+		t.Errorf("SplitSigma = %v %v %v", high, low, err)
+	}
+	if _, _, err := s.Split(0); err == nil {
+		t.Error("expected error for split at the first sigma")
+	}
+	if _, _, err := s.Split(4); err == nil {
+		t.Error("expected error for split at the last sigma")
+	}
+}
+
+func TestAttach(t *testing.T) {
+	var params stablediffusion.SDSampleParams
+	s := must(Karras(4, DefaultSigmaMin, DefaultSigmaMax, 7))
+	if err := Attach(&params, s); err != nil {
+		t.Fatal(err)
+	}
+	if params.CustomSigmasCount != 5 || params.SampleSteps != 4 {
+		t.Fatalf("unexpected count %d steps %d", params.CustomSigmasCount, params.SampleSteps)
+	}
+	attached := unsafe.Slice(params.CustomSigmas, params.CustomSigmasCount)
+	if &attached[0] == &s[0] {
+		t.Error("Attach should copy the schedule")
+	}
+	if got := FromParams(&params); !slices.Equal(got, s) {
+		t.Errorf("FromParams = %v, want %v", got, s)
+	}
+
+	Detach(&params)
+	if params.CustomSigmas != nil || FromParams(&params) != nil {
+		t.Error("Detach should clear the custom sigmas")
+	}
+	if err := Attach(&params, Schedule{0, 1}); err == nil {
+		t.Error("expected error for invalid schedule")
+	}
+}
+
+func TestScale(t *testing.T) {
+	if got := (Schedule{2, 1, 0}).Scale(0.5); !slices.Equal(got, Schedule{1, 0.5, 0}) {
+		t.Errorf("Scale = %v", got)
+	}
+}
@@ -2,10 +2,13 @@ package stablediffusion
 
 import (
 	"fmt"
+	"image"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"unsafe"
 
 	"github.com/ebitengine/purego"
@@ -320,6 +323,14 @@ type SDVidGenParams struct {
 type SDContext struct {
 	ptr unsafe.Pointer
 	sd  *StableDiffusion
+	// controlNetPath, controlNetOnCPU and photoMakerPath record how the context was created
+	controlNetPath  string
+	controlNetOnCPU bool
+	photoMakerPath  string
+	// flow is set when the model is known to be flow-matching, see isFlowModel
+	flow bool
+	// editMultiple is the default Edit size multiple, see editSizeMultiple
+	editMultiple int
 }
 
 type UpscalerContext struct {
@@ -378,6 +389,16 @@ type StableDiffusion struct {
 	preprocessCanny          func(image *SDImage, highThreshold float32, lowThreshold float32, weak float32, strong float32, inverse bool) bool
 	sdCommit                 func() *uint8
 	sdVersion                func() *uint8
+
+	// Optional symbols, nil when the library does not export them
+	free func(ptr unsafe.Pointer)
+
+	// Native log dispatch, see logs.go
+	logOnce    sync.Once
+	logMu      sync.Mutex
+	logHandler func(level SDLogLevel, text string)
+	logCapture *[]string
+	convertMu  sync.Mutex
 }
 
 // LibraryConfig configures library loading
@@ -473,9 +494,16 @@ func (sd *StableDiffusion) registerFunctions() error {
 	purego.RegisterLibFunc(&sd.upscale, sd.handle, "upscale")
 	purego.RegisterLibFunc(&sd.getUpscaleFactor, sd.handle, "get_upscale_factor")
 	purego.RegisterLibFunc(&sd.convert, sd.handle, "convert")
-	purego.RegisterLibFunc(&sd.preprocessCanny, sd.handle, "preprocess_canny")
 	purego.RegisterLibFunc(&sd.sdCommit, sd.handle, "sd_commit")
 	purego.RegisterLibFunc(&sd.sdVersion, sd.handle, "sd_version")
+
+	if addr, err := lookupSymbol(sd.handle, "free"); err == nil && addr != 0 {
+		purego.RegisterFunc(&sd.free, addr)
+	}
+	// Some builds ship without the preprocessing helpers; Canny falls back to Go
+	if addr, err := lookupSymbol(sd.handle, "preprocess_canny"); err == nil && addr != 0 {
+		purego.RegisterFunc(&sd.preprocessCanny, addr)
+	}
 	return nil
 }
 
@@ -573,7 +601,29 @@ func (sd *StableDiffusion) NewContext(params *SDContextParams) (*SDContext, erro
 	if ptr == nil {
 		return nil, fmt.Errorf("failed to create SD context")
 	}
-	return &SDContext{ptr: ptr, sd: sd}, nil
+	return &SDContext{
+		ptr:             ptr,
+		sd:              sd,
+		controlNetPath:  CGoString(params.ControlNetPath),
+		controlNetOnCPU: params.KeepControlNetOnCPU,
+		photoMakerPath:  CGoString(params.PhotoMakerPath),
+		flow:            isFlowModel(params),
+		editMultiple:    editSizeMultiple(params),
+	}, nil
+}
+
+// isFlowModel guesses from the context params whether the model is
+// flow-matching. Besides an explicit flow prediction, the T5 and LLM text
+// encoders are only used by flow families (Flux, SD3, Wan, Qwen-Image), as is
+// a finite FlowShift. DiffusionModelPath says nothing, since SD1 and SDXL
+// UNet-only files are loaded through it too.
+func isFlowModel(params *SDContextParams) bool {
+	switch params.Prediction {
+	case FlowPred, FluxFlowPred, Flux2FlowPred:
+		return true
+	}
+	return CGoString(params.T5XXLPath) != "" || CGoString(params.LLMPath) != "" ||
+		(params.FlowShift > 0 && !math.IsInf(float64(params.FlowShift), 1))
 }
 
 func (ctx *SDContext) Free() {
@@ -609,10 +659,32 @@ func (ctx *SDContext) GenerateImage(params *SDImgGenParams) *SDImage {
 	return ctx.sd.generateImage(ctx.ptr, params)
 }
 
+// Generate runs generate_image and copies the result into Go memory,
+// freeing the native image. Only BatchCount 1 is supported.
+func (ctx *SDContext) Generate(params *SDImgGenParams) (*image.RGBA, error) {
+	if ctx == nil || ctx.ptr == nil {
+		return nil, fmt.Errorf("SD context is not initialized")
+	}
+	if params.BatchCount > 1 {
+		return nil, fmt.Errorf("only BatchCount 1 is supported, got %d", params.BatchCount)
+	}
+	defer runtime.KeepAlive(params)
+
+	result := ctx.GenerateImage(params)
+	if result == nil {
+		return nil, fmt.Errorf("generate_image returned no image")
+	}
+	defer ctx.sd.freeImage(result)
+
+	return SDImageToImage(result)
+}
+
 func (sd *StableDiffusion) VidGenParamsInit(params *SDVidGenParams) {
 	sd.sdVidGenParamsInit(params)
 }
 
+// GenerateVideo returns frames that alias native memory.
+// The package-level GenerateVideo copies them into Go images.
 func (ctx *SDContext) GenerateVideo(params *SDVidGenParams) ([]SDImage, int) {
 	var numFrames int32
 	framesPtr := ctx.sd.generateVideo(ctx.ptr, params, &numFrames)
@@ -649,6 +721,24 @@ func (sd *StableDiffusion) GetDefaultScheduler(ctx *SDContext, sampleMethod Samp
 	return sd.sdGetDefaultScheduler(ctx.ptr, sampleMethod)
 }
 
+// DefaultSampleMethod returns the sample method the loaded model uses by default,
+// or SampleMethodCount when ctx is nil or freed
+func (ctx *SDContext) DefaultSampleMethod() SampleMethod {
+	if ctx == nil || ctx.ptr == nil {
+		return SampleMethodCount
+	}
+	return ctx.sd.GetDefaultSampleMethod(ctx)
+}
+
+// DefaultScheduler returns the scheduler the loaded model uses by default with
+// sampleMethod, or SchedulerCount when ctx is nil or freed
+func (ctx *SDContext) DefaultScheduler(sampleMethod SampleMethod) Scheduler {
+	if ctx == nil || ctx.ptr == nil {
+		return SchedulerCount
+	}
+	return ctx.sd.GetDefaultScheduler(ctx, sampleMethod)
+}
+
 // NewUpscalerContext creates a new upscaler context
 func (sd *StableDiffusion) NewUpscalerContext(esrganPath string, offloadParamsToCPU bool, direct bool, nThreads int32, tileSize int32) (*UpscalerContext, error) {
 	cPath := CString(esrganPath)
@@ -666,8 +756,12 @@ func (sd *StableDiffusion) CacheParamsInit(params *SDCacheParams) {
 	sd.sdCacheParamsInit(params)
 }
 
-// PreprocessCanny preprocesses image with Canny edge detection
+// PreprocessCanny preprocesses image with Canny edge detection.
+// The pixel buffer of image is overwritten in place; use Canny to keep the input.
 func (sd *StableDiffusion) PreprocessCanny(image SDImage, highThreshold, lowThreshold, weak, strong float32, inverse bool) bool {
+	if sd.preprocessCanny == nil {
+		return false
+	}
 	return sd.preprocessCanny(&image, highThreshold, lowThreshold, weak, strong, inverse)
 }
 
@@ -738,7 +832,7 @@ func PreprocessCanny(image SDImage, highThreshold, lowThreshold, weak, strong fl
 	if defaultSD == nil {
 		return false
 	}
-	return defaultSD.preprocessCanny(&image, highThreshold, lowThreshold, weak, strong, inverse)
+	return defaultSD.PreprocessCanny(image, highThreshold, lowThreshold, weak, strong, inverse)
 }
 
 // Convert converts model using default instance
@@ -746,19 +840,15 @@ func Convert(inputPath, vaePath, outputPath string, outputType SDType, tensorTyp
 	if defaultSD == nil {
 		return false, fmt.Errorf("no default StableDiffusion instance set")
 	}
-	cInputPath := CString(inputPath)
-	cVaePath := CString(vaePath)
-	cOutputPath := CString(outputPath)
-	cTensorTypeRules := CString(tensorTypeRules)
-
-	defer func() {
-		FreeCString(cInputPath)
-		FreeCString(cVaePath)
-		FreeCString(cOutputPath)
-		FreeCString(cTensorTypeRules)
-	}()
-
-	return defaultSD.convert(cInputPath, cVaePath, cOutputPath, outputType, cTensorTypeRules, convertName), nil
+	err := defaultSD.Convert(ConvertOptions{
+		InputPath:       inputPath,
+		VAEPath:         vaePath,
+		OutputPath:      outputPath,
+		OutputType:      outputType,
+		TensorTypeRules: tensorTypeRules,
+		ConvertName:     convertName,
+	})
+	return err == nil, err
 }
 
 // UpscalerContext methods
@@ -771,12 +861,92 @@ func (ctx *UpscalerContext) Free() {
 	}
 }
 
-// Upscale upscales an image
-func (ctx *UpscalerContext) Upscale(inputImage SDImage, upscaleFactor uint32) SDImage {
-	return *ctx.sd.upscale(ctx.ptr, &inputImage, upscaleFactor)
+// maxUpscalePasses bounds how many native passes Upscale chains together
+const maxUpscalePasses = 4
+
+// Upscale upscales img by factor. Factors above the model's native factor are
+// reached by chaining passes, and the result is resized to exactly factor times
+// the input size.
+func (ctx *UpscalerContext) Upscale(img image.Image, factor uint32) (image.Image, error) {
+	if ctx.ptr == nil {
+		return nil, fmt.Errorf("upscaler context is not initialized")
+	}
+	if img == nil || img.Bounds().Empty() {
+		return nil, fmt.Errorf("input image is empty")
+	}
+
+	native := ctx.GetUpscaleFactor()
+	if native <= 0 {
+		return nil, fmt.Errorf("upscaler reported invalid factor %d", native)
+	}
+	if factor == 0 {
+		return nil, fmt.Errorf("upscale factor must be at least 1")
+	}
+
+	passes := 0
+	for reach := 1; reach < int(factor); reach *= native {
+		passes++
+	}
+	if passes > maxUpscalePasses {
+		return nil, fmt.Errorf("upscale factor %d needs %d passes of the %dx model, limit is %d", factor, passes, native, maxUpscalePasses)
+	}
+
+	bounds := img.Bounds()
+	out := img
+	for i := 0; i < passes; i++ {
+		var err error
+		out, err = ctx.upscaleOnce(out, uint32(native))
+		if err != nil {
+			return nil, fmt.Errorf("upscale pass %d failed: %w", i+1, err)
+		}
+	}
+
+	return ResizeLanczos(out, bounds.Dx()*int(factor), bounds.Dy()*int(factor)), nil
+}
+
+// upscaleOnce runs a single native upscale pass and copies the result into Go memory
+func (ctx *UpscalerContext) upscaleOnce(img image.Image, factor uint32) (*image.RGBA, error) {
+	input := ImageToSDImage(img)
+	defer runtime.KeepAlive(&input)
+
+	result := ctx.sd.upscale(ctx.ptr, &input, factor)
+	if result == nil || result.Data == nil {
+		return nil, fmt.Errorf("upscale returned no image")
+	}
+	defer ctx.sd.freeImage(result)
+
+	return SDImageToImage(result)
+}
+
+// UpscaleFile upscales the image at in by the model's native factor and saves it as PNG at out
+func (ctx *UpscalerContext) UpscaleFile(in, out string) error {
+	img, err := decodeImageFile(in)
+	if err != nil {
+		return err
+	}
+
+	upscaled, err := ctx.Upscale(img, uint32(ctx.GetUpscaleFactor()))
+	if err != nil {
+		return err
+	}
+
+	sdImg := ImageToSDImage(upscaled)
+	return SaveImage(&sdImg, out)
 }
 
 // GetUpscaleFactor gets the upscale factor
 func (ctx *UpscalerContext) GetUpscaleFactor() int {
 	return int(ctx.sd.getUpscaleFactor(ctx.ptr))
 }
+
+// freeImage releases a native image returned by the library.
+// It is a no-op when the library does not expose free.
+func (sd *StableDiffusion) freeImage(img *SDImage) {
+	if sd.free == nil || img == nil {
+		return
+	}
+	if img.Data != nil {
+		sd.free(unsafe.Pointer(img.Data))
+	}
+	sd.free(unsafe.Pointer(img))
+}
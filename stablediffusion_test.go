@@ -64,3 +64,24 @@ func TestFindBestLibrary(t *testing.T) {
 		t.Errorf("Expected empty string for non-existent path, got %s", result)
 	}
 }
+
+func TestGenerateErrors(t *testing.T) {
+	sd, fake := newFakeSD()
+	params := SDImgGenParams{Width: 8, Height: 8, BatchCount: 2}
+
+	if _, err := newFakeContext(sd).Generate(&params); err == nil {
+		t.Error("expected error for BatchCount 2")
+	}
+	if len(fake.imgCalls) != 0 {
+		t.Error("batch generation should be rejected before calling generate_image")
+	}
+
+	params.BatchCount = 1
+	if _, err := (&SDContext{sd: sd}).Generate(&params); err == nil {
+		t.Error("expected error for freed context")
+	}
+	var ctx *SDContext
+	if _, err := ctx.Generate(&params); err == nil {
+		t.Error("expected error for nil context")
+	}
+}
@@ -15,3 +15,8 @@ func openLibrary(name string) (uintptr, error) {
 func closeLibrary(handle uintptr) error {
 	return purego.Dlclose(handle)
 }
+
+// lookupSymbol resolves an exported symbol - Unix platforms (macOS/Linux)
+func lookupSymbol(handle uintptr, name string) (uintptr, error) {
+	return purego.Dlsym(handle, name)
+}
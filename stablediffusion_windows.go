@@ -16,3 +16,8 @@ func openLibrary(name string) (uintptr, error) {
 func closeLibrary(handle uintptr) error {
 	return windows.FreeLibrary(windows.Handle(handle))
 }
+
+// lookupSymbol resolves an exported symbol - Windows platform
+func lookupSymbol(handle uintptr, name string) (uintptr, error) {
+	return windows.GetProcAddress(windows.Handle(handle), name)
+}
@@ -0,0 +1,157 @@
+package sweep
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// Axis is one dimension of a sweep. Apply sets the i-th value on a copy of the
+// base parameters.
+type Axis struct {
+	Name   string
+	Labels []string
+	Apply  func(params *stablediffusion.SDImgGenParams, i int) error
+}
+
+// Len returns the number of values on the axis
+func (a Axis) Len() int {
+	return len(a.Labels)
+}
+
+// label returns the header text for value i
+func (a Axis) label(i int) string {
+	if a.Name == "" {
+		return a.Labels[i]
+	}
+	return a.Name + ": " + a.Labels[i]
+}
+
+// SampleMethods sweeps the sampler
+func SampleMethods(methods ...stablediffusion.SampleMethod) Axis {
+	return Axis{
+		Name:   "Sampler",
+		Labels: labels(methods, stablediffusion.SampleMethod.String),
+		Apply: func(p *stablediffusion.SDImgGenParams, i int) error {
+			p.SampleParams.SampleMethod = methods[i]
+			return nil
+		},
+	}
+}
+
+// Schedulers sweeps the scheduler
+func Schedulers(schedulers ...stablediffusion.Scheduler) Axis {
+	return Axis{
+		Name:   "Scheduler",
+		Labels: labels(schedulers, stablediffusion.Scheduler.String),
+		Apply: func(p *stablediffusion.SDImgGenParams, i int) error {
+			p.SampleParams.Scheduler = schedulers[i]
+			return nil
+		},
+	}
+}
+
+// Steps sweeps SampleSteps
+func Steps(steps ...int32) Axis {
+	return Axis{
+		Name:   "Steps",
+		Labels: labels(steps, func(v int32) string { return strconv.Itoa(int(v)) }),
+		Apply: func(p *stablediffusion.SDImgGenParams, i int) error {
+			p.SampleParams.SampleSteps = steps[i]
+			return nil
+		},
+	}
+}
+
+// CFG sweeps the text CFG scale
+func CFG(values ...float32) Axis {
+	return Axis{
+		Name:   "CFG",
+		Labels: labels(values, formatFloat),
+		Apply: func(p *stablediffusion.SDImgGenParams, i int) error {
+			p.SampleParams.Guidance.TxtCfg = values[i]
+			return nil
+		},
+	}
+}
+
+// Seeds sweeps the seed
+func Seeds(seeds ...int64) Axis {
+	return Axis{
+		Name:   "Seed",
+		Labels: labels(seeds, func(v int64) string { return strconv.FormatInt(v, 10) }),
+		Apply: func(p *stablediffusion.SDImgGenParams, i int) error {
+			p.Seed = seeds[i]
+			return nil
+		},
+	}
+}
+
+// Strengths sweeps the img2img denoising strength
+func Strengths(values ...float32) Axis {
+	return Axis{
+		Name:   "Strength",
+		Labels: labels(values, formatFloat),
+		Apply: func(p *stablediffusion.SDImgGenParams, i int) error {
+			p.Strength = values[i]
+			return nil
+		},
+	}
+}
+
+// LoraMultiplier sweeps the multiplier of the LoRA at index in params.Loras.
+// The LoRA array is copied so the base parameters are never modified.
+func LoraMultiplier(index int, values ...float32) Axis {
+	return Axis{
+		Name:   fmt.Sprintf("LoRA %d", index),
+		Labels: labels(values, formatFloat),
+		Apply: func(p *stablediffusion.SDImgGenParams, i int) error {
+			if index < 0 || index >= int(p.LoraCount) {
+				return fmt.Errorf("lora index %d out of range, params have %d", index, p.LoraCount)
+			}
+			loras := append([]stablediffusion.SDLora(nil), unsafe.Slice(p.Loras, p.LoraCount)...)
+			loras[index].Multiplier = values[i]
+			p.Loras = &loras[0]
+			return nil
+		},
+	}
+}
+
+// PromptSR is A1111's prompt search/replace: the first cell uses the prompt
+// unchanged and each following cell replaces search with a replacement in the
+// prompt and negative prompt
+func PromptSR(search string, replacements ...string) Axis {
+	values := append([]string{search}, replacements...)
+	return Axis{
+		Name:   "Prompt S/R",
+		Labels: values,
+		Apply: func(p *stablediffusion.SDImgGenParams, i int) error {
+			text := stablediffusion.CGoString(p.Prompt)
+			negative := stablediffusion.CGoString(p.NegativePrompt)
+			if !strings.Contains(text, search) && !strings.Contains(negative, search) {
+				return fmt.Errorf("prompt S/R: %q not found in the prompt", search)
+			}
+			if i == 0 {
+				return nil
+			}
+			p.Prompt = stablediffusion.CString(strings.ReplaceAll(text, search, values[i]))
+			p.NegativePrompt = stablediffusion.CString(strings.ReplaceAll(negative, search, values[i]))
+			return nil
+		},
+	}
+}
+
+func labels[T any](values []T, format func(T) string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = format(v)
+	}
+	return out
+}
+
+func formatFloat(v float32) string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
@@ -0,0 +1,127 @@
+package sweep
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/kawai-network/stablediffusion/internal/glyph"
+)
+
+var (
+	sheetBackground = color.RGBA{255, 255, 255, 255}
+	sheetText       = color.RGBA{0, 0, 0, 255}
+)
+
+// sheetLayout holds the pixel sizes of a contact sheet
+type sheetLayout struct {
+	cellW, cellH int
+	scale, pad   int
+	gap          int
+	headerH      int
+	rowHeaderW   int
+	nx, ny, nz   int
+}
+
+func (r *Result) layout() sheetLayout {
+	l := sheetLayout{nx: max(r.X.Len(), 1), ny: max(r.Y.Len(), 1), nz: max(r.Z.Len(), 1)}
+	for _, c := range r.Cells {
+		l.cellW = max(l.cellW, c.Image.Bounds().Dx())
+		l.cellH = max(l.cellH, c.Image.Bounds().Dy())
+	}
+	// Scale the 7x13 font up for large images so the labels stay readable
+	l.scale = max(1, l.cellW/384)
+	l.pad = 4 * l.scale
+	l.gap = 2 * l.scale
+	l.headerH = glyph.Height*l.scale + 2*l.pad
+
+	if r.Y.Len() > 0 {
+		longest := 0
+		for i := range r.Y.Labels {
+			longest = max(longest, len(r.Y.label(i)))
+		}
+		l.rowHeaderW = min(longest*glyph.Width*l.scale+2*l.pad, l.cellW)
+	}
+	return l
+}
+
+// Sheet renders the cells as a grid with X labels above the columns, Y labels
+// left of the rows and one grid per Z value, each under its own title
+func (r *Result) Sheet() *image.RGBA {
+	l := r.layout()
+
+	colHeaderH := 0
+	if r.X.Len() > 0 {
+		colHeaderH = l.headerH
+	}
+	titleH := 0
+	if r.Z.Len() > 0 {
+		titleH = l.headerH
+	}
+	gridW := l.nx*l.cellW + (l.nx-1)*l.gap
+	blockH := titleH + colHeaderH + l.ny*l.cellH + (l.ny-1)*l.gap
+	width := l.rowHeaderW + gridW
+	height := l.nz*blockH + (l.nz-1)*4*l.gap
+
+	sheet := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(sheetBackground), image.Point{}, draw.Src)
+
+	for z := range l.nz {
+		top := z * (blockH + 4*l.gap)
+		if r.Z.Len() > 0 {
+			l.drawText(sheet, r.Z.label(z), image.Rect(0, top, width, top+titleH))
+		}
+		gridTop := top + titleH + colHeaderH
+
+		for x := range l.nx {
+			left := l.rowHeaderW + x*(l.cellW+l.gap)
+			if r.X.Len() > 0 {
+				l.drawText(sheet, r.X.label(x), image.Rect(left, top+titleH, left+l.cellW, gridTop))
+			}
+		}
+		for y := range l.ny {
+			cellTop := gridTop + y*(l.cellH+l.gap)
+			if r.Y.Len() > 0 {
+				l.drawText(sheet, r.Y.label(y), image.Rect(0, cellTop, l.rowHeaderW, cellTop+l.cellH))
+			}
+			for x := range l.nx {
+				img := r.At(x, y, z)
+				left := l.rowHeaderW + x*(l.cellW+l.gap)
+				dst := image.Rect(left, cellTop, left+img.Bounds().Dx(), cellTop+img.Bounds().Dy())
+				draw.Draw(sheet, dst, img, img.Bounds().Min, draw.Src)
+			}
+		}
+	}
+	return sheet
+}
+
+// drawText draws text centred in rect, truncating it with "..." when it does not fit
+func (l sheetLayout) drawText(dst *image.RGBA, text string, rect image.Rectangle) {
+	maxChars := (rect.Dx() - 2*l.pad) / (glyph.Width * l.scale)
+	if maxChars <= 0 {
+		return
+	}
+	if runes := []rune(text); len(runes) > maxChars {
+		if maxChars > 3 {
+			text = string(runes[:maxChars-3]) + "..."
+		} else {
+			text = string(runes[:maxChars])
+		}
+	}
+
+	n := len([]rune(text))
+	mask := glyph.Mask(text)
+
+	w, h := n*glyph.Width*l.scale, glyph.Height*l.scale
+	x0 := rect.Min.X + (rect.Dx()-w)/2
+	y0 := rect.Min.Y + (rect.Dy()-h)/2
+	for y := range glyph.Height {
+		for x := range n * glyph.Width {
+			if mask.AlphaAt(x, y).A < 128 {
+				continue
+			}
+			block := image.Rect(x0+x*l.scale, y0+y*l.scale, x0+(x+1)*l.scale, y0+(y+1)*l.scale)
+			draw.Draw(dst, block.Intersect(rect), image.NewUniform(sheetText), image.Point{}, draw.Src)
+		}
+	}
+}
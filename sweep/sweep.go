@@ -0,0 +1,109 @@
+// Package sweep runs X/Y/Z parameter grids and renders them as contact sheets.
+package sweep
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// Sweep describes up to three axes. Unused axes are left zero.
+type Sweep struct {
+	X, Y, Z Axis
+	// Progress is called after each image
+	Progress func(done, total int)
+}
+
+// Cell is one generated image and its position on the axes
+type Cell struct {
+	X, Y, Z int
+	Image   image.Image
+}
+
+// Result holds every cell of a finished sweep
+type Result struct {
+	X, Y, Z Axis
+	// Cells are ordered by Z, then Y, then X
+	Cells []Cell
+}
+
+// At returns the image at the given axis positions
+func (r *Result) At(x, y, z int) image.Image {
+	nx, ny := max(r.X.Len(), 1), max(r.Y.Len(), 1)
+	return r.Cells[(z*ny+y)*nx+x].Image
+}
+
+// Run generates every combination of the axes on ctx. Each image starts from a
+// copy of base with BatchCount 1.
+func (s *Sweep) Run(ctx *stablediffusion.SDContext, base *stablediffusion.SDImgGenParams) (*Result, error) {
+	for _, a := range []struct {
+		name string
+		axis Axis
+	}{{"x", s.X}, {"y", s.Y}, {"z", s.Z}} {
+		if a.axis.Len() > 0 && a.axis.Apply == nil {
+			return nil, fmt.Errorf("%s axis %q has no Apply function", a.name, a.axis.Name)
+		}
+	}
+	if s.X.Len() == 0 && (s.Y.Len() > 0 || s.Z.Len() > 0) {
+		return nil, fmt.Errorf("the x axis must be set before y or z")
+	}
+	if s.Y.Len() == 0 && s.Z.Len() > 0 {
+		return nil, fmt.Errorf("the y axis must be set before z")
+	}
+
+	nx, ny, nz := max(s.X.Len(), 1), max(s.Y.Len(), 1), max(s.Z.Len(), 1)
+	total := nx * ny * nz
+	res := &Result{X: s.X, Y: s.Y, Z: s.Z, Cells: make([]Cell, 0, total)}
+
+	for z := range nz {
+		for y := range ny {
+			for x := range nx {
+				params := *base
+				params.BatchCount = 1
+				for _, step := range []struct {
+					axis Axis
+					i    int
+				}{{s.X, x}, {s.Y, y}, {s.Z, z}} {
+					if step.axis.Len() == 0 {
+						continue
+					}
+					if err := step.axis.Apply(&params, step.i); err != nil {
+						return nil, err
+					}
+				}
+
+				img, err := ctx.Generate(&params)
+				if err != nil {
+					return nil, fmt.Errorf("cell x=%d y=%d z=%d: %w", x, y, z, err)
+				}
+				res.Cells = append(res.Cells, Cell{X: x, Y: y, Z: z, Image: img})
+				if s.Progress != nil {
+					s.Progress(len(res.Cells), total)
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// SaveSheet renders the contact sheet and writes it as PNG
+func (r *Result) SaveSheet(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close file: %v", err)
+		}
+	}()
+
+	if err := png.Encode(file, r.Sheet()); err != nil {
+		return fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package sweep
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+func TestAxes(t *testing.T) {
+	loras := []stablediffusion.SDLora{{Multiplier: 1}, {Multiplier: 1}}
+	base := stablediffusion.SDImgGenParams{
+		Prompt:         stablediffusion.CString("a red cat"),
+		NegativePrompt: stablediffusion.CString("red tint"),
+		Loras:          &loras[0],
+		LoraCount:      2,
+	}
+
+	p := base
+	axes := []struct {
+		axis Axis
+		i    int
+	}{
+		{SampleMethods(stablediffusion.EulerSampleMethod, stablediffusion.EulerASampleMethod), 1},
+		{Schedulers(stablediffusion.KarrasScheduler), 0},
+		{Steps(10, 20), 1},
+		{CFG(5, 7.5), 1},
+		{Seeds(1, 2), 1},
+		{Strengths(0.5), 0},
+		{LoraMultiplier(1, 0.25, 0.5), 1},
+		{PromptSR("red", "blue"), 1},
+	}
+	for _, a := range axes {
+		if err := a.axis.Apply(&p, a.i); err != nil {
+			t.Fatalf("%s: %v", a.axis.Name, err)
+		}
+	}
+
+	if p.SampleParams.SampleMethod != stablediffusion.EulerASampleMethod || p.SampleParams.Scheduler != stablediffusion.KarrasScheduler {
+		t.Error("sampler or scheduler not applied")
+	}
+	if p.SampleParams.SampleSteps != 20 || p.SampleParams.Guidance.TxtCfg != 7.5 || p.Seed != 2 || p.Strength != 0.5 {
+		t.Error("numeric axes not applied")
+	}
+	if got := unsafe.Slice(p.Loras, 2); got[1].Multiplier != 0.5 || loras[1].Multiplier != 1 {
+		t.Error("lora multiplier not applied to a copy")
+	}
+	if stablediffusion.CGoString(p.Prompt) != "a blue cat" || stablediffusion.CGoString(p.NegativePrompt) != "blue tint" {
+		t.Errorf("prompt S/R = %q / %q", stablediffusion.CGoString(p.Prompt), stablediffusion.CGoString(p.NegativePrompt))
+	}
+
+	if axes[3].axis.label(1) != "CFG: 7.5" {
+		t.Errorf("label = %q", axes[3].axis.label(1))
+	}
+	if err := PromptSR("green", "blue").Apply(&base, 0); err == nil {
+		t.Error("expected error when the search text is missing")
+	}
+	if err := LoraMultiplier(5, 1).Apply(&base, 0); err == nil {
+		t.Error("expected error for out-of-range lora index")
+	}
+}
+
+func solid(w, h int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestSheet(t *testing.T) {
+	res := &Result{X: Steps(10, 20, 30), Y: CFG(5, 7), Z: Seeds(1, 2)}
+	for z := range 2 {
+		for y := range 2 {
+			for x := range 3 {
+				res.Cells = append(res.Cells, Cell{X: x, Y: y, Z: z, Image: solid(64, 48, color.RGBA{uint8(x * 80), uint8(y * 80), uint8(z * 80), 255})})
+			}
+		}
+	}
+
+	sheet := res.Sheet()
+	l := res.layout()
+	wantW := l.rowHeaderW + 3*64 + 2*l.gap
+	wantH := 2*(2*l.headerH+2*48+l.gap) + 4*l.gap
+	if sheet.Bounds().Dx() != wantW || sheet.Bounds().Dy() != wantH {
+		t.Fatalf("sheet is %v, want %dx%d", sheet.Bounds().Size(), wantW, wantH)
+	}
+
+	// The cell at x=2, y=1 of the second grid sits below both headers
+	top := (2*l.headerH + 2*48 + l.gap) + 4*l.gap + 2*l.headerH + 48 + l.gap
+	left := l.rowHeaderW + 2*(64+l.gap)
+	if got := sheet.RGBAAt(left+10, top+10); got != (color.RGBA{160, 80, 80, 255}) {
+		t.Errorf("cell pixel = %v", got)
+	}
+
+	// Headers contain dark text pixels
+	dark := 0
+	for y := 0; y < l.headerH; y++ {
+		for x := 0; x < sheet.Bounds().Dx(); x++ {
+			if sheet.RGBAAt(x, y).R == 0 {
+				dark++
+			}
+		}
+	}
+	if dark == 0 {
+		t.Error("z title was not rendered")
+	}
+
+	if err := res.SaveSheet(filepath.Join(t.TempDir(), "sheet.png")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSheetSingleAxis(t *testing.T) {
+	res := &Result{X: Seeds(1, 2), Cells: []Cell{{X: 0, Image: solid(32, 32, color.Black)}, {X: 1, Image: solid(32, 32, color.White)}}}
+	sheet := res.Sheet()
+	l := res.layout()
+	if l.rowHeaderW != 0 || sheet.Bounds().Dy() != l.headerH+32 {
+		t.Errorf("unexpected single-axis sheet %v", sheet.Bounds())
+	}
+}
+
+func TestRunValidation(t *testing.T) {
+	var base stablediffusion.SDImgGenParams
+	for _, s := range []Sweep{
+		{Y: Seeds(1)},
+		{X: Seeds(1), Z: Seeds(2)},
+		{X: Axis{Name: "custom", Labels: []string{"a"}}},
+	} {
+		if _, err := s.Run(nil, &base); err == nil {
+			t.Errorf("expected validation error for %+v", s)
+		}
+	}
+}
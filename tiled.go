@@ -0,0 +1,157 @@
+package stablediffusion
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// TiledUpscaleOptions configures TiledUpscale
+type TiledUpscaleOptions struct {
+	// Scale is the output size multiplier. Defaults to 2.
+	Scale float32
+	// Upscaler upscales with an ESRGAN model before refinement instead of Lanczos when set
+	Upscaler *UpscalerContext
+	// TileSize is the tile edge in pixels, rounded down to a multiple of 8. Defaults to 512.
+	TileSize int32
+	// Overlap is the number of pixels shared by neighbouring tiles. Defaults to 64.
+	Overlap int32
+	// Strength is the per-tile denoising strength. Defaults to 0.35.
+	Strength float32
+	// Progress is called after each tile is refined
+	Progress func(done, total int)
+}
+
+// TiledUpscale upscales img and re-diffuses it tile by tile with img2img.
+// Each tile reuses the prompt, seed and sample params from params and is
+// blended into the canvas with a feathered edge over the overlap, so peak
+// memory is bounded by the tile size rather than the output size.
+func (ctx *SDContext) TiledUpscale(img image.Image, params *SDImgGenParams, opts TiledUpscaleOptions) (image.Image, error) {
+	if ctx == nil || ctx.ptr == nil {
+		return nil, fmt.Errorf("SD context is not initialized")
+	}
+	if params == nil {
+		return nil, fmt.Errorf("image generation params are nil")
+	}
+	if opts.Upscaler != nil && opts.Upscaler.ptr == nil {
+		return nil, fmt.Errorf("upscaler context is not initialized")
+	}
+
+	scale := opts.Scale
+	if scale <= 0 {
+		scale = 2
+	}
+	tileSize := int(opts.TileSize) / 8 * 8
+	if opts.TileSize == 0 {
+		tileSize = 512
+	}
+	overlap := int(opts.Overlap)
+	if opts.Overlap == 0 {
+		overlap = 64
+	}
+	if tileSize < 64 {
+		return nil, fmt.Errorf("tile size %d is too small", opts.TileSize)
+	}
+	if overlap < 0 || overlap >= tileSize/2 {
+		return nil, fmt.Errorf("overlap %d must be between 0 and half the tile size", overlap)
+	}
+	strength := opts.Strength
+	if strength <= 0 {
+		strength = 0.35
+	}
+
+	bounds := img.Bounds()
+	width := int(float32(bounds.Dx()) * scale)
+	height := int(float32(bounds.Dy()) * scale)
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid output size %dx%d", width, height)
+	}
+
+	upscaled, err := hiresUpscale(img, width, height, opts.Upscaler)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pad to a multiple of 8 so every tile has a size the model accepts
+	canvas := image.NewRGBA(image.Rect(0, 0, (width+7)/8*8, (height+7)/8*8))
+	draw.Draw(canvas, canvas.Rect, image.NewUniform(upscaled.At(width-1, height-1)), image.Point{}, draw.Src)
+	draw.Draw(canvas, image.Rect(0, 0, width, height), upscaled, upscaled.Bounds().Min, draw.Src)
+
+	tileW := min(tileSize, canvas.Rect.Dx())
+	tileH := min(tileSize, canvas.Rect.Dy())
+	xs := tileOrigins(canvas.Rect.Dx(), tileW, overlap)
+	ys := tileOrigins(canvas.Rect.Dy(), tileH, overlap)
+	total := len(xs) * len(ys)
+
+	done := 0
+	for _, y := range ys {
+		for _, x := range xs {
+			rect := image.Rect(x, y, x+tileW, y+tileH)
+			tile := image.NewRGBA(image.Rect(0, 0, tileW, tileH))
+			draw.Draw(tile, tile.Rect, canvas, rect.Min, draw.Src)
+
+			tileParams := *params
+			tileParams.BatchCount = 1
+			tileParams.Width = int32(tileW)
+			tileParams.Height = int32(tileH)
+			tileParams.InitImage = ImageToSDImage(tile)
+			tileParams.MaskImage = SDImage{}
+			tileParams.ControlImage = SDImage{}
+			tileParams.Strength = strength
+
+			refined, err := ctx.Generate(&tileParams)
+			if err != nil {
+				return nil, fmt.Errorf("tile at %d,%d failed: %w", x, y, err)
+			}
+			blendTile(canvas, refined, rect, x > 0, y > 0, overlap)
+
+			done++
+			if opts.Progress != nil {
+				opts.Progress(done, total)
+			}
+		}
+	}
+
+	return canvas.SubImage(image.Rect(0, 0, width, height)), nil
+}
+
+// tileOrigins returns tile start offsets covering size with the given overlap
+func tileOrigins(size, tile, overlap int) []int {
+	if tile >= size {
+		return []int{0}
+	}
+	stride := tile - overlap
+	var origins []int
+	for x := 0; ; x += stride {
+		if x+tile >= size {
+			origins = append(origins, size-tile)
+			break
+		}
+		origins = append(origins, x)
+	}
+	return origins
+}
+
+// blendTile composites tile over dst at rect, feathering the left and top
+// edges that overlap tiles already written
+func blendTile(dst *image.RGBA, tile image.Image, rect image.Rectangle, featherLeft, featherTop bool, overlap int) {
+	src := toRGBA(tile)
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			alpha := 1.0
+			if featherLeft && x < overlap {
+				alpha *= float64(x+1) / float64(overlap+1)
+			}
+			if featherTop && y < overlap {
+				alpha *= float64(y+1) / float64(overlap+1)
+			}
+
+			s := src.Pix[y*src.Stride+x*4:]
+			d := dst.Pix[(rect.Min.Y+y)*dst.Stride+(rect.Min.X+x)*4:]
+			for c := 0; c < 3; c++ {
+				d[c] = clampUint8(float64(d[c])*(1-alpha) + float64(s[c])*alpha)
+			}
+			d[3] = 255
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package stablediffusion
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestTileOrigins(t *testing.T) {
+	tests := []struct {
+		size, tile, overlap int
+		expected            []int
+	}{
+		{512, 512, 64, []int{0}},
+		{256, 512, 64, []int{0}},
+		{1024, 512, 64, []int{0, 448, 512}},
+		{960, 512, 64, []int{0, 448}},
+	}
+
+	for _, tt := range tests {
+		got := tileOrigins(tt.size, tt.tile, tt.overlap)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("tileOrigins(%d, %d, %d) = %v, want %v", tt.size, tt.tile, tt.overlap, got, tt.expected)
+		}
+	}
+}
+
+func TestTiledUpscale(t *testing.T) {
+	sd, fake := newFakeSD()
+	fake.fill = 90
+	ctx := newFakeContext(sd)
+
+	img := image.NewRGBA(image.Rect(0, 0, 100, 60))
+	params := SDImgGenParams{Seed: 3, Prompt: CString("castle")}
+
+	progress := 0
+	out, err := ctx.TiledUpscale(img, &params, TiledUpscaleOptions{
+		TileSize: 128,
+		Overlap:  16,
+		Progress: func(done, total int) { progress = done },
+	})
+	if err != nil {
+		t.Fatalf("TiledUpscale failed: %v", err)
+	}
+
+	if out.Bounds().Dx() != 200 || out.Bounds().Dy() != 120 {
+		t.Errorf("unexpected output size %v", out.Bounds())
+	}
+	// 200 wide -> origins 0, 72 ; 120 tall fits in one tile
+	if len(fake.imgCalls) != 2 || progress != 2 {
+		t.Fatalf("expected 2 tiles, got %d calls and progress %d", len(fake.imgCalls), progress)
+	}
+	for _, call := range fake.imgCalls {
+		if call.Width != 128 || call.Height != 120 {
+			t.Errorf("unexpected tile size %dx%d", call.Width, call.Height)
+		}
+		if call.Strength != 0.35 || call.Seed != 3 || call.InitImage.Data == nil {
+			t.Error("tile params not derived from base params")
+		}
+	}
+
+	r, _, _, _ := out.At(150, 60).RGBA()
+	if r>>8 != 90 {
+		t.Errorf("expected refined pixel value 90, got %d", r>>8)
+	}
+}
+
+func TestTiledUpscaleInvalidOptions(t *testing.T) {
+	sd, _ := newFakeSD()
+	ctx := newFakeContext(sd)
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+
+	if _, err := ctx.TiledUpscale(img, &SDImgGenParams{}, TiledUpscaleOptions{TileSize: 32}); err == nil {
+		t.Error("expected error for tiny tiles")
+	}
+	if _, err := ctx.TiledUpscale(img, &SDImgGenParams{}, TiledUpscaleOptions{TileSize: 128, Overlap: 64}); err == nil {
+		t.Error("expected error for overlap >= half the tile")
+	}
+	var nilCtx *SDContext
+	if _, err := nilCtx.TiledUpscale(img, &SDImgGenParams{}, TiledUpscaleOptions{}); err == nil {
+		t.Error("expected error for nil context")
+	}
+}
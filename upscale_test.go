@@ -0,0 +1,102 @@
+package stablediffusion
+
+import (
+	"bytes"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpscale(t *testing.T) {
+	sd, fake := newFakeSD()
+	up := newFakeUpscaler(sd)
+	img := image.NewRGBA(image.Rect(0, 0, 10, 6))
+
+	tests := []struct {
+		factor uint32
+		passes int
+	}{
+		{1, 0},
+		{2, 1},
+		{4, 1},
+		{8, 2},
+		{16, 2},
+	}
+
+	for _, tt := range tests {
+		fake.upscaleCalls = 0
+		out, err := up.Upscale(img, tt.factor)
+		if err != nil {
+			t.Fatalf("Upscale(%d) failed: %v", tt.factor, err)
+		}
+		if out.Bounds().Dx() != 10*int(tt.factor) || out.Bounds().Dy() != 6*int(tt.factor) {
+			t.Errorf("Upscale(%d) size = %v", tt.factor, out.Bounds())
+		}
+		if fake.upscaleCalls != tt.passes {
+			t.Errorf("Upscale(%d) ran %d passes, want %d", tt.factor, fake.upscaleCalls, tt.passes)
+		}
+	}
+}
+
+func TestUpscaleErrors(t *testing.T) {
+	sd, fake := newFakeSD()
+	up := newFakeUpscaler(sd)
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+
+	if _, err := up.Upscale(img, 0); err == nil {
+		t.Error("expected error for zero factor")
+	}
+	if _, err := up.Upscale(img, 4*4*4*4*4); err == nil {
+		t.Error("expected error for factor beyond pass limit")
+	}
+
+	fake.upscaleFail = true
+	if _, err := up.Upscale(img, 4); err == nil {
+		t.Error("expected error when native upscale returns nil")
+	}
+
+	fake.upscaleFactor = 0
+	if _, err := up.Upscale(img, 2); err == nil {
+		t.Error("expected error for invalid native factor")
+	}
+
+	if _, err := (&UpscalerContext{sd: sd}).Upscale(img, 2); err == nil {
+		t.Error("expected error for freed context")
+	}
+}
+
+func TestUpscaleFile(t *testing.T) {
+	sd, _ := newFakeSD()
+	up := newFakeUpscaler(sd)
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.png")
+	out := filepath.Join(dir, "out.png")
+	src := solidImage(5, 3, 10)
+	if err := SaveImage(&src, in); err != nil {
+		t.Fatalf("SaveImage failed: %v", err)
+	}
+
+	if err := up.UpscaleFile(in, out); err != nil {
+		t.Fatalf("UpscaleFile failed: %v", err)
+	}
+	loaded, err := LoadImage(out)
+	if err != nil {
+		t.Fatalf("LoadImage failed: %v", err)
+	}
+	if loaded.Width != 20 || loaded.Height != 12 {
+		t.Errorf("unexpected output size %dx%d", loaded.Width, loaded.Height)
+	}
+
+	before, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := up.UpscaleFile(filepath.Join(dir, "missing.png"), out); err == nil {
+		t.Error("expected error for missing input")
+	}
+	if after, err := os.ReadFile(out); err != nil || !bytes.Equal(after, before) {
+		t.Errorf("existing output should be untouched: %v", err)
+	}
+}
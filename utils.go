@@ -6,6 +6,7 @@ import (
 	"image/color"
 	"image/png"
 	"log"
+	"math/rand/v2"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -15,26 +16,9 @@ import (
 
 // SaveImage saves SDImage as PNG file
 func SaveImage(img *SDImage, path string) error {
-	if img == nil || img.Data == nil {
-		return fmt.Errorf("invalid image data")
-	}
-
-	bounds := image.Rect(0, 0, int(img.Width), int(img.Height))
-	rgba := image.NewRGBA(bounds)
-
-	data := unsafe.Slice(img.Data, img.Width*img.Height*img.Channel)
-	for i := 0; i < int(img.Width*img.Height); i++ {
-		index := i * int(img.Channel)
-		x := i % int(img.Width)
-		y := i / int(img.Width)
-
-		var r, g, b, a uint8
-		r = data[index]
-		g = data[index+1]
-		b = data[index+2]
-		a = 255
-
-		rgba.Set(x, y, color.RGBA{r, g, b, a})
+	rgba, err := SDImageToImage(img)
+	if err != nil {
+		return err
 	}
 
 	file, err := os.Create(path)
@@ -52,9 +36,19 @@ func SaveImage(img *SDImage, path string) error {
 
 // LoadImage loads image from file and converts to SDImage format
 func LoadImage(path string) (SDImage, error) {
+	img, err := decodeImageFile(path)
+	if err != nil {
+		return SDImage{}, err
+	}
+
+	return ImageToSDImage(img), nil
+}
+
+// decodeImageFile opens and decodes an image file
+func decodeImageFile(path string) (image.Image, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return SDImage{}, fmt.Errorf("failed to open image file: %v", err)
+		return nil, fmt.Errorf("failed to open image file: %v", err)
 	}
 	defer func() {
 		if err := file.Close(); err != nil {
@@ -64,15 +58,24 @@ func LoadImage(path string) (SDImage, error) {
 
 	img, _, err := image.Decode(file)
 	if err != nil {
-		return SDImage{}, fmt.Errorf("failed to decode image: %v", err)
+		return nil, fmt.Errorf("failed to decode image: %v", err)
 	}
 
+	return img, nil
+}
+
+// ImageToSDImage converts a Go image to a 3-channel RGB SDImage.
+// The pixel buffer is owned by Go and stays valid while the SDImage is reachable.
+func ImageToSDImage(img image.Image) SDImage {
 	bounds := img.Bounds()
 	width := bounds.Dx()
 	height := bounds.Dy()
 
 	channel := 3
 	dataSize := width * height * channel
+	if dataSize == 0 {
+		return SDImage{Width: uint32(width), Height: uint32(height), Channel: uint32(channel)}
+	}
 
 	data := make([]uint8, dataSize)
 
@@ -95,7 +98,42 @@ func LoadImage(path string) (SDImage, error) {
 		Height:  uint32(height),
 		Channel: uint32(channel),
 		Data:    &data[0],
-	}, nil
+	}
+}
+
+// SDImageToImage copies an SDImage into a Go image.
+// Single-channel images are treated as grayscale. 4-channel images hold straight
+// alpha and are premultiplied to fit *image.RGBA.
+func SDImageToImage(img *SDImage) (*image.RGBA, error) {
+	if img == nil || img.Data == nil {
+		return nil, fmt.Errorf("invalid image data")
+	}
+
+	channel := int(img.Channel)
+	if channel != 1 && channel != 3 && channel != 4 {
+		return nil, fmt.Errorf("unsupported channel count: %d", img.Channel)
+	}
+
+	width := int(img.Width)
+	height := int(img.Height)
+	rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	data := unsafe.Slice(img.Data, width*height*channel)
+	for i := 0; i < width*height; i++ {
+		index := i * channel
+		var c color.RGBA
+		switch channel {
+		case 1:
+			c = color.RGBA{data[index], data[index], data[index], 255}
+		case 3:
+			c = color.RGBA{data[index], data[index+1], data[index+2], 255}
+		case 4:
+			c = color.RGBAModel.Convert(color.NRGBA{data[index], data[index+1], data[index+2], data[index+3]}).(color.RGBA)
+		}
+		rgba.SetRGBA(i%width, i/width, c)
+	}
+
+	return rgba, nil
 }
 
 // EncodeVideo encodes PNG frame sequence to video using FFmpeg
@@ -139,3 +177,12 @@ func SaveFrames(frames []SDImage, outputDir string) error {
 
 	return nil
 }
+
+// ResolveSeed returns seed, or a random non-negative seed when seed is
+// negative, so that several passes meant to share a random seed do
+func ResolveSeed(seed int64) int64 {
+	if seed >= 0 {
+		return seed
+	}
+	return rand.Int64N(1 << 31)
+}
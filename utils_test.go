@@ -0,0 +1,79 @@
+package stablediffusion
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestImageToSDImageRoundTrip(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	img.SetRGBA(1, 1, color.RGBA{0, 0, 255, 255})
+
+	sdImg := ImageToSDImage(img)
+	if sdImg.Width != 2 || sdImg.Height != 2 || sdImg.Channel != 3 {
+		t.Fatalf("unexpected shape %dx%dx%d", sdImg.Width, sdImg.Height, sdImg.Channel)
+	}
+
+	back, err := SDImageToImage(&sdImg)
+	if err != nil {
+		t.Fatalf("SDImageToImage failed: %v", err)
+	}
+	if back.RGBAAt(0, 0) != img.RGBAAt(0, 0) || back.RGBAAt(1, 1) != img.RGBAAt(1, 1) {
+		t.Error("round trip changed pixel values")
+	}
+}
+
+func TestSDImageToImagePremultipliesAlpha(t *testing.T) {
+	data := []uint8{200, 100, 50, 128}
+	img, err := SDImageToImage(&SDImage{Width: 1, Height: 1, Channel: 4, Data: &data[0]})
+	if err != nil {
+		t.Fatalf("SDImageToImage failed: %v", err)
+	}
+	want := color.RGBAModel.Convert(color.NRGBA{200, 100, 50, 128}).(color.RGBA)
+	if got := img.RGBAAt(0, 0); got != want {
+		t.Errorf("got %v, want premultiplied %v", got, want)
+	}
+}
+
+func TestSDImageToImageInvalid(t *testing.T) {
+	if _, err := SDImageToImage(nil); err == nil {
+		t.Error("expected error for nil image")
+	}
+
+	data := []uint8{1, 2}
+	if _, err := SDImageToImage(&SDImage{Width: 1, Height: 1, Channel: 2, Data: &data[0]}); err == nil {
+		t.Error("expected error for unsupported channel count")
+	}
+}
+
+func TestSaveLoadImage(t *testing.T) {
+	data := []uint8{10, 20, 30, 40, 50, 60}
+	path := filepath.Join(t.TempDir(), "out.png")
+	if err := SaveImage(&SDImage{Width: 2, Height: 1, Channel: 3, Data: &data[0]}, path); err != nil {
+		t.Fatalf("SaveImage failed: %v", err)
+	}
+
+	loaded, err := LoadImage(path)
+	if err != nil {
+		t.Fatalf("LoadImage failed: %v", err)
+	}
+	back, err := SDImageToImage(&loaded)
+	if err != nil {
+		t.Fatalf("SDImageToImage failed: %v", err)
+	}
+	if back.RGBAAt(1, 0) != (color.RGBA{40, 50, 60, 255}) {
+		t.Errorf("unexpected pixel %v", back.RGBAAt(1, 0))
+	}
+}
+
+func TestResolveSeed(t *testing.T) {
+	if ResolveSeed(42) != 42 {
+		t.Error("non-negative seeds should be kept")
+	}
+	if s := ResolveSeed(-1); s < 0 {
+		t.Errorf("expected a non-negative seed, got %d", s)
+	}
+}
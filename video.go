@@ -0,0 +1,184 @@
+package stablediffusion
+
+import (
+	"fmt"
+	"image"
+	"runtime"
+	"unsafe"
+)
+
+const (
+	// DefaultVideoFPS is the frame rate Wan models are trained at
+	DefaultVideoFPS = 16
+	// wanFrameStep is the temporal compression of the Wan VAE; frame counts must be 4n+1
+	wanFrameStep = 4
+)
+
+// VideoRequest describes a video generation with optional first/last frame
+// and VACE control inputs. Images are fitted to Width x Height with Fit.
+type VideoRequest struct {
+	Prompt         string
+	NegativePrompt string
+	Width          int32
+	Height         int32
+	// Frames is the number of frames to generate and must be 4n+1
+	Frames int32
+	// FPS is reported with the result. Defaults to 16.
+	FPS int
+	// StartFrame and EndFrame anchor the first and last frames (I2V, FLF2V)
+	StartFrame image.Image
+	EndFrame   image.Image
+	// ControlFrames is a VACE control sequence
+	ControlFrames []image.Image
+	// VaceStrength scales the control sequence. Defaults to 1.
+	VaceStrength float32
+	Fit          ImageFit
+	// HighNoiseSampleParams drives the high-noise expert of Wan2.2 MoE models
+	HighNoiseSampleParams *SDSampleParams
+	// MOEBoundary is the timestep fraction where the low-noise expert takes over
+	MOEBoundary float32
+	// Seed overrides the seed in Params, or in the defaults when Params is nil
+	Seed *int64
+	// Params supplies sampling, seed, LoRAs and the remaining settings.
+	// Prompt, size, frame and image fields are overwritten. Defaults are used when nil.
+	Params *SDVidGenParams
+}
+
+// Video holds generated frames in Go memory
+type Video struct {
+	Frames []*image.RGBA
+	FPS    int
+}
+
+// GenerateVideo generates a video from req and copies the frames out of native memory
+func GenerateVideo(ctx *SDContext, req VideoRequest) (*Video, error) {
+	if ctx == nil || ctx.ptr == nil {
+		return nil, fmt.Errorf("SD context is not initialized")
+	}
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	var params SDVidGenParams
+	if req.Params != nil {
+		params = *req.Params
+	} else {
+		ctx.sd.VidGenParamsInit(&params)
+	}
+	if req.Seed != nil {
+		params.Seed = *req.Seed
+	}
+	params.Prompt = CString(req.Prompt)
+	params.NegativePrompt = CString(req.NegativePrompt)
+	params.Width = req.Width
+	params.Height = req.Height
+	params.VideoFrames = req.Frames
+	params.InitImage = SDImage{}
+	params.EndImage = SDImage{}
+	params.ControlFrames = nil
+	params.ControlFramesSize = 0
+
+	width, height := int(req.Width), int(req.Height)
+	if req.StartFrame != nil {
+		params.InitImage = ImageToSDImage(FitImage(req.StartFrame, width, height, req.Fit))
+	}
+	if req.EndFrame != nil {
+		params.EndImage = ImageToSDImage(FitImage(req.EndFrame, width, height, req.Fit))
+	}
+
+	var control []SDImage
+	if len(req.ControlFrames) > 0 {
+		control = make([]SDImage, len(req.ControlFrames))
+		for i, frame := range req.ControlFrames {
+			control[i] = ImageToSDImage(FitImage(frame, width, height, req.Fit))
+		}
+		params.ControlFrames = &control[0]
+		params.ControlFramesSize = int32(len(control))
+		params.VaceStrength = req.VaceStrength
+		if params.VaceStrength == 0 {
+			params.VaceStrength = 1
+		}
+	}
+	defer runtime.KeepAlive(control)
+
+	if req.HighNoiseSampleParams != nil {
+		params.HighNoiseSampleParams = *req.HighNoiseSampleParams
+	}
+	if req.MOEBoundary > 0 {
+		params.MOEBoundary = req.MOEBoundary
+	}
+
+	frames, err := ctx.generateVideoFrames(&params)
+	if err != nil {
+		return nil, err
+	}
+
+	fps := req.FPS
+	if fps <= 0 {
+		fps = DefaultVideoFPS
+	}
+	return &Video{Frames: frames, FPS: fps}, nil
+}
+
+// validate checks sizes, frame counts and image inputs
+func (req *VideoRequest) validate() error {
+	if req.Width <= 0 || req.Height <= 0 {
+		return fmt.Errorf("invalid video size %dx%d", req.Width, req.Height)
+	}
+	if req.Frames <= 0 || (req.Frames-1)%wanFrameStep != 0 {
+		return fmt.Errorf("video frames must be 4n+1, got %d", req.Frames)
+	}
+	if req.MOEBoundary < 0 || req.MOEBoundary > 1 {
+		return fmt.Errorf("MoE boundary must be between 0 and 1, got %v", req.MOEBoundary)
+	}
+	if req.StartFrame != nil && req.StartFrame.Bounds().Empty() {
+		return fmt.Errorf("start frame is empty")
+	}
+	if req.EndFrame != nil && req.EndFrame.Bounds().Empty() {
+		return fmt.Errorf("end frame is empty")
+	}
+	for i, frame := range req.ControlFrames {
+		if frame == nil || frame.Bounds().Empty() {
+			return fmt.Errorf("control frame %d is empty", i)
+		}
+	}
+	return nil
+}
+
+// generateVideoFrames runs generate_video, copies every frame into Go memory
+// and releases the native frames
+func (ctx *SDContext) generateVideoFrames(params *SDVidGenParams) ([]*image.RGBA, error) {
+	defer runtime.KeepAlive(params)
+
+	var numFrames int32
+	framesPtr := ctx.sd.generateVideo(ctx.ptr, params, &numFrames)
+	if framesPtr == nil || numFrames <= 0 {
+		return nil, fmt.Errorf("generate_video returned no frames")
+	}
+	native := unsafe.Slice(framesPtr, numFrames)
+	defer ctx.sd.freeFrames(native)
+
+	frames := make([]*image.RGBA, len(native))
+	for i := range native {
+		frame, err := SDImageToImage(&native[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert frame %d: %w", i, err)
+		}
+		frames[i] = frame
+	}
+	return frames, nil
+}
+
+// freeFrames releases a native frame array returned by generate_video.
+// It is a no-op when the library does not expose free.
+func (sd *StableDiffusion) freeFrames(frames []SDImage) {
+	if sd.free == nil || len(frames) == 0 {
+		return
+	}
+	for _, frame := range frames {
+		if frame.Data != nil {
+			sd.free(unsafe.Pointer(frame.Data))
+		}
+	}
+	sd.free(unsafe.Pointer(&frames[0]))
+}
@@ -0,0 +1,261 @@
+// Package video runs video-to-video style transfer, chained long video
+// extension and frame interpolation on top of stablediffusion.GenerateVideo.
+//
+// Clips are decoded from a directory of frames or through an ffmpeg pipe and
+// keep their frame rate so results can be reassembled with the original timing.
+package video
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// FFmpeg and FFprobe are the executables used by Decode and Encode
+	FFmpeg  = "ffmpeg"
+	FFprobe = "ffprobe"
+)
+
+// DefaultFPS is used when a frame rate cannot be determined
+const DefaultFPS = 16
+
+// frameExts are the frame extensions picked up by ReadDir
+var frameExts = []string{".png", ".jpg", ".jpeg"}
+
+// Clip is a sequence of frames played at FPS
+type Clip struct {
+	Frames []image.Image
+	FPS    float64
+}
+
+// Duration returns the playback length of the clip
+func (c *Clip) Duration() time.Duration {
+	if c.FPS <= 0 {
+		return 0
+	}
+	return time.Duration(float64(len(c.Frames)) / c.FPS * float64(time.Second))
+}
+
+// ReadDir loads every frame in dir in name order
+func ReadDir(dir string, fps float64) (*Clip, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frame directory: %w", err)
+	}
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+
+	clip := &Clip{FPS: fps}
+	for _, e := range entries {
+		if e.IsDir() || !slices.Contains(frameExts, strings.ToLower(filepath.Ext(e.Name()))) {
+			continue
+		}
+		img, err := readImage(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		clip.Frames = append(clip.Frames, img)
+	}
+	if len(clip.Frames) == 0 {
+		return nil, fmt.Errorf("no frames in %s", dir)
+	}
+	return clip, nil
+}
+
+// WriteDir saves the frames as frame_0001.png, frame_0002.png, ... in dir
+func (c *Clip) WriteDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	for i, frame := range c.Frames {
+		if err := writePNG(filepath.Join(dir, fmt.Sprintf("frame_%04d.png", i+1)), frame); err != nil {
+			return fmt.Errorf("failed to save frame %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// DecodeReader reads a stream of concatenated PNG images, as written by
+// ffmpeg -f image2pipe -c:v png
+func DecodeReader(r io.Reader, fps float64) (*Clip, error) {
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+	br := bufio.NewReader(r)
+	clip := &Clip{FPS: fps}
+	for {
+		if _, err := br.Peek(1); errors.Is(err, io.EOF) {
+			break
+		}
+		img, err := png.Decode(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode frame %d: %w", len(clip.Frames)+1, err)
+		}
+		clip.Frames = append(clip.Frames, img)
+	}
+	if len(clip.Frames) == 0 {
+		return nil, fmt.Errorf("no frames in stream")
+	}
+	return clip, nil
+}
+
+// Decode decodes the video at path through an ffmpeg pipe.
+// The frame rate is read with ffprobe and falls back to DefaultFPS.
+func Decode(ctx context.Context, path string) (*Clip, error) {
+	if _, err := exec.LookPath(FFmpeg); err != nil {
+		return nil, fmt.Errorf("ffmpeg not found: %w", err)
+	}
+
+	fps, err := probeFPS(ctx, path)
+	if err != nil {
+		log.Printf("failed to probe frame rate, using %d fps: %v", DefaultFPS, err)
+		fps = DefaultFPS
+	}
+
+	cmd := exec.CommandContext(ctx, FFmpeg, "-v", "error", "-i", path, "-f", "image2pipe", "-c:v", "png", "-")
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ffmpeg pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	clip, decodeErr := DecodeReader(stdout, fps)
+	if decodeErr != nil {
+		// Drain so ffmpeg is not blocked writing when we wait for it
+		_, _ = io.Copy(io.Discard, stdout)
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	return clip, decodeErr
+}
+
+// Encode pipes the frames to ffmpeg and writes an H.264 video at path
+func (c *Clip) Encode(ctx context.Context, path string) error {
+	if len(c.Frames) == 0 {
+		return fmt.Errorf("clip has no frames")
+	}
+	if _, err := exec.LookPath(FFmpeg); err != nil {
+		return fmt.Errorf("ffmpeg not found: %w", err)
+	}
+	fps := c.FPS
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+
+	cmd := exec.CommandContext(ctx, FFmpeg,
+		"-y", "-v", "error",
+		"-f", "image2pipe",
+		"-framerate", strconv.FormatFloat(fps, 'f', -1, 64),
+		"-i", "-",
+		"-c:v", "libx264",
+		"-pix_fmt", "yuv420p",
+		path,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open ffmpeg pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	var writeErr error
+	for i, frame := range c.Frames {
+		if writeErr = png.Encode(stdin, frame); writeErr != nil {
+			writeErr = fmt.Errorf("failed to write frame %d: %w", i+1, writeErr)
+			break
+		}
+	}
+	if err := stdin.Close(); err != nil && writeErr == nil {
+		writeErr = fmt.Errorf("failed to close ffmpeg pipe: %w", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	return writeErr
+}
+
+// probeFPS reads the average frame rate of the first video stream
+func probeFPS(ctx context.Context, path string) (float64, error) {
+	out, err := exec.CommandContext(ctx, FFprobe,
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=avg_frame_rate",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return parseRate(strings.TrimSpace(string(out)))
+}
+
+// parseRate parses an ffprobe rate such as "30000/1001" or "25"
+func parseRate(s string) (float64, error) {
+	num, den, found := strings.Cut(s, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid frame rate %q", s)
+	}
+	d := 1.0
+	if found {
+		if d, err = strconv.ParseFloat(den, 64); err != nil {
+			return 0, fmt.Errorf("invalid frame rate %q", s)
+		}
+	}
+	if n <= 0 || d <= 0 {
+		return 0, fmt.Errorf("invalid frame rate %q", s)
+	}
+	return n / d, nil
+}
+
+func readImage(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open frame: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close file: %v", err)
+		}
+	}()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode frame %s: %w", path, err)
+	}
+	return img, nil
+}
+
+func writePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,94 @@
+package video
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDecodeReader(t *testing.T) {
+	var buf bytes.Buffer
+	for i := range 3 {
+		if err := png.Encode(&buf, solid(4, 2, uint8(i*50))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clip, err := DecodeReader(&buf, 24)
+	if err != nil {
+		t.Fatalf("DecodeReader failed: %v", err)
+	}
+	if len(clip.Frames) != 3 || clip.FPS != 24 {
+		t.Fatalf("expected 3 frames at 24 fps, got %d at %v", len(clip.Frames), clip.FPS)
+	}
+	for i, frame := range clip.Frames {
+		if r, _, _, _ := frame.At(0, 0).RGBA(); uint8(r>>8) != uint8(i*50) {
+			t.Errorf("frame %d out of order", i)
+		}
+	}
+
+	if _, err := DecodeReader(bytes.NewReader(nil), 0); err == nil {
+		t.Error("expected error for empty stream")
+	}
+	if _, err := DecodeReader(bytes.NewReader([]byte("not a png")), 0); err == nil {
+		t.Error("expected error for corrupt stream")
+	}
+}
+
+func TestReadWriteDir(t *testing.T) {
+	dir := t.TempDir()
+	clip := &Clip{Frames: []image.Image{solid(2, 2, 10), solid(2, 2, 20)}, FPS: 8}
+	if err := clip.WriteDir(dir); err != nil {
+		t.Fatalf("WriteDir failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadDir(dir, 8)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(read.Frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(read.Frames))
+	}
+	if r, _, _, _ := read.Frames[1].At(0, 0).RGBA(); uint8(r>>8) != 20 {
+		t.Errorf("frames out of order")
+	}
+	if read.Duration() != 250*time.Millisecond {
+		t.Errorf("expected 250ms, got %v", read.Duration())
+	}
+
+	if _, err := ReadDir(t.TempDir(), 0); err == nil {
+		t.Error("expected error for empty directory")
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	for in, want := range map[string]float64{"25": 25, "30000/1001": 30000.0 / 1001, "24/1": 24} {
+		got, err := parseRate(in)
+		if err != nil || got != want {
+			t.Errorf("parseRate(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0/0", "abc", "30/x"} {
+		if _, err := parseRate(in); err == nil {
+			t.Errorf("parseRate(%q): expected error", in)
+		}
+	}
+}
+
+func solid(width, height int, v uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
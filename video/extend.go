@@ -0,0 +1,308 @@
+package video
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// ExtendRequest describes a long video rendered as a chain of short segments
+type ExtendRequest struct {
+	// Request is the template for every segment. Frames is the segment length
+	// and must be 4n+1; StartFrame and ControlFrames only apply to the first
+	// segment when Initial is nil. Seed is replaced per segment.
+	Request stablediffusion.VideoRequest
+	// Segments is the number of segments to generate
+	Segments int
+	// Overlap is the number of frames each segment shares with the previous one.
+	// 1 continues from the last frame as the start frame; more frames are
+	// passed as VACE control and cross-faded. Defaults to 1.
+	Overlap int
+	// Seed is the seed of the first segment; segment i uses Seed+i.
+	// A negative seed is resolved to one random seed for the whole render,
+	// so such a render never resumes from Dir.
+	Seed int64
+	// Initial is an existing clip to extend. The first segment continues from it.
+	Initial *Clip
+	// Dir persists every generated segment so an interrupted render resumes
+	// where it stopped. A cached segment is only reused when it was rendered
+	// with the same prompts, seed, size, frames, overlap, sampling settings
+	// and conditioning frames, and only while every earlier segment was
+	// reused too.
+	Dir string
+	// Progress is called after each segment
+	Progress func(done, total int)
+}
+
+// ExtendVideo generates req.Segments chained segments and returns the joined clip
+func ExtendVideo(ctx *stablediffusion.SDContext, req ExtendRequest) (*Clip, error) {
+	if req.Segments <= 0 {
+		return nil, fmt.Errorf("segments must be positive, got %d", req.Segments)
+	}
+	frames := int(req.Request.Frames)
+	if frames <= 0 || (frames-1)%4 != 0 {
+		return nil, fmt.Errorf("segment frames must be 4n+1, got %d", frames)
+	}
+	overlap := req.Overlap
+	if overlap <= 0 {
+		overlap = 1
+	}
+	if overlap >= frames {
+		return nil, fmt.Errorf("overlap %d must be smaller than the segment length %d", overlap, frames)
+	}
+
+	out := &Clip{FPS: float64(req.Request.FPS)}
+	if out.FPS <= 0 {
+		out.FPS = stablediffusion.DefaultVideoFPS
+	}
+	var prev []image.Image
+	if req.Initial != nil && len(req.Initial.Frames) > 0 {
+		out.Frames = append(out.Frames, req.Initial.Frames...)
+		if req.Initial.FPS > 0 {
+			out.FPS = req.Initial.FPS
+		}
+		prev = req.Initial.Frames
+	}
+
+	req.Seed = stablediffusion.ResolveSeed(req.Seed)
+	reuse := true
+	for i := 0; i < req.Segments; i++ {
+		segment, cached, err := req.segment(ctx, i, prev, overlap, reuse)
+		if err != nil {
+			return nil, err
+		}
+		// Later segments continue from this one, so a regenerated segment
+		// invalidates everything cached after it
+		reuse = reuse && cached
+		if prev == nil {
+			out.Frames = append(out.Frames, segment...)
+		} else {
+			out.Frames = joinOverlap(out.Frames, segment, min(overlap, len(prev)))
+		}
+		prev = segment
+
+		if req.Progress != nil {
+			req.Progress(i+1, req.Segments)
+		}
+	}
+	return out, nil
+}
+
+// segmentManifestName is the file in a segment directory that records how it was rendered
+const segmentManifestName = "segment.json"
+
+// segmentManifest holds the request parameters a cached segment was rendered with
+type segmentManifest struct {
+	Prompt         string `json:"prompt"`
+	NegativePrompt string `json:"negative_prompt"`
+	Seed           int64  `json:"seed"`
+	Width          int32  `json:"width"`
+	Height         int32  `json:"height"`
+	Frames         int32  `json:"frames"`
+	Overlap        int    `json:"overlap"`
+	// Params and Conditioning are hashes of the sampling settings and of the
+	// start, end and control frames, which carry the clip being continued
+	Params       string `json:"params"`
+	Conditioning string `json:"conditioning"`
+}
+
+// segment loads segment i from Dir when reuse is set, or generates and
+// persists it. It reports whether the segment came from Dir.
+func (req *ExtendRequest) segment(ctx *stablediffusion.SDContext, i int, prev []image.Image, overlap int, reuse bool) ([]image.Image, bool, error) {
+	r := req.Request
+	seed := req.Seed + int64(i)
+	r.Seed = &seed
+	if prev != nil {
+		r.StartFrame, r.EndFrame, r.ControlFrames = nil, nil, nil
+		if overlap == 1 {
+			r.StartFrame = prev[len(prev)-1]
+		} else {
+			r.ControlFrames = extensionControl(prev, overlap, int(r.Frames))
+		}
+	}
+
+	dir := ""
+	var manifest segmentManifest
+	if req.Dir != "" {
+		dir = filepath.Join(req.Dir, fmt.Sprintf("segment_%04d", i+1))
+		manifest = segmentManifest{
+			Prompt:         r.Prompt,
+			NegativePrompt: r.NegativePrompt,
+			Seed:           seed,
+			Width:          r.Width,
+			Height:         r.Height,
+			Frames:         r.Frames,
+			Overlap:        overlap,
+			Params:         paramsHash(&r),
+			Conditioning:   framesHash(&r),
+		}
+		if reuse {
+			if cached, ok := loadSegment(dir, manifest); ok {
+				return cached, true, nil
+			}
+		}
+	}
+
+	video, err := generateVideo(ctx, r)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to render segment %d: %w", i+1, err)
+	}
+	if len(video.Frames) != int(r.Frames) {
+		return nil, false, fmt.Errorf("segment %d: expected %d frames, got %d", i+1, r.Frames, len(video.Frames))
+	}
+	segment := make([]image.Image, len(video.Frames))
+	for j, frame := range video.Frames {
+		segment[j] = frame
+	}
+
+	if dir != "" {
+		if err := saveSegment(dir, segment, manifest); err != nil {
+			return nil, false, fmt.Errorf("failed to save segment %d: %w", i+1, err)
+		}
+	}
+	return segment, false, nil
+}
+
+// paramsHash hashes the settings besides the manifest fields that change
+// what a segment renders: sampling, guidance, LoRAs, cache, MoE and VACE
+func paramsHash(r *stablediffusion.VideoRequest) string {
+	h := sha256.New()
+	if p := r.Params; p != nil {
+		q := *p
+		writeSampleParams(h, q.SampleParams)
+		writeSampleParams(h, q.HighNoiseSampleParams)
+		if q.Loras != nil {
+			for _, l := range unsafe.Slice(q.Loras, q.LoraCount) {
+				fmt.Fprintf(h, "lora %q %v %v\n", stablediffusion.CGoString(l.Path), l.Multiplier, l.IsHighNoise)
+			}
+		}
+		fmt.Fprintf(h, "scm %q\n", stablediffusion.CGoString(q.Cache.ScmMask))
+		q.Cache.ScmMask = nil
+		fmt.Fprintf(h, "clip skip %d strength %v moe %v cache %+v\n", q.ClipSkip, q.Strength, q.MOEBoundary, q.Cache)
+	}
+	if r.HighNoiseSampleParams != nil {
+		writeSampleParams(h, *r.HighNoiseSampleParams)
+	}
+	fmt.Fprintf(h, "moe %v vace %v fit %v\n", r.MOEBoundary, r.VaceStrength, r.Fit)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeSampleParams writes sp to w with its custom sigmas and skip layers
+// in place of the pointers to them
+func writeSampleParams(w io.Writer, sp stablediffusion.SDSampleParams) {
+	var sigmas []float32
+	if sp.CustomSigmas != nil {
+		sigmas = unsafe.Slice(sp.CustomSigmas, sp.CustomSigmasCount)
+	}
+	var layers []int32
+	if sp.Guidance.SLG.Layers != nil {
+		layers = unsafe.Slice(sp.Guidance.SLG.Layers, sp.Guidance.SLG.LayerCount)
+	}
+	sp.CustomSigmas, sp.Guidance.SLG.Layers = nil, nil
+	fmt.Fprintf(w, "sample %+v sigmas %v layers %v\n", sp, sigmas, layers)
+}
+
+// framesHash hashes the pixels of the start, end and control frames
+func framesHash(r *stablediffusion.VideoRequest) string {
+	h := sha256.New()
+	write := func(name string, img image.Image) {
+		if img == nil {
+			fmt.Fprintf(h, "%s none\n", name)
+			return
+		}
+		rgba := toRGBA(img)
+		fmt.Fprintf(h, "%s %dx%d\n", name, rgba.Rect.Dx(), rgba.Rect.Dy())
+		h.Write(rgba.Pix[:rgba.Stride*rgba.Rect.Dy()])
+	}
+	write("start", r.StartFrame)
+	write("end", r.EndFrame)
+	for _, frame := range r.ControlFrames {
+		write("control", frame)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// extensionControl builds a VACE control sequence that starts with the last
+// overlap frames of prev and leaves the rest gray for the model to fill
+func extensionControl(prev []image.Image, overlap, frames int) []image.Image {
+	overlap = min(overlap, len(prev))
+	control := make([]image.Image, 0, frames)
+	control = append(control, prev[len(prev)-overlap:]...)
+
+	b := prev[len(prev)-1].Bounds()
+	gray := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(gray, gray.Bounds(), image.NewUniform(color.RGBA{127, 127, 127, 255}), image.Point{}, draw.Src)
+	for len(control) < frames {
+		control = append(control, gray)
+	}
+	return control
+}
+
+// joinOverlap cross-fades the first overlap frames of segment into the last
+// overlap frames of frames and appends the rest
+func joinOverlap(frames, segment []image.Image, overlap int) []image.Image {
+	base := len(frames) - overlap
+	for k := 0; k < overlap; k++ {
+		w := float64(k+1) / float64(overlap+1)
+		frames[base+k] = Blend(frames[base+k], segment[k], w)
+	}
+	return append(frames, segment[overlap:]...)
+}
+
+// loadSegment reads the cached segment in dir when its manifest matches want
+func loadSegment(dir string, want segmentManifest) ([]image.Image, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, segmentManifestName))
+	if err != nil {
+		return nil, false
+	}
+	var got segmentManifest
+	if err := json.Unmarshal(data, &got); err != nil || got != want {
+		return nil, false
+	}
+	cached, err := ReadDir(dir, 0)
+	if err != nil || len(cached.Frames) != int(want.Frames) {
+		return nil, false
+	}
+	return cached.Frames, true
+}
+
+// saveSegment writes a segment and its manifest to a temporary directory and
+// renames it into place so an interrupted write is never mistaken for a
+// finished segment
+func saveSegment(dir string, frames []image.Image, manifest segmentManifest) error {
+	tmp := dir + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := (&Clip{Frames: frames}).WriteDir(tmp); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, segmentManifestName), data, 0644); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		if rmErr := os.RemoveAll(tmp); rmErr != nil {
+			log.Printf("failed to remove %s: %v", tmp, rmErr)
+		}
+		return err
+	}
+	return nil
+}
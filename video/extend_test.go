@@ -0,0 +1,225 @@
+package video
+
+import (
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+func TestExtendVideoResumes(t *testing.T) {
+	calls := fakeGenerate(t)
+	dir := t.TempDir()
+	req := ExtendRequest{
+		Request:  stablediffusion.VideoRequest{Width: 16, Height: 16, Frames: 5, Params: &stablediffusion.SDVidGenParams{}},
+		Segments: 3,
+		Seed:     10,
+		Dir:      dir,
+	}
+
+	out, err := ExtendVideo(nil, req)
+	if err != nil {
+		t.Fatalf("ExtendVideo failed: %v", err)
+	}
+	if len(out.Frames) != 5+4+4 || out.FPS != stablediffusion.DefaultVideoFPS {
+		t.Fatalf("expected 13 frames at %d fps, got %d at %v", stablediffusion.DefaultVideoFPS, len(out.Frames), out.FPS)
+	}
+	if len(*calls) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(*calls))
+	}
+	for i, call := range *calls {
+		if *call.Seed != 10+int64(i) {
+			t.Errorf("segment %d: expected seed %d, got %d", i, 10+i, *call.Seed)
+		}
+	}
+	if (*calls)[0].StartFrame != nil {
+		t.Error("first segment should not get a start frame")
+	}
+	if r, _, _, _ := (*calls)[1].StartFrame.At(0, 0).RGBA(); r>>8 != 1 {
+		t.Error("second segment should start from the last frame of the first")
+	}
+
+	// A finished render is served from disk
+	if _, err := ExtendVideo(nil, req); err != nil || len(*calls) != 3 {
+		t.Fatalf("expected no new segments, got %d calls, err %v", len(*calls), err)
+	}
+
+	// A missing segment is regenerated with its own seed
+	if err := os.RemoveAll(filepath.Join(dir, "segment_0003")); err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := ExtendVideo(nil, req)
+	if err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if len(*calls) != 4 || *(*calls)[3].Seed != 12 || len(resumed.Frames) != 13 {
+		t.Errorf("expected only segment 3 to be regenerated, got %d calls", len(*calls))
+	}
+}
+
+func TestExtendVideoIgnoresMismatchedCache(t *testing.T) {
+	calls := fakeGenerate(t)
+	dir := t.TempDir()
+	req := ExtendRequest{
+		Request:  stablediffusion.VideoRequest{Prompt: "a river", Width: 16, Height: 16, Frames: 5, Params: &stablediffusion.SDVidGenParams{}},
+		Segments: 2,
+		Seed:     10,
+		Dir:      dir,
+	}
+	if _, err := ExtendVideo(nil, req); err != nil {
+		t.Fatalf("ExtendVideo failed: %v", err)
+	}
+
+	for _, change := range []func(r *ExtendRequest){
+		func(r *ExtendRequest) { r.Request.Prompt = "a lake" },
+		func(r *ExtendRequest) { r.Seed = 20 },
+		func(r *ExtendRequest) { r.Request.Width = 32 },
+		func(r *ExtendRequest) { r.Overlap = 3 },
+		func(r *ExtendRequest) {
+			r.Request.Params = &stablediffusion.SDVidGenParams{SampleParams: stablediffusion.SDSampleParams{SampleSteps: 8}}
+		},
+		func(r *ExtendRequest) { r.Request.VaceStrength = 0.5 },
+		func(r *ExtendRequest) { r.Request.StartFrame = solid(16, 16, 200) },
+		func(r *ExtendRequest) { r.Initial = &Clip{Frames: []image.Image{solid(16, 16, 200)}} },
+	} {
+		changed := req
+		change(&changed)
+		before := len(*calls)
+		if _, err := ExtendVideo(nil, changed); err != nil {
+			t.Fatalf("ExtendVideo failed: %v", err)
+		}
+		if len(*calls)-before != 2 {
+			t.Errorf("expected both segments to be regenerated, got %d calls", len(*calls)-before)
+		}
+		// Restore the original render so the next change is compared against it
+		if _, err := ExtendVideo(nil, req); err != nil {
+			t.Fatalf("ExtendVideo failed: %v", err)
+		}
+	}
+
+	// A segment without a manifest is not trusted either
+	if _, err := ExtendVideo(nil, req); err != nil {
+		t.Fatalf("ExtendVideo failed: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "segment_0001", segmentManifestName)); err != nil {
+		t.Fatal(err)
+	}
+	before := len(*calls)
+	if _, err := ExtendVideo(nil, req); err != nil || len(*calls)-before != 2 {
+		t.Errorf("expected segment 1 and the segment continuing from it to be regenerated, got %d calls, err %v", len(*calls)-before, err)
+	}
+}
+
+func TestExtendVideoDefaultParams(t *testing.T) {
+	calls := fakeGenerate(t)
+	req := ExtendRequest{
+		Request:  stablediffusion.VideoRequest{Width: 16, Height: 16, Frames: 5},
+		Segments: 3,
+		Seed:     -1,
+		Dir:      t.TempDir(),
+	}
+	if _, err := ExtendVideo(nil, req); err != nil {
+		t.Fatalf("ExtendVideo failed: %v", err)
+	}
+
+	first := *(*calls)[0].Seed
+	if first < 0 {
+		t.Fatalf("random seed was not resolved, got %d", first)
+	}
+	for i, call := range *calls {
+		if call.Params != nil {
+			t.Errorf("segment %d: params should stay nil so the context defaults apply", i)
+		}
+		if *call.Seed != first+int64(i) {
+			t.Errorf("segment %d: expected seed %d, got %d", i, first+int64(i), *call.Seed)
+		}
+	}
+}
+
+func TestExtendVideoFailure(t *testing.T) {
+	calls := fakeGenerate(t)
+	fake := generateVideo
+	generateVideo = func(ctx *stablediffusion.SDContext, req stablediffusion.VideoRequest) (*stablediffusion.Video, error) {
+		if *req.Seed == 1 {
+			return nil, errors.New("out of memory")
+		}
+		return fake(ctx, req)
+	}
+
+	dir := t.TempDir()
+	req := ExtendRequest{
+		Request:  stablediffusion.VideoRequest{Width: 16, Height: 16, Frames: 5, Params: &stablediffusion.SDVidGenParams{}},
+		Segments: 2,
+		Dir:      dir,
+	}
+	if _, err := ExtendVideo(nil, req); err == nil {
+		t.Fatal("expected error from failing segment")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "segment_0001", "frame_0005.png")); err != nil {
+		t.Errorf("finished segment was not persisted: %v", err)
+	}
+
+	generateVideo = fake
+	if _, err := ExtendVideo(nil, req); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if len(*calls) != 2 {
+		t.Errorf("expected the first segment to be reused, got %d calls", len(*calls))
+	}
+}
+
+func TestExtendVideoOverlap(t *testing.T) {
+	calls := fakeGenerate(t)
+	initial := &Clip{Frames: []image.Image{solid(16, 16, 100), solid(16, 16, 100), solid(16, 16, 100)}, FPS: 24}
+
+	out, err := ExtendVideo(nil, ExtendRequest{
+		Request:  stablediffusion.VideoRequest{Width: 16, Height: 16, Frames: 5, Params: &stablediffusion.SDVidGenParams{}},
+		Segments: 1,
+		Overlap:  2,
+		Initial:  initial,
+	})
+	if err != nil {
+		t.Fatalf("ExtendVideo failed: %v", err)
+	}
+	if len(out.Frames) != 3+3 || out.FPS != 24 {
+		t.Fatalf("expected 6 frames at 24 fps, got %d at %v", len(out.Frames), out.FPS)
+	}
+
+	call := (*calls)[0]
+	if call.StartFrame != nil || len(call.ControlFrames) != 5 {
+		t.Fatalf("expected 5 control frames and no start frame, got %d", len(call.ControlFrames))
+	}
+	if call.ControlFrames[0] != initial.Frames[1] || call.ControlFrames[1] != initial.Frames[2] {
+		t.Error("control should start with the last frames of the initial clip")
+	}
+	if r, _, _, _ := call.ControlFrames[2].At(0, 0).RGBA(); r>>8 != 127 {
+		t.Errorf("remaining control frames should be gray, got %d", r>>8)
+	}
+
+	// Overlapping frames fade from the initial clip (100) to the segment (1)
+	want := []uint8{100, 67, 34, 1}
+	for i, w := range want {
+		if r, _, _, _ := out.Frames[i].At(0, 0).RGBA(); uint8(r>>8) != w {
+			t.Errorf("frame %d: got %d, want %d", i, r>>8, w)
+		}
+	}
+	if initial.Frames[2].(*image.RGBA).RGBAAt(0, 0).R != 100 {
+		t.Error("ExtendVideo modified the initial clip")
+	}
+}
+
+func TestExtendVideoValidation(t *testing.T) {
+	fakeGenerate(t)
+	for name, req := range map[string]ExtendRequest{
+		"segments": {Request: stablediffusion.VideoRequest{Frames: 5}},
+		"frames":   {Request: stablediffusion.VideoRequest{Frames: 6}, Segments: 1},
+		"overlap":  {Request: stablediffusion.VideoRequest{Frames: 5}, Segments: 1, Overlap: 5},
+	} {
+		if _, err := ExtendVideo(nil, req); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
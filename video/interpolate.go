@@ -0,0 +1,193 @@
+package video
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// Interpolation selects how in-between frames are synthesized
+type Interpolation int
+
+const (
+	// Linear cross-fades the neighbouring frames
+	Linear Interpolation = iota
+	// FlowLite estimates per-block motion and blends motion-compensated frames
+	FlowLite
+)
+
+const (
+	// flowBlock is the block size for motion estimation
+	flowBlock = 8
+	// flowSearch is the search radius in pixels for motion estimation
+	flowSearch = 6
+)
+
+// Interpolate inserts factor-1 frames between every pair of frames and
+// multiplies the frame rate by factor so the clip keeps its timing
+func (c *Clip) Interpolate(factor int, mode Interpolation) *Clip {
+	if factor <= 1 || len(c.Frames) < 2 {
+		return &Clip{Frames: append([]image.Image(nil), c.Frames...), FPS: c.FPS}
+	}
+
+	out := &Clip{
+		Frames: make([]image.Image, 0, (len(c.Frames)-1)*factor+1),
+		FPS:    c.FPS * float64(factor),
+	}
+	for i := 0; i+1 < len(c.Frames); i++ {
+		a, b := toRGBA(c.Frames[i]), toRGBA(c.Frames[i+1])
+		// Both directions are estimated once per pair and shared by its in-between frames
+		var flow, back []image.Point
+		if mode == FlowLite && a.Bounds().Size() == b.Bounds().Size() {
+			flow, back = estimateFlow(a, b), estimateFlow(b, a)
+		}
+		out.Frames = append(out.Frames, c.Frames[i])
+		for k := 1; k < factor; k++ {
+			t := float64(k) / float64(factor)
+			if flow != nil {
+				out.Frames = append(out.Frames, warpBlend(a, b, flow, back, t))
+			} else {
+				out.Frames = append(out.Frames, Blend(a, b, t))
+			}
+		}
+	}
+	out.Frames = append(out.Frames, c.Frames[len(c.Frames)-1])
+	return out
+}
+
+// Between synthesizes the frame at t in [0, 1] between a and b
+func Between(a, b image.Image, t float64, mode Interpolation) *image.RGBA {
+	ra, rb := toRGBA(a), toRGBA(b)
+	if mode == FlowLite && ra.Bounds().Size() == rb.Bounds().Size() {
+		return warpBlend(ra, rb, estimateFlow(ra, rb), estimateFlow(rb, ra), t)
+	}
+	return Blend(ra, rb, t)
+}
+
+// Blend mixes a and b with weight t on b. b is resized to a when sizes differ.
+func Blend(a, b image.Image, t float64) *image.RGBA {
+	ra, rb := toRGBA(a), toRGBA(b)
+	if ra.Bounds().Size() != rb.Bounds().Size() {
+		rb = stablediffusion.ResizeLanczos(rb, ra.Bounds().Dx(), ra.Bounds().Dy())
+	}
+	out := image.NewRGBA(ra.Bounds())
+	for i := range out.Pix {
+		out.Pix[i] = mix(ra.Pix[i], rb.Pix[i], t)
+	}
+	return out
+}
+
+// estimateFlow finds the displacement of each block of a in b by minimizing
+// the sum of absolute luminance differences
+func estimateFlow(a, b *image.RGBA) []image.Point {
+	w, h := a.Bounds().Dx(), a.Bounds().Dy()
+	la, lb := luma(a), luma(b)
+	bw, bh := (w+flowBlock-1)/flowBlock, (h+flowBlock-1)/flowBlock
+	flow := make([]image.Point, bw*bh)
+
+	for by := 0; by < bh; by++ {
+		for bx := 0; bx < bw; bx++ {
+			x0, y0 := bx*flowBlock, by*flowBlock
+			x1, y1 := min(x0+flowBlock, w), min(y0+flowBlock, h)
+			best, bestCost := image.Point{}, math.MaxInt
+			for dy := -flowSearch; dy <= flowSearch; dy++ {
+				for dx := -flowSearch; dx <= flowSearch; dx++ {
+					cost := 0
+					for y := y0; y < y1 && cost <= bestCost; y++ {
+						sy := min(max(y+dy, 0), h-1)
+						for x := x0; x < x1; x++ {
+							sx := min(max(x+dx, 0), w-1)
+							d := int(la[y*w+x]) - int(lb[sy*w+sx])
+							cost += max(d, -d)
+						}
+					}
+					// Prefer the smallest motion on ties so flat areas stay still
+					if cost < bestCost || (cost == bestCost && dx*dx+dy*dy < best.X*best.X+best.Y*best.Y) {
+						best, bestCost = image.Pt(dx, dy), cost
+					}
+				}
+			}
+			flow[by*bw+bx] = best
+		}
+	}
+	return flow
+}
+
+// warpBlend moves a forward along flow and b backward along back to time t and blends them
+func warpBlend(a, b *image.RGBA, flow, back []image.Point, t float64) *image.RGBA {
+	w, h := a.Bounds().Dx(), a.Bounds().Dy()
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	// Splat the blocks of a first and let b fill what a leaves uncovered,
+	// such as the area a moving object has just left
+	filled := make([]bool, w*h)
+	splat(out, filled, a, b, flow, t)
+	splat(out, filled, b, a, back, 1-t)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if filled[y*w+x] {
+				continue
+			}
+			ai, bi, oi := a.PixOffset(x, y), b.PixOffset(x, y), out.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				out.Pix[oi+c] = mix(a.Pix[ai+c], b.Pix[bi+c], t)
+			}
+		}
+	}
+	return out
+}
+
+// splat moves each block of p by s times its flow towards q and writes the
+// mix of p and its match in q into pixels of out that are not filled yet
+func splat(out *image.RGBA, filled []bool, p, q *image.RGBA, flow []image.Point, s float64) {
+	w, h := p.Bounds().Dx(), p.Bounds().Dy()
+	bw := (w + flowBlock - 1) / flowBlock
+	for i, v := range flow {
+		x0, y0 := (i%bw)*flowBlock, (i/bw)*flowBlock
+		ox := int(math.Round(float64(v.X) * s))
+		oy := int(math.Round(float64(v.Y) * s))
+		for y := y0; y < min(y0+flowBlock, h); y++ {
+			for x := x0; x < min(x0+flowBlock, w); x++ {
+				tx, ty := x+ox, y+oy
+				if tx < 0 || ty < 0 || tx >= w || ty >= h || filled[ty*w+tx] {
+					continue
+				}
+				qx, qy := min(max(x+v.X, 0), w-1), min(max(y+v.Y, 0), h-1)
+				pi, qi, oi := p.PixOffset(x, y), q.PixOffset(qx, qy), out.PixOffset(tx, ty)
+				for c := 0; c < 4; c++ {
+					out.Pix[oi+c] = mix(p.Pix[pi+c], q.Pix[qi+c], s)
+				}
+				filled[ty*w+tx] = true
+			}
+		}
+	}
+}
+
+func luma(img *image.RGBA) []uint8 {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	out := make([]uint8, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := img.PixOffset(x, y)
+			out[y*w+x] = uint8((299*int(img.Pix[i]) + 587*int(img.Pix[i+1]) + 114*int(img.Pix[i+2])) / 1000)
+		}
+	}
+	return out
+}
+
+func mix(a, b uint8, t float64) uint8 {
+	return uint8(math.Round(float64(a)*(1-t) + float64(b)*t))
+}
+
+// toRGBA returns img as a zero-origin *image.RGBA, copying only when needed
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) && rgba.Stride == 4*rgba.Bounds().Dx() {
+		return rgba
+	}
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(out, out.Bounds(), img, b.Min, draw.Src)
+	return out
+}
@@ -0,0 +1,66 @@
+package video
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestInterpolateLinear(t *testing.T) {
+	clip := &Clip{Frames: []image.Image{solid(4, 4, 0), solid(4, 4, 200), solid(4, 4, 100)}, FPS: 12}
+	out := clip.Interpolate(4, Linear)
+	if len(out.Frames) != 9 || out.FPS != 48 {
+		t.Fatalf("expected 9 frames at 48 fps, got %d at %v", len(out.Frames), out.FPS)
+	}
+	// Span between first and last frame is unchanged
+	if got, want := float64(len(out.Frames)-1)/out.FPS, float64(len(clip.Frames)-1)/clip.FPS; got != want {
+		t.Errorf("timing changed: %v != %v", got, want)
+	}
+	want := []uint8{0, 50, 100, 150, 200, 175, 150, 125, 100}
+	for i, frame := range out.Frames {
+		if r, _, _, _ := frame.At(1, 1).RGBA(); uint8(r>>8) != want[i] {
+			t.Errorf("frame %d: got %d, want %d", i, r>>8, want[i])
+		}
+	}
+
+	if same := clip.Interpolate(1, Linear); len(same.Frames) != 3 || same.FPS != 12 {
+		t.Errorf("factor 1 should copy the clip")
+	}
+}
+
+func TestInterpolateFlowLite(t *testing.T) {
+	// A bright square moving 4px to the right
+	a, b := square(32, 16, 8, 4), square(32, 16, 12, 4)
+	mid := Between(a, b, 0.5, FlowLite)
+
+	// Flow places the square halfway; a cross-fade would leave two half-bright squares
+	if v := mid.RGBAAt(13, 6).R; v != 255 {
+		t.Errorf("expected moved square at x=13, got %d", v)
+	}
+	if v := mid.RGBAAt(9, 6).R; v != 0 {
+		t.Errorf("expected background behind moved square, got %d", v)
+	}
+
+	blend := Between(a, b, 0.5, Linear)
+	if v := blend.RGBAAt(9, 6).R; v != 128 {
+		t.Errorf("linear blend should ghost the square, got %d", v)
+	}
+}
+
+func TestBlendResizes(t *testing.T) {
+	out := Blend(solid(4, 4, 0), solid(8, 8, 100), 1)
+	if out.Bounds().Dx() != 4 || out.RGBAAt(2, 2).R != 100 {
+		t.Errorf("unexpected blend %v %v", out.Bounds(), out.RGBAAt(2, 2))
+	}
+}
+
+// square draws an 8x8 white square at (x, y) on black
+func square(width, height, x, y int) *image.RGBA {
+	img := solid(width, height, 0)
+	for j := y; j < y+8; j++ {
+		for i := x; i < x+8; i++ {
+			img.SetRGBA(i, j, color.RGBA{255, 255, 255, 255})
+		}
+	}
+	return img
+}
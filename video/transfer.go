@@ -0,0 +1,106 @@
+package video
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// generateVideo is stablediffusion.GenerateVideo, replaced in tests
+var generateVideo = stablediffusion.GenerateVideo
+
+// sizeMultiple is the granularity used when the output size is taken from the source
+const sizeMultiple = 16
+
+// Transfer restyles a clip by feeding its frames to GenerateVideo as a VACE
+// control sequence, one chunk at a time
+type Transfer struct {
+	// Request is the template for every chunk. Frames, ControlFrames and
+	// VaceStrength are set per chunk. Width and Height default to the source
+	// frame size rounded down to a multiple of 16.
+	Request stablediffusion.VideoRequest
+	// ChunkFrames is the number of frames per GenerateVideo call and must be 4n+1.
+	// Defaults to 1, which renders every frame independently.
+	ChunkFrames int32
+	// VaceStrength scales the control sequence. Defaults to 1.
+	VaceStrength float32
+	// Control turns source frames into control frames, e.g. a preprocess.Func.
+	// Source frames are used as is when nil.
+	Control func(image.Image) (image.Image, error)
+	// Progress is called after each chunk with the number of frames done
+	Progress func(done, total int)
+}
+
+// Run renders clip chunk by chunk and returns a clip with the same frame count and frame rate
+func (t *Transfer) Run(ctx *stablediffusion.SDContext, clip *Clip) (*Clip, error) {
+	if clip == nil || len(clip.Frames) == 0 {
+		return nil, fmt.Errorf("clip has no frames")
+	}
+	chunk := t.ChunkFrames
+	if chunk <= 0 {
+		chunk = 1
+	}
+	if (chunk-1)%4 != 0 {
+		return nil, fmt.Errorf("chunk frames must be 4n+1, got %d", chunk)
+	}
+
+	req := t.Request
+	if req.Width <= 0 || req.Height <= 0 {
+		b := clip.Frames[0].Bounds()
+		req.Width = int32(max(b.Dx()/sizeMultiple*sizeMultiple, sizeMultiple))
+		req.Height = int32(max(b.Dy()/sizeMultiple*sizeMultiple, sizeMultiple))
+	}
+	req.VaceStrength = t.VaceStrength
+	req.FPS = int(clip.FPS + 0.5)
+
+	out := &Clip{Frames: make([]image.Image, 0, len(clip.Frames)), FPS: clip.FPS}
+	total := len(clip.Frames)
+	for start := 0; start < total; start += int(chunk) {
+		n := min(int(chunk), total-start)
+		control, err := t.controlFrames(clip.Frames[start : start+n])
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", start+1, err)
+		}
+		// Pad a short final chunk to 4n+1 by holding the last frame
+		for (len(control)-1)%4 != 0 {
+			control = append(control, control[len(control)-1])
+		}
+
+		chunkReq := req
+		chunkReq.Frames = int32(len(control))
+		chunkReq.ControlFrames = control
+		video, err := generateVideo(ctx, chunkReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render frames %d-%d: %w", start+1, start+n, err)
+		}
+		if len(video.Frames) < n {
+			return nil, fmt.Errorf("frames %d-%d: expected %d frames, got %d", start+1, start+n, n, len(video.Frames))
+		}
+		for _, frame := range video.Frames[:n] {
+			out.Frames = append(out.Frames, frame)
+		}
+
+		if t.Progress != nil {
+			t.Progress(len(out.Frames), total)
+		}
+	}
+	return out, nil
+}
+
+// controlFrames runs Control over frames
+func (t *Transfer) controlFrames(frames []image.Image) ([]image.Image, error) {
+	control := make([]image.Image, len(frames))
+	for i, frame := range frames {
+		if t.Control == nil {
+			control[i] = frame
+			continue
+		}
+		c, err := t.Control(frame)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build control frame: %w", err)
+		}
+		control[i] = c
+	}
+	return control, nil
+}
@@ -0,0 +1,100 @@
+package video
+
+import (
+	"errors"
+	"image"
+	"testing"
+
+	"github.com/kawai-network/stablediffusion"
+)
+
+// fakeGenerate replaces generateVideo and records every request
+func fakeGenerate(t *testing.T) *[]stablediffusion.VideoRequest {
+	t.Helper()
+	var calls []stablediffusion.VideoRequest
+	orig := generateVideo
+	generateVideo = func(ctx *stablediffusion.SDContext, req stablediffusion.VideoRequest) (*stablediffusion.Video, error) {
+		calls = append(calls, req)
+		frames := make([]*image.RGBA, req.Frames)
+		for i := range frames {
+			frames[i] = solid(int(req.Width), int(req.Height), uint8(len(calls)))
+		}
+		return &stablediffusion.Video{Frames: frames, FPS: req.FPS}, nil
+	}
+	t.Cleanup(func() { generateVideo = orig })
+	return &calls
+}
+
+func TestTransferChunks(t *testing.T) {
+	calls := fakeGenerate(t)
+	clip := &Clip{FPS: 24}
+	for i := range 7 {
+		clip.Frames = append(clip.Frames, solid(40, 20, uint8(i)))
+	}
+
+	controlled := 0
+	var progress []int
+	tr := &Transfer{
+		Request:      stablediffusion.VideoRequest{Prompt: "oil painting"},
+		ChunkFrames:  5,
+		VaceStrength: 0.8,
+		Control: func(img image.Image) (image.Image, error) {
+			controlled++
+			return img, nil
+		},
+		Progress: func(done, total int) { progress = append(progress, done) },
+	}
+	out, err := tr.Run(nil, clip)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(out.Frames) != 7 || out.FPS != 24 {
+		t.Fatalf("expected 7 frames at 24 fps, got %d at %v", len(out.Frames), out.FPS)
+	}
+	if controlled != 7 {
+		t.Errorf("expected 7 control frames, got %d", controlled)
+	}
+	if len(*calls) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(*calls))
+	}
+	first, second := (*calls)[0], (*calls)[1]
+	if first.Frames != 5 || len(first.ControlFrames) != 5 || first.VaceStrength != 0.8 {
+		t.Errorf("unexpected first chunk: %d frames, %d control, strength %v", first.Frames, len(first.ControlFrames), first.VaceStrength)
+	}
+	// The 2 remaining frames are padded to 5 by holding the last one
+	if second.Frames != 5 || second.ControlFrames[4] != clip.Frames[6] {
+		t.Errorf("final chunk was not padded with the last frame")
+	}
+	if first.Width != 32 || first.Height != 16 || first.Prompt != "oil painting" || first.FPS != 24 {
+		t.Errorf("unexpected request: %dx%d %q %d fps", first.Width, first.Height, first.Prompt, first.FPS)
+	}
+	if r, _, _, _ := out.Frames[6].At(0, 0).RGBA(); r>>8 != 2 {
+		t.Errorf("last frame should come from the second chunk")
+	}
+	if len(progress) != 2 || progress[1] != 7 {
+		t.Errorf("unexpected progress %v", progress)
+	}
+}
+
+func TestTransferErrors(t *testing.T) {
+	fakeGenerate(t)
+	clip := &Clip{Frames: []image.Image{solid(16, 16, 0)}, FPS: 8}
+
+	if _, err := (&Transfer{ChunkFrames: 4}).Run(nil, clip); err == nil {
+		t.Error("expected error for chunk size that is not 4n+1")
+	}
+	if _, err := (&Transfer{}).Run(nil, &Clip{}); err == nil {
+		t.Error("expected error for empty clip")
+	}
+	failing := &Transfer{Control: func(image.Image) (image.Image, error) { return nil, errors.New("boom") }}
+	if _, err := failing.Run(nil, clip); err == nil {
+		t.Error("expected control error")
+	}
+
+	generateVideo = func(*stablediffusion.SDContext, stablediffusion.VideoRequest) (*stablediffusion.Video, error) {
+		return &stablediffusion.Video{}, nil
+	}
+	if _, err := (&Transfer{}).Run(nil, clip); err == nil {
+		t.Error("expected error for short chunk output")
+	}
+}
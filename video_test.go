@@ -0,0 +1,108 @@
+package stablediffusion
+
+import (
+	"image"
+	"testing"
+	"unsafe"
+)
+
+func TestGenerateVideoRequest(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx := newFakeContext(sd)
+
+	high := SDSampleParams{SampleSteps: 4}
+	base := &SDVidGenParams{Seed: 7, SampleParams: SDSampleParams{SampleSteps: 20}}
+	control := []image.Image{
+		image.NewRGBA(image.Rect(0, 0, 16, 16)),
+		image.NewRGBA(image.Rect(0, 0, 64, 32)),
+	}
+
+	video, err := GenerateVideo(ctx, VideoRequest{
+		Prompt:                "a cat walking",
+		Width:                 32,
+		Height:                16,
+		Frames:                5,
+		StartFrame:            image.NewRGBA(image.Rect(0, 0, 100, 50)),
+		EndFrame:              image.NewGray(image.Rect(0, 0, 8, 8)),
+		ControlFrames:         control,
+		HighNoiseSampleParams: &high,
+		MOEBoundary:           0.875,
+		Params:                base,
+	})
+	if err != nil {
+		t.Fatalf("GenerateVideo failed: %v", err)
+	}
+	if len(video.Frames) != 5 || video.FPS != DefaultVideoFPS {
+		t.Fatalf("expected 5 frames at %d fps, got %d at %d", DefaultVideoFPS, len(video.Frames), video.FPS)
+	}
+	for i, frame := range video.Frames {
+		if frame.Bounds().Dx() != 32 || frame.Bounds().Dy() != 16 {
+			t.Errorf("frame %d: unexpected size %v", i, frame.Bounds())
+		}
+		if v := frame.RGBAAt(0, 0).R; v != fake.fill+uint8(i) {
+			t.Errorf("frame %d: expected value %d, got %d", i, fake.fill+uint8(i), v)
+		}
+	}
+
+	call := fake.vidCalls[0]
+	if CGoString(call.Prompt) != "a cat walking" || call.VideoFrames != 5 || call.Seed != 7 {
+		t.Errorf("unexpected params: prompt %q frames %d seed %d", CGoString(call.Prompt), call.VideoFrames, call.Seed)
+	}
+	if call.InitImage.Width != 32 || call.InitImage.Height != 16 || call.EndImage.Width != 32 || call.EndImage.Height != 16 {
+		t.Errorf("start/end frames not fitted: %dx%d, %dx%d", call.InitImage.Width, call.InitImage.Height, call.EndImage.Width, call.EndImage.Height)
+	}
+	if call.ControlFramesSize != 2 || call.VaceStrength != 1 {
+		t.Fatalf("unexpected control params: size %d strength %v", call.ControlFramesSize, call.VaceStrength)
+	}
+	for i, frame := range unsafe.Slice(call.ControlFrames, call.ControlFramesSize) {
+		if frame.Width != 32 || frame.Height != 16 || frame.Channel != 3 {
+			t.Errorf("control frame %d: got %dx%dx%d", i, frame.Width, frame.Height, frame.Channel)
+		}
+	}
+	if call.HighNoiseSampleParams.SampleSteps != 4 || call.SampleParams.SampleSteps != 20 || call.MOEBoundary != 0.875 {
+		t.Errorf("unexpected sample params: high %d low %d boundary %v", call.HighNoiseSampleParams.SampleSteps, call.SampleParams.SampleSteps, call.MOEBoundary)
+	}
+	if base.Prompt != nil || base.ControlFrames != nil {
+		t.Error("GenerateVideo should not modify the base params")
+	}
+
+	// Without Params the context's defaults are used, with Seed applied on top
+	seed := int64(99)
+	if _, err := GenerateVideo(ctx, VideoRequest{Width: 32, Height: 16, Frames: 1, Seed: &seed}); err != nil {
+		t.Fatalf("GenerateVideo failed: %v", err)
+	}
+	if call := fake.vidCalls[1]; call.Seed != 99 || call.SampleParams.SampleSteps != 20 {
+		t.Errorf("expected default params with seed 99, got seed %d steps %d", call.Seed, call.SampleParams.SampleSteps)
+	}
+}
+
+func TestGenerateVideoValidation(t *testing.T) {
+	sd, fake := newFakeSD()
+	ctx := newFakeContext(sd)
+
+	for name, req := range map[string]VideoRequest{
+		"size":     {Frames: 5},
+		"frames":   {Width: 16, Height: 16, Frames: 6},
+		"zero":     {Width: 16, Height: 16},
+		"boundary": {Width: 16, Height: 16, Frames: 1, MOEBoundary: 2},
+		"control":  {Width: 16, Height: 16, Frames: 1, ControlFrames: []image.Image{nil}},
+		"start":    {Width: 16, Height: 16, Frames: 1, StartFrame: image.NewRGBA(image.Rectangle{})},
+	} {
+		if _, err := GenerateVideo(ctx, req); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+	if len(fake.vidCalls) != 0 {
+		t.Errorf("expected no generations, got %d", len(fake.vidCalls))
+	}
+
+	sd.generateVideo = func(ctx unsafe.Pointer, params *SDVidGenParams, numFramesOut *int32) *SDImage {
+		return nil
+	}
+	if _, err := GenerateVideo(ctx, VideoRequest{Width: 16, Height: 16, Frames: 1}); err == nil {
+		t.Error("expected error when generate_video returns no frames")
+	}
+	if _, err := GenerateVideo(&SDContext{}, VideoRequest{Width: 16, Height: 16, Frames: 1}); err == nil {
+		t.Error("expected error for uninitialized context")
+	}
+}